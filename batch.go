@@ -0,0 +1,55 @@
+package sqlitestore
+
+import (
+	"context"
+	"errors"
+	"strconv"
+
+	"github.com/gorilla/sessions"
+)
+
+// LoadBatch loads up to limit unexpired sessions whose row IDs are greater
+// than afterID, in row ID order, and returns them with the row ID of the last
+// row read. Passing that ID back in pages through the whole table, which is
+// done once the returned ID equals afterID. Rows that cannot be decoded with
+// the store's codecs are reported to the error handler and skipped, so a
+// batch shorter than limit, or even an empty one, need not be the last.
+func (m *Store) LoadBatch(ctx context.Context, afterID int64, limit int) ([]*sessions.Session, int64, error) {
+	if limit <= 0 {
+		return nil, afterID, errors.New("sqlitestore: batch limit must be positive")
+	}
+	if m.tableOpts.WithoutRowid {
+		return nil, afterID, errTextIDs
+	}
+	rows, err := m.db.QueryContext(ctx, "SELECT id, name, session_data, created_on, modified_on, expires_on FROM "+
+		m.table+" WHERE id > ? AND expires_on >= ? ORDER BY id LIMIT ?",
+		afterID, m.transform("expires_on", m.clock()), limit)
+	if err != nil {
+		return nil, afterID, err
+	}
+	defer rows.Close()
+
+	var batch []*sessions.Session
+	lastID := afterID
+	for rows.Next() {
+		var sess sessionRow
+		if err := rows.Scan(&sess.id, &sess.name, &sess.data, &sess.createdOn, &sess.modifiedOn, &sess.expiresOn); err != nil {
+			return nil, lastID, err
+		}
+		if lastID, err = strconv.ParseInt(sess.id, 10, 64); err != nil {
+			return nil, afterID, err
+		}
+		session := sessions.NewSession(m, sess.name)
+		session.Options = &sessions.Options{Path: m.Options.Path, MaxAge: m.Options.MaxAge}
+		session.ID = m.prefix + sess.id
+		if err := m.decodeValues(session, sess.data); err != nil {
+			m.handleError(err)
+			continue
+		}
+		session.Values["created_on"] = sess.createdOn
+		session.Values["modified_on"] = sess.modifiedOn
+		session.Values["expires_on"] = sess.expiresOn
+		batch = append(batch, session)
+	}
+	return batch, lastID, rows.Err()
+}
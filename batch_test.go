@@ -0,0 +1,65 @@
+package sqlitestore
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLoadBatch(t *testing.T) {
+	var reported []error
+	store := newTestStore(t, WithErrorHandler(func(err error) { reported = append(reported, err) }))
+	var ids []string
+	for i := 0; i < 5; i++ {
+		ids = append(ids, insertTestSession(t, store, map[interface{}]interface{}{"n": i}).ID)
+	}
+	insertTestSession(t, store, map[interface{}]interface{}{"expires_on": time.Now().Add(-time.Hour)})
+	// an undecodable row leaves the first batch short
+	_, err := store.db.ExecContext(context.Background(), "UPDATE sessions SET session_data = 'bad' WHERE id = ?", ids[1])
+	require.NoError(t, err)
+
+	var seen []interface{}
+	var afterID int64
+	for {
+		batch, lastID, err := store.LoadBatch(context.Background(), afterID, 2)
+		require.NoError(t, err)
+		for _, sess := range batch {
+			seen = append(seen, sess.Values["n"])
+			assert.Equal(t, "test", sess.Name())
+		}
+		if lastID == afterID {
+			break
+		}
+		afterID = lastID
+	}
+	assert.Equal(t, []interface{}{0, 2, 3, 4}, seen)
+	assert.Len(t, reported, 1)
+}
+
+func BenchmarkLoadBatch(b *testing.B) {
+	for name, size := range map[string]int64{"mmap": 256 << 20, "nommap": 0} {
+		b.Run(name, func(b *testing.B) {
+			db := newTestDB(b)
+			db.SetMaxOpenConns(1)
+			store := newTestStoreDB(b, db, WithMmapSize(size))
+			for i := 0; i < 10000; i++ {
+				insertTestSession(b, store, map[interface{}]interface{}{"n": i})
+			}
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				var afterID int64
+				for {
+					_, lastID, err := store.LoadBatch(context.Background(), afterID, 500)
+					require.NoError(b, err)
+					if lastID == afterID {
+						break
+					}
+					afterID = lastID
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,114 @@
+package sqlitestore
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"time"
+
+	"github.com/gorilla/sessions"
+)
+
+const benchTable = "sessions_bench"
+
+// BenchmarkResult summarizes a BenchmarkSave run. The percentiles are taken
+// over the duration of the individual saves.
+type BenchmarkResult struct {
+	TotalDuration time.Duration
+	OpsPerSecond  float64
+	P50           time.Duration
+	P95           time.Duration
+	P99           time.Duration
+	ErrorCount    int
+}
+
+// BenchmarkSave measures how fast the store can write sessions with its
+// current codecs and database settings. It encodes and inserts iterations
+// sessions into a scratch table, sessions_bench, deletes them again and drops
+// the table, so the sessions table is never touched.
+func (m *Store) BenchmarkSave(ctx context.Context, iterations int) (BenchmarkResult, error) {
+	if iterations <= 0 {
+		return BenchmarkResult{}, errors.New("sqlitestore: benchmark iterations must be positive")
+	}
+	if m.readOnly {
+		return BenchmarkResult{}, ErrReadOnly
+	}
+	cTableQ := "CREATE TABLE IF NOT EXISTS " + benchTable +
+		" (id INTEGER PRIMARY KEY, " +
+		"session_data LONGBLOB, " +
+		"created_on TIMESTAMP DEFAULT 0, " +
+		"modified_on TIMESTAMP DEFAULT CURRENT_TIMESTAMP, " +
+		"expires_on TIMESTAMP DEFAULT 0);"
+	if _, err := m.db.ExecContext(ctx, cTableQ); err != nil {
+		return BenchmarkResult{}, err
+	}
+	defer m.db.ExecContext(context.Background(), "DROP TABLE IF EXISTS "+benchTable)
+
+	var res BenchmarkResult
+	durations := make([]time.Duration, 0, iterations)
+	ids := make([]int64, 0, iterations)
+	start := time.Now()
+	for i := 0; i < iterations; i++ {
+		if err := ctx.Err(); err != nil {
+			return BenchmarkResult{}, err
+		}
+		opStart := time.Now()
+		id, err := m.benchSave(ctx, i)
+		durations = append(durations, time.Since(opStart))
+		if err != nil {
+			res.ErrorCount++
+			continue
+		}
+		ids = append(ids, id)
+	}
+	res.TotalDuration = time.Since(start)
+
+	for _, id := range ids {
+		if _, err := m.db.ExecContext(ctx, "DELETE FROM "+benchTable+" WHERE id = ?", id); err != nil {
+			return BenchmarkResult{}, err
+		}
+	}
+
+	if res.TotalDuration > 0 {
+		res.OpsPerSecond = float64(iterations) / res.TotalDuration.Seconds()
+	}
+	sort.Slice(durations, func(i, j int) bool { return durations[i] < durations[j] })
+	res.P50 = percentile(durations, 50)
+	res.P95 = percentile(durations, 95)
+	res.P99 = percentile(durations, 99)
+	return res, nil
+}
+
+// benchSave encodes a small session and inserts it into the benchmark table.
+func (m *Store) benchSave(ctx context.Context, i int) (int64, error) {
+	session := sessions.NewSession(m, "bench")
+	session.Options = &sessions.Options{Path: m.Options.Path, MaxAge: m.Options.MaxAge}
+	session.Values["iteration"] = i
+	session.Values["user"] = "benchmark"
+	encoded, err := m.encodeValues(session)
+	if err != nil {
+		return 0, err
+	}
+	now := time.Now()
+	expiresOn := now.Add(time.Second * time.Duration(session.Options.MaxAge))
+	res, err := m.db.ExecContext(ctx, "INSERT INTO "+benchTable+
+		" (id, session_data, created_on, modified_on, expires_on) VALUES (NULL, ?, ?, ?, ?)",
+		encoded, now, now, expiresOn)
+	if err != nil {
+		return 0, err
+	}
+	return res.LastInsertId()
+}
+
+// percentile returns the p-th percentile of the sorted durations using the
+// nearest-rank method.
+func percentile(sorted []time.Duration, p int) time.Duration {
+	if len(sorted) == 0 {
+		return 0
+	}
+	rank := (p*len(sorted) + 99) / 100
+	if rank < 1 {
+		rank = 1
+	}
+	return sorted[rank-1]
+}
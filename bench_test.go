@@ -0,0 +1,26 @@
+package sqlitestore
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBenchmarkSave(t *testing.T) {
+	store := newTestStore(t)
+	defer store.Close()
+
+	res, err := store.BenchmarkSave(context.Background(), 50)
+	require.NoError(t, err)
+	assert.Equal(t, 0, res.ErrorCount)
+	assert.True(t, res.OpsPerSecond > 0)
+	assert.True(t, res.P50 <= res.P95 && res.P95 <= res.P99)
+	assert.Equal(t, 0, countRows(t, store))
+
+	var tables int
+	require.NoError(t, store.db.QueryRowContext(context.Background(),
+		"SELECT COUNT(*) FROM sqlite_master WHERE name = 'sessions_bench'").Scan(&tables))
+	assert.Equal(t, 0, tables)
+}
@@ -0,0 +1,156 @@
+package sqlitestore
+
+import (
+	"container/list"
+	"context"
+	"strings"
+	"sync"
+)
+
+// CacheStats reports the activity of the session cache enabled with
+// WithLRUCache.
+type CacheStats struct {
+	Hits, Misses   int64
+	Size, Capacity int
+}
+
+// sessionCache is a least recently used cache of session rows keyed by
+// session ID. A nil *sessionCache is a valid, always empty cache.
+type sessionCache struct {
+	mu           sync.Mutex
+	capacity     int
+	ll           *list.List
+	items        map[string]*list.Element
+	hits, misses int64
+}
+
+type cacheEntry struct {
+	id  string
+	row sessionRow
+}
+
+func newSessionCache(capacity int) *sessionCache {
+	return &sessionCache{
+		capacity: capacity,
+		ll:       list.New(),
+		items:    make(map[string]*list.Element),
+	}
+}
+
+func (c *sessionCache) get(id string) (sessionRow, bool) {
+	if c == nil {
+		return sessionRow{}, false
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	el, ok := c.items[id]
+	if !ok {
+		c.misses++
+		return sessionRow{}, false
+	}
+	c.hits++
+	c.ll.MoveToFront(el)
+	return el.Value.(*cacheEntry).row, true
+}
+
+func (c *sessionCache) add(id string, row sessionRow) {
+	if c == nil {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if el, ok := c.items[id]; ok {
+		el.Value.(*cacheEntry).row = row
+		c.ll.MoveToFront(el)
+		return
+	}
+	c.items[id] = c.ll.PushFront(&cacheEntry{id: id, row: row})
+	if c.ll.Len() > c.capacity {
+		oldest := c.ll.Back()
+		c.ll.Remove(oldest)
+		delete(c.items, oldest.Value.(*cacheEntry).id)
+	}
+}
+
+func (c *sessionCache) remove(id string) {
+	if c == nil {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if el, ok := c.items[id]; ok {
+		c.ll.Remove(el)
+		delete(c.items, id)
+	}
+}
+
+// purge empties the cache and returns the number of entries removed.
+func (c *sessionCache) purge() int {
+	if c == nil {
+		return 0
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	n := c.ll.Len()
+	c.ll.Init()
+	c.items = make(map[string]*list.Element)
+	return n
+}
+
+func (c *sessionCache) stats() CacheStats {
+	if c == nil {
+		return CacheStats{}
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return CacheStats{Hits: c.hits, Misses: c.misses, Size: c.ll.Len(), Capacity: c.capacity}
+}
+
+// CacheStats returns the hit and miss counts and occupancy of the session
+// cache. It is zero when the store has no cache.
+func (m *Store) CacheStats() CacheStats {
+	return m.cache.stats()
+}
+
+// FlushCache empties the session cache, for example after rows were edited
+// directly in the database, and returns the number of entries removed. It
+// returns 0 when the store has no cache. It holds the write lock of every
+// session while flushing, so no concurrent load or save can cache a session
+// mid flush.
+func (m *Store) FlushCache() int {
+	m.locks.LockAll()
+	defer m.locks.UnlockAll()
+	return m.cache.purge()
+}
+
+// PrefetchSessions reads the unexpired sessions among ids in one query and
+// puts them in the session cache, so the first requests after a restart do
+// not all go to the database. It returns the number of sessions cached,
+// which is 0 when the store has no cache.
+func (m *Store) PrefetchSessions(ctx context.Context, ids []string) (int64, error) {
+	if m.cache == nil || len(ids) == 0 {
+		return 0, nil
+	}
+	args := make([]interface{}, 0, len(ids)+1)
+	for _, id := range ids {
+		args = append(args, m.rowID(id))
+	}
+	args = append(args, m.transform("expires_on", m.clock()))
+	rows, err := m.db.QueryContext(ctx, "SELECT id, session_data, created_on, modified_on, expires_on FROM "+
+		m.table+" WHERE id IN (?"+strings.Repeat(", ?", len(ids)-1)+") AND expires_on > ?", args...)
+	if err != nil {
+		return 0, err
+	}
+	defer rows.Close()
+
+	var n int64
+	for rows.Next() {
+		var sess sessionRow
+		if err := m.scanSession(rows, &sess); err != nil {
+			return n, err
+		}
+		m.cache.add(m.prefix+sess.id, sess)
+		n++
+	}
+	return n, rows.Err()
+}
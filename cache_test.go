@@ -0,0 +1,107 @@
+package sqlitestore
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/gorilla/sessions"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPrefetchSessions(t *testing.T) {
+	store := newTestStore(t, WithLRUCache(100))
+	var ids []string
+	for i := 0; i < 50; i++ {
+		ids = append(ids, insertTestSession(t, store, map[interface{}]interface{}{"n": i}).ID)
+	}
+	expired := insertTestSession(t, store, map[interface{}]interface{}{"expires_on": time.Now().Add(-time.Hour)})
+
+	n, err := store.PrefetchSessions(context.Background(), append(ids, expired.ID, "999"))
+	require.NoError(t, err)
+	assert.Equal(t, int64(50), n)
+
+	for i, id := range ids {
+		assert.Equal(t, i, loadTestSession(t, store, id).Values["n"])
+	}
+	stats := store.CacheStats()
+	assert.Equal(t, int64(50), stats.Hits)
+	assert.Zero(t, stats.Misses)
+	assert.Equal(t, 50, stats.Size)
+
+	report, err := store.HealthReport(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, 1.0, report.CacheHitRate)
+}
+
+func TestLRUCacheInvalidation(t *testing.T) {
+	store := newTestStore(t, WithLRUCache(2))
+	a := insertTestSession(t, store, map[interface{}]interface{}{"n": 1})
+	loadTestSession(t, store, a.ID)
+
+	a.Values["n"] = 2
+	require.NoError(t, store.save(context.Background(), a))
+	assert.Equal(t, 2, loadTestSession(t, store, a.ID).Values["n"])
+
+	b := insertTestSession(t, store, nil)
+	c := insertTestSession(t, store, nil)
+	loadTestSession(t, store, b.ID)
+	loadTestSession(t, store, c.ID)
+	stats := store.CacheStats()
+	assert.Equal(t, 2, stats.Size)
+	assert.Equal(t, int64(4), stats.Misses)
+	loadTestSession(t, store, a.ID)
+	assert.Equal(t, int64(5), store.CacheStats().Misses)
+}
+
+func TestFlushCache(t *testing.T) {
+	assert.Zero(t, newTestStore(t).FlushCache())
+
+	store := newTestStore(t, WithLRUCache(10))
+	sess := insertTestSession(t, store, map[interface{}]interface{}{"user": "alice"})
+	other := insertTestSession(t, store, map[interface{}]interface{}{"user": "bob"})
+	assert.Equal(t, "alice", loadTestSession(t, store, sess.ID).Values["user"])
+
+	_, err := store.GetUnderlyingDB().Exec(
+		"UPDATE sessions SET session_data = (SELECT session_data FROM sessions WHERE id = ?) WHERE id = ?", other.ID, sess.ID)
+	require.NoError(t, err)
+	assert.Equal(t, "alice", loadTestSession(t, store, sess.ID).Values["user"])
+
+	assert.Equal(t, 1, store.FlushCache())
+	assert.Equal(t, "bob", loadTestSession(t, store, sess.ID).Values["user"])
+
+	// the flush waits for the write lock
+	store.locks.Lock("")
+	flushed := make(chan int)
+	go func() { flushed <- store.FlushCache() }()
+	select {
+	case <-flushed:
+		t.Fatal("FlushCache did not wait for the write lock")
+	case <-time.After(20 * time.Millisecond):
+	}
+	store.locks.Unlock("")
+	assert.Equal(t, 1, <-flushed)
+}
+
+func TestFlushCacheShardedMutex(t *testing.T) {
+	store := newTestStore(t, WithLRUCache(10), WithShardedMutex(64))
+	shards := store.locks.(*shardedLocker)
+	var sess *sessions.Session
+	for sess == nil || shards.shard(sess.ID) == shards.shard("") {
+		sess = insertTestSession(t, store, nil)
+	}
+	loadTestSession(t, store, sess.ID)
+
+	// a load on any shard holds off the flush
+	store.locks.RLock(sess.ID)
+	flushed := make(chan int)
+	go func() { flushed <- store.FlushCache() }()
+	select {
+	case <-flushed:
+		t.Fatal("FlushCache did not wait for the session lock")
+	case <-time.After(20 * time.Millisecond):
+	}
+	store.locks.RUnlock(sess.ID)
+	assert.Equal(t, 1, <-flushed)
+}
@@ -0,0 +1,174 @@
+package sqlitestore
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/gob"
+	"errors"
+
+	"github.com/gorilla/sessions"
+)
+
+// chunkSnapshot is the session.Values key under which load records the
+// values read from the values table, so Save can tell which keys changed.
+type chunkSnapshot struct{}
+
+// queryer is implemented by DB and *sql.Tx.
+type queryer interface {
+	QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error)
+}
+
+// valuesTable is the table WithChunkedValueStorage keeps session values in,
+// session_values for the default table and <table>_values otherwise.
+func (m *Store) valuesTable() string {
+	if m.table == defaultTableName {
+		return "session_values"
+	}
+	return m.table + "_values"
+}
+
+// createValuesTable creates the values table and a trigger that removes the
+// values of deleted sessions, however they are deleted.
+func (m *Store) createValuesTable(ctx context.Context) error {
+	for _, q := range []string{
+		"CREATE TABLE IF NOT EXISTS " + m.valuesTable() +
+			" (session_id TEXT NOT NULL, key BLOB NOT NULL, value BLOB, PRIMARY KEY (session_id, key))",
+		"CREATE TRIGGER IF NOT EXISTS " + m.table + "_values_cleanup AFTER DELETE ON " + m.table +
+			" BEGIN DELETE FROM " + m.valuesTable() + " WHERE session_id = OLD.id; END",
+	} {
+		if _, err := m.db.ExecContext(ctx, q); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// gobBytes returns the gob encoding of v as an interface value. It identifies
+// keys in the values table and detects changed values.
+func gobBytes(v interface{}) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := gob.NewEncoder(&buf).Encode(&v); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// saveChunked inserts or updates the row of session and then writes the
+// values that changed since it was loaded to the values table, in one
+// transaction.
+func (m *Store) saveChunked(ctx context.Context, session *sessions.Session, insert bool) (err error) {
+	tx, err := m.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	id := session.ID
+	defer func() {
+		if err != nil {
+			tx.Rollback()
+			session.ID = id
+		}
+	}()
+	if insert {
+		err = m.insertStmt(ctx, tx.StmtContext(ctx, m.create), session)
+	} else {
+		err = m.saveStmt(ctx, tx.StmtContext(ctx, m.update), session)
+	}
+	if err != nil {
+		return err
+	}
+	if err = m.writeChunks(ctx, tx, session); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// writeChunks writes the values of session that differ from the snapshot
+// taken by load and removes deleted keys. Without a snapshot every value is
+// rewritten.
+func (m *Store) writeChunks(ctx context.Context, tx *sql.Tx, session *sessions.Session) error {
+	rowID := m.rowID(session.ID)
+	old, loaded := session.Values[chunkSnapshot{}].(map[string][]byte)
+	if !loaded {
+		if _, err := tx.ExecContext(ctx, "DELETE FROM "+m.valuesTable()+" WHERE session_id = ?", rowID); err != nil {
+			return err
+		}
+	}
+
+	snapshot := make(map[string][]byte, len(session.Values))
+	for k, v := range session.Values {
+		if _, ok := k.(chunkSnapshot); ok {
+			continue
+		}
+		key, err := gobBytes(k)
+		if err != nil {
+			return err
+		}
+		value, err := gobBytes(v)
+		if err != nil {
+			return err
+		}
+		snapshot[string(key)] = value
+		if prev, ok := old[string(key)]; ok && bytes.Equal(prev, value) {
+			continue
+		}
+		encoded, err := m.encodeMap(session.Name(), map[interface{}]interface{}{k: v})
+		if err != nil {
+			return err
+		}
+		if _, err := tx.ExecContext(ctx, "INSERT OR REPLACE INTO "+m.valuesTable()+
+			" (session_id, key, value) VALUES (?, ?, ?)", rowID, key, encoded); err != nil {
+			return err
+		}
+	}
+	for key := range old {
+		if _, ok := snapshot[key]; ok {
+			continue
+		}
+		if _, err := tx.ExecContext(ctx, "DELETE FROM "+m.valuesTable()+
+			" WHERE session_id = ? AND key = ?", rowID, []byte(key)); err != nil {
+			return err
+		}
+	}
+	session.Values[chunkSnapshot{}] = snapshot
+	return nil
+}
+
+// readChunks adds the values of session stored in the values table to
+// session.Values and records the snapshot writeChunks compares against.
+func (m *Store) readChunks(ctx context.Context, q queryer, session *sessions.Session) error {
+	rows, err := q.QueryContext(ctx, "SELECT key, value FROM "+m.valuesTable()+
+		" WHERE session_id = ?", m.rowID(session.ID))
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	snapshot := make(map[string][]byte)
+	for rows.Next() {
+		var key []byte
+		var data string
+		if err := rows.Scan(&key, &data); err != nil {
+			return err
+		}
+		values := make(map[interface{}]interface{}, 1)
+		if err := m.decodeMap(session.Name(), data, &values); err != nil {
+			return err
+		}
+		for k, v := range values {
+			value, err := gobBytes(v)
+			if err != nil {
+				return err
+			}
+			session.Values[k] = v
+			snapshot[string(key)] = value
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+	session.Values[chunkSnapshot{}] = snapshot
+	return nil
+}
+
+var errChunkedCoalescing = errors.New("sqlitestore: chunked value storage cannot be combined with write coalescing")
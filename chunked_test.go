@@ -0,0 +1,80 @@
+package sqlitestore
+
+import (
+	"context"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gorilla/sessions"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// valueRowIDs returns the rowid of each values table row of the session id.
+func valueRowIDs(t *testing.T, store *Store, id string) []int64 {
+	rows, err := store.db.QueryContext(context.Background(),
+		"SELECT rowid FROM session_values WHERE session_id = ? ORDER BY key", id)
+	require.NoError(t, err)
+	defer rows.Close()
+	var ids []int64
+	for rows.Next() {
+		var rowid int64
+		require.NoError(t, rows.Scan(&rowid))
+		ids = append(ids, rowid)
+	}
+	require.NoError(t, rows.Err())
+	return ids
+}
+
+func TestChunkedValueStorage(t *testing.T) {
+	store := newTestStore(t, WithChunkedValueStorage())
+	id := insertTestSession(t, store, map[interface{}]interface{}{
+		"user": "alice", "cart": []string{"book"}, "visits": 1,
+	}).ID
+	before := valueRowIDs(t, store, id)
+	require.Len(t, before, 3)
+
+	sess := loadTestSession(t, store, id)
+	assert.Equal(t, "alice", sess.Values["user"])
+	assert.Equal(t, []string{"book"}, sess.Values["cart"])
+	sess.Values["visits"] = 2
+	require.NoError(t, store.save(context.Background(), sess))
+
+	// only the changed value is rewritten
+	after := valueRowIDs(t, store, id)
+	require.Len(t, after, 3)
+	var changed int
+	for i := range before {
+		if before[i] != after[i] {
+			changed++
+		}
+	}
+	assert.Equal(t, 1, changed)
+
+	sess = loadTestSession(t, store, id)
+	assert.Equal(t, 2, sess.Values["visits"])
+	delete(sess.Values, "cart")
+	require.NoError(t, store.save(context.Background(), sess))
+	sess = loadTestSession(t, store, id)
+	assert.NotContains(t, sess.Values, "cart")
+	assert.Len(t, valueRowIDs(t, store, id), 2)
+
+	r := httptest.NewRequest("GET", "/", nil)
+	sess.Options = &sessions.Options{MaxAge: -1}
+	require.NoError(t, store.Save(r, httptest.NewRecorder(), sess))
+	assert.Empty(t, valueRowIDs(t, store, id))
+
+	_, err := New(newTestDB(t), nil, WithChunkedValueStorage(), WithWriteCoalescing(time.Second))
+	assert.Equal(t, errChunkedCoalescing, err)
+}
+
+func TestChunkedNewSession(t *testing.T) {
+	var created int
+	store := newTestStore(t, WithChunkedValueStorage(), WithHooks(Hooks{OnCreate: func(*sessions.Session) { created++ }}))
+	sess, _, err := store.NewSession(context.Background(), "test", map[interface{}]interface{}{"user": "alice"}, nil)
+	require.NoError(t, err)
+	assert.Equal(t, 1, created)
+	assert.Len(t, valueRowIDs(t, store, sess.ID), 1)
+	assert.Equal(t, "alice", loadTestSession(t, store, sess.ID).Values["user"])
+}
@@ -0,0 +1,196 @@
+package sqlitestore
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"time"
+)
+
+// GarbageCollect deletes every session that has expired and returns the
+// number of rows removed.
+func (m *Store) GarbageCollect(ctx context.Context) (n int64, err error) {
+	if m.readOnly {
+		return 0, ErrReadOnly
+	}
+	ctx, cancel := m.opContext(ctx, opDelete)
+	defer cancel()
+	start := time.Now()
+	defer m.observeOp("delete_expired", start, &err)
+	res, err := m.db.ExecContext(ctx, "DELETE FROM "+m.table+" WHERE expires_on < ?", m.transform("expires_on", m.clock()))
+	m.observeQuery("gc", "", start, err)
+	if err != nil {
+		return 0, err
+	}
+	atomic.StoreInt64(&m.lastCleanup, time.Now().UnixNano())
+	m.pruneChecksums(m.clock())
+	return res.RowsAffected()
+}
+
+// DeleteExpired deletes every session that has expired and returns the
+// number of rows removed. It is GarbageCollect without a context.
+func (m *Store) DeleteExpired() (int64, error) {
+	return m.GarbageCollect(context.Background())
+}
+
+// errCleanupRunning and errCleanupStopped are returned by StartCleanup and
+// StopCleanup when the cleanup goroutine is already in the requested state.
+var (
+	errCleanupRunning = errors.New("sqlitestore: cleanup is already running")
+	errCleanupStopped = errors.New("sqlitestore: cleanup is not running")
+)
+
+// StartCleanup starts a goroutine that deletes expired sessions every
+// interval, like WithCleanupInterval. It returns an error if cleanup is
+// already running; StopCleanup stops it again.
+func (m *Store) StartCleanup(interval time.Duration) error {
+	if interval <= 0 {
+		return errors.New("sqlitestore: cleanup interval must be positive")
+	}
+	if m.readOnly {
+		return ErrReadOnly
+	}
+	return m.startCleanup(interval)
+}
+
+// StopCleanup stops the cleanup goroutine and waits for a pass in progress
+// to finish. It returns an error if cleanup is not running.
+func (m *Store) StopCleanup() error {
+	m.cleanupMu.Lock()
+	defer m.cleanupMu.Unlock()
+	if m.cleanupStop == nil {
+		return errCleanupStopped
+	}
+	close(m.cleanupStop)
+	<-m.cleanupDone
+	m.cleanupStop, m.cleanupDone = nil, nil
+	return nil
+}
+
+func (m *Store) startCleanup(interval time.Duration) error {
+	m.cleanupMu.Lock()
+	defer m.cleanupMu.Unlock()
+	if m.cleanupStop != nil {
+		return errCleanupRunning
+	}
+	stop, done := make(chan struct{}), make(chan struct{})
+	m.cleanupStop, m.cleanupDone = stop, done
+
+	m.wg.Add(1)
+	go func() {
+		defer m.wg.Done()
+		defer close(done)
+
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-m.done:
+				return
+			case <-stop:
+				return
+			case <-ticker.C:
+				m.cleanup(context.Background())
+			}
+		}
+	}()
+	return nil
+}
+
+// cleanup runs a single pass of the background cleanup goroutine.
+func (m *Store) cleanup(ctx context.Context) {
+	if _, err := m.GarbageCollect(ctx); err != nil {
+		m.handleError(err)
+		return
+	}
+	if m.vacuumPages > 0 {
+		if err := m.IncrementalVacuum(ctx, m.vacuumPages); err != nil {
+			m.handleError(err)
+		}
+	}
+	// cleanups is only touched by the cleanup goroutine
+	m.cleanups++
+	if m.analyzeEvery > 0 && m.cleanups%m.analyzeEvery == 0 {
+		if err := m.Analyze(ctx); err != nil {
+			m.handleError(err)
+		}
+	}
+}
+
+// Analyze refreshes the query planner statistics for the sessions table. Run
+// it after large bulk changes such as imports or mass deletes.
+func (m *Store) Analyze(ctx context.Context) error {
+	_, err := m.db.ExecContext(ctx, "ANALYZE "+m.table)
+	return err
+}
+
+// DeleteExpiredInBatches deletes expired sessions batchSize at a time, each
+// batch in its own short transaction, pausing delay between batches so
+// other writers are not locked out for long. It returns the number of
+// sessions deleted.
+func (m *Store) DeleteExpiredInBatches(ctx context.Context, batchSize int, delay time.Duration) (int64, error) {
+	if batchSize <= 0 {
+		return 0, errors.New("sqlitestore: batch size must be positive")
+	}
+	if m.readOnly {
+		return 0, ErrReadOnly
+	}
+	var total int64
+	for {
+		n, err := m.deleteExpiredBatch(ctx, batchSize)
+		total += n
+		if err != nil {
+			return total, err
+		}
+		if n < int64(batchSize) {
+			atomic.StoreInt64(&m.lastCleanup, time.Now().UnixNano())
+			return total, nil
+		}
+		select {
+		case <-ctx.Done():
+			return total, ctx.Err()
+		case <-time.After(delay):
+		}
+	}
+}
+
+func (m *Store) deleteExpiredBatch(ctx context.Context, batchSize int) (n int64, err error) {
+	ctx, cancel := m.opContext(ctx, opDelete)
+	defer cancel()
+	tx, err := m.db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, err
+	}
+	defer func() {
+		if err != nil {
+			tx.Rollback()
+			n = 0
+		}
+	}()
+
+	rows, err := tx.QueryContext(ctx, "SELECT id FROM "+m.table+" WHERE expires_on < ? LIMIT ?", m.transform("expires_on", m.clock()), batchSize)
+	if err != nil {
+		return 0, err
+	}
+	var ids []string
+	for rows.Next() {
+		var id string
+		if err = rows.Scan(&id); err != nil {
+			rows.Close()
+			return 0, err
+		}
+		ids = append(ids, id)
+	}
+	rows.Close()
+	if err = rows.Err(); err != nil {
+		return 0, err
+	}
+
+	del := tx.StmtContext(ctx, m.delete)
+	for _, id := range ids {
+		if _, err = del.ExecContext(ctx, id); err != nil {
+			return 0, err
+		}
+	}
+	return int64(len(ids)), tx.Commit()
+}
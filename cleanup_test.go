@@ -0,0 +1,193 @@
+package sqlitestore
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func countRows(t testing.TB, store *Store) int {
+	var n int
+	require.NoError(t, store.db.QueryRowContext(context.Background(), "SELECT COUNT(*) FROM sessions").Scan(&n))
+	return n
+}
+
+func TestGarbageCollect(t *testing.T) {
+	store := newTestStore(t)
+	insertTestSession(t, store, nil)
+	insertTestSession(t, store, map[interface{}]interface{}{"expires_on": time.Now().Add(-time.Hour)})
+
+	n, err := store.GarbageCollect(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), n)
+	assert.Equal(t, 1, countRows(t, store))
+}
+
+func TestGarbageCollectWithClock(t *testing.T) {
+	clock := &fakeClock{t: time.Unix(0, 0)}
+	store := newTestStore(t, WithClock(clock.Now))
+	insertTestSession(t, store, nil)
+
+	n, err := store.DeleteExpired()
+	require.NoError(t, err)
+	assert.Equal(t, int64(0), n)
+
+	clock.Advance(2 * time.Hour)
+	n, err = store.DeleteExpired()
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), n)
+}
+
+func TestCleanupWithIncrementalVacuum(t *testing.T) {
+	db := newTestDB(t)
+	_, err := db.Exec("PRAGMA auto_vacuum = INCREMENTAL")
+	require.NoError(t, err)
+	var errs []error
+	store := newTestStoreDB(t, db,
+		WithCleanupInterval(10*time.Millisecond),
+		WithIncrementalVacuumAfterGC(100),
+		WithErrorHandler(func(err error) { errs = append(errs, err) }),
+	)
+
+	insertTestSession(t, store, map[interface{}]interface{}{"expires_on": time.Now().Add(-time.Hour)})
+	require.Eventually(t, func() bool { return countRows(t, store) == 0 }, time.Second, 10*time.Millisecond)
+	store.Close()
+	assert.Empty(t, errs)
+}
+
+func TestDeleteExpiredInBatches(t *testing.T) {
+	store := newTestStore(t)
+	for i := 0; i < 5; i++ {
+		insertTestSession(t, store, nil)
+	}
+	for i := 0; i < 23; i++ {
+		insertTestSession(t, store, map[interface{}]interface{}{"expires_on": time.Now().Add(-time.Hour)})
+	}
+
+	n, err := store.DeleteExpiredInBatches(context.Background(), 5, time.Millisecond)
+	require.NoError(t, err)
+	assert.Equal(t, int64(23), n)
+	assert.Equal(t, 5, countRows(t, store))
+}
+
+func TestDeleteExpiredInBatchesCanceled(t *testing.T) {
+	store := newTestStore(t)
+	for i := 0; i < 10; i++ {
+		insertTestSession(t, store, map[interface{}]interface{}{"expires_on": time.Now().Add(-time.Hour)})
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(20*time.Millisecond, cancel)
+	n, err := store.DeleteExpiredInBatches(ctx, 2, time.Hour)
+	assert.Equal(t, context.Canceled, err)
+	assert.Equal(t, int64(2), n)
+	assert.Equal(t, 8, countRows(t, store))
+}
+
+func TestAutoGCOnInsert(t *testing.T) {
+	store := newTestStore(t, WithAutoGCOnInsert(1.0))
+	for i := 0; i < 5; i++ {
+		insertTestSession(t, store, map[interface{}]interface{}{"expires_on": time.Now().Add(-time.Hour)})
+		insertTestSession(t, store, nil)
+		assert.Eventually(t, func() bool { return countRows(t, store) == i+1 }, time.Second, 10*time.Millisecond)
+	}
+
+	_, err := New(newTestDB(t), nil, WithAutoGCOnInsert(0))
+	assert.Error(t, err)
+}
+
+func TestAnalyze(t *testing.T) {
+	store := newTestStore(t)
+	ctx := context.Background()
+	require.NoError(t, store.EnsureIndex(ctx, "expires_on", false))
+	for i := 0; i < 50; i++ {
+		insertTestSession(t, store, map[interface{}]interface{}{"n": i})
+	}
+	require.NoError(t, store.Analyze(ctx))
+
+	var n int
+	require.NoError(t, store.db.QueryRowContext(ctx, "SELECT COUNT(*) FROM sqlite_stat1 WHERE tbl = 'sessions'").Scan(&n))
+	assert.True(t, n > 0)
+}
+
+func TestAnalyzeAfterNCleanups(t *testing.T) {
+	store := newTestStore(t, WithAnalyzeAfterNCleanups(2))
+	ctx := context.Background()
+	require.NoError(t, store.EnsureIndex(ctx, "expires_on", false))
+	insertTestSession(t, store, nil)
+
+	analyzed := func() bool {
+		var n int
+		require.NoError(t, store.db.QueryRowContext(ctx,
+			"SELECT COUNT(*) FROM sqlite_master WHERE name = 'sqlite_stat1'").Scan(&n))
+		return n > 0
+	}
+	store.cleanup(ctx)
+	assert.False(t, analyzed())
+	store.cleanup(ctx)
+	assert.True(t, analyzed())
+}
+
+func TestStartStopCleanup(t *testing.T) {
+	store := newTestStore(t)
+	expired := func() {
+		insertTestSession(t, store, map[interface{}]interface{}{"expires_on": time.Now().Add(-time.Hour)})
+	}
+	assert.Error(t, store.StopCleanup())
+
+	require.NoError(t, store.StartCleanup(10*time.Millisecond))
+	assert.Error(t, store.StartCleanup(10*time.Millisecond))
+	expired()
+	require.Eventually(t, func() bool { return countRows(t, store) == 0 }, time.Second, 10*time.Millisecond)
+
+	require.NoError(t, store.StopCleanup())
+	expired()
+	time.Sleep(50 * time.Millisecond)
+	assert.Equal(t, 1, countRows(t, store))
+
+	n, err := store.DeleteExpired()
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), n)
+
+	require.NoError(t, store.StartCleanup(10*time.Millisecond))
+	store.Close()
+}
+
+// BenchmarkDeleteExpiredLargeTable measures DeleteExpired removing 1,000
+// expired sessions from a table of 100,000 live ones, with and without the
+// index New creates on expires_on.
+func BenchmarkDeleteExpiredLargeTable(b *testing.B) {
+	for _, indexed := range []bool{true, false} {
+		name := "indexed"
+		if !indexed {
+			name = "unindexed"
+		}
+		b.Run(name, func(b *testing.B) {
+			store := newTestStore(b)
+			if !indexed {
+				_, err := store.db.Exec("DROP INDEX idx_sessions_expires_on")
+				require.NoError(b, err)
+			}
+			insertRows := func(n int, expiresOn time.Time) {
+				_, err := store.db.Exec("WITH RECURSIVE seq(i) AS (SELECT 1 UNION ALL SELECT i + 1 FROM seq WHERE i < ?) "+
+					"INSERT INTO sessions (session_data, created_on, modified_on, expires_on) SELECT '', ?, ?, ? FROM seq",
+					n, time.Now(), time.Now(), expiresOn)
+				require.NoError(b, err)
+			}
+			insertRows(100000, time.Now().Add(time.Hour))
+
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				b.StopTimer()
+				insertRows(1000, time.Now().Add(-time.Hour))
+				b.StartTimer()
+				n, err := store.DeleteExpired()
+				require.NoError(b, err)
+				require.Equal(b, int64(1000), n)
+			}
+		})
+	}
+}
@@ -0,0 +1,122 @@
+package sqlitestore
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/gorilla/sessions"
+)
+
+// pendingUpdate is a coalesced session update waiting to be written.
+type pendingUpdate struct {
+	mu        sync.Mutex
+	args      []interface{}
+	data      string
+	expiresOn time.Time
+	done      bool
+}
+
+func (m *Store) saveCoalesced(ctx context.Context, session *sessions.Session) error {
+	if err := m.runPreSave(session); err != nil {
+		return err
+	}
+	if err := m.lock(ctx, session.ID); err != nil {
+		return err
+	}
+	defer m.locks.Unlock(session.ID)
+
+	args, err := m.updateArgs(session)
+	if err != nil {
+		return err
+	}
+	for {
+		v, loaded := m.pending.LoadOrStore(session.ID, &pendingUpdate{})
+		pw := v.(*pendingUpdate)
+		pw.mu.Lock()
+		if pw.done {
+			// flushed between the load and the lock, queue a fresh write
+			pw.mu.Unlock()
+			continue
+		}
+		pw.args = args
+		pw.data = args[0].(string)
+		pw.expiresOn = args[2].(time.Time)
+		pw.mu.Unlock()
+		if !loaded {
+			id := session.ID
+			time.AfterFunc(m.coalesceWindow, func() {
+				if err := m.flushWrite(context.Background(), id); err != nil {
+					m.handleError(err)
+				}
+			})
+		}
+		return nil
+	}
+}
+
+// pendingWrite returns a snapshot of the pending write for id, if any.
+func (m *Store) pendingWrite(id string) *pendingUpdate {
+	v, ok := m.pending.Load(id)
+	if !ok {
+		return nil
+	}
+	pw := v.(*pendingUpdate)
+	pw.mu.Lock()
+	defer pw.mu.Unlock()
+	if pw.done || pw.args == nil {
+		return nil
+	}
+	return &pendingUpdate{data: pw.data, expiresOn: pw.expiresOn}
+}
+
+func (m *Store) flushWrite(ctx context.Context, id string) error {
+	v, ok := m.pending.LoadAndDelete(id)
+	if !ok {
+		return nil
+	}
+	pw := v.(*pendingUpdate)
+	pw.mu.Lock()
+	defer pw.mu.Unlock()
+	pw.done = true
+	if pw.args == nil {
+		return nil
+	}
+	_, err := m.update.ExecContext(ctx, m.transformArgs(m.updateColumns(), pw.args)...)
+	m.cache.remove(id)
+	return err
+}
+
+// cancelWrite drops the pending write for id without writing it.
+func (m *Store) cancelWrite(id string) {
+	if v, ok := m.pending.LoadAndDelete(id); ok {
+		pw := v.(*pendingUpdate)
+		pw.mu.Lock()
+		pw.done = true
+		pw.mu.Unlock()
+	}
+}
+
+// flushWrites writes every pending coalesced update.
+func (m *Store) flushWrites(ctx context.Context) error {
+	var firstErr error
+	m.pending.Range(func(key, _ interface{}) bool {
+		if err := ctx.Err(); err != nil {
+			firstErr = err
+			return false
+		}
+		if err := m.flushWrite(ctx, key.(string)); err != nil && firstErr == nil {
+			firstErr = err
+		}
+		return true
+	})
+	return firstErr
+}
+
+// GracefulClose writes all pending coalesced updates and then closes the
+// store. The store is closed even if flushing fails or ctx expires.
+func (m *Store) GracefulClose(ctx context.Context) error {
+	err := m.flushWrites(ctx)
+	m.Close()
+	return err
+}
@@ -0,0 +1,87 @@
+package sqlitestore
+
+import (
+	"context"
+	"database/sql"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gorilla/securecookie"
+	"github.com/gorilla/sessions"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// trackUpdates installs a trigger counting the UPDATE statements that reach
+// the sessions table, read back with countUpdates.
+func trackUpdates(t testing.TB, store *Store) {
+	ctx := context.Background()
+	_, err := store.db.ExecContext(ctx, "CREATE TABLE update_count (n INTEGER)")
+	require.NoError(t, err)
+	_, err = store.db.ExecContext(ctx, "CREATE TRIGGER count_updates AFTER UPDATE ON sessions BEGIN INSERT INTO update_count VALUES (1); END")
+	require.NoError(t, err)
+}
+
+func countUpdates(t testing.TB, store *Store) int {
+	var n int
+	require.NoError(t, store.db.QueryRowContext(context.Background(), "SELECT COUNT(*) FROM update_count").Scan(&n))
+	return n
+}
+
+func loadTestSession(t testing.TB, store *Store, id string) *sessions.Session {
+	sess := sessions.NewSession(store, "test")
+	sess.ID = id
+	sess.Options = &sessions.Options{MaxAge: 3600}
+	require.NoError(t, store.load(context.Background(), sess))
+	return sess
+}
+
+func TestWriteCoalescing(t *testing.T) {
+	store := newTestStore(t, WithWriteCoalescing(50*time.Millisecond))
+	trackUpdates(t, store)
+	id := insertTestSession(t, store, map[interface{}]interface{}{"count": 0}).ID
+
+	for i := 1; i <= 100; i++ {
+		sess := loadTestSession(t, store, id)
+		assert.Equal(t, i-1, sess.Values["count"])
+		sess.Values["count"] = i
+		require.NoError(t, store.save(context.Background(), sess))
+	}
+	assert.Equal(t, 0, countUpdates(t, store))
+
+	require.Eventually(t, func() bool { return countUpdates(t, store) > 0 }, time.Second, 10*time.Millisecond)
+	assert.True(t, countUpdates(t, store) <= 2)
+	assert.Equal(t, 100, loadTestSession(t, store, id).Values["count"])
+}
+
+func TestWriteCoalescingDelete(t *testing.T) {
+	store := newTestStore(t, WithWriteCoalescing(20*time.Millisecond))
+	sess := insertTestSession(t, store, nil)
+	sess.IsNew = false
+	require.NoError(t, store.save(context.Background(), sess))
+
+	r := httptest.NewRequest("GET", "/", nil)
+	require.NoError(t, store.Delete(r, httptest.NewRecorder(), sess))
+	time.Sleep(50 * time.Millisecond)
+	assert.Equal(t, 0, countRows(t, store))
+}
+
+func TestGracefulCloseFlushes(t *testing.T) {
+	path := tempDBPath(t)
+	db, err := sql.Open("sqlite3", path)
+	require.NoError(t, err)
+	store, err := New(db, [][]byte{securecookie.GenerateRandomKey(32)}, WithWriteCoalescing(time.Hour))
+	require.NoError(t, err)
+	sess := insertTestSession(t, store, map[interface{}]interface{}{"v": "old"})
+	sess.IsNew = false
+	sess.Values["v"] = "new"
+	require.NoError(t, store.save(context.Background(), sess))
+	require.NoError(t, store.GracefulClose(context.Background()))
+
+	db, err = sql.Open("sqlite3", path)
+	require.NoError(t, err)
+	reopened := newTestStoreDB(t, db)
+	reopened.Codecs = store.Codecs
+	assert.Equal(t, "new", loadTestSession(t, reopened, sess.ID).Values["v"])
+}
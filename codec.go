@@ -0,0 +1,121 @@
+package sqlitestore
+
+import (
+	"crypto/rand"
+	"errors"
+	"io"
+
+	"github.com/gorilla/securecookie"
+	"github.com/gorilla/sessions"
+)
+
+// encryptedMagic prefixes session data encrypted at rest. securecookie output
+// is base64, so it can never start with this byte.
+const encryptedMagic = 0x01
+
+var errNoAtRestKey = errors.New("sqlitestore: session data is encrypted but no at-rest key is configured")
+
+// encodeValues encodes session values into the form stored in session_data.
+func (m *Store) encodeValues(session *sessions.Session) (string, error) {
+	if m.chunked {
+		// the values live in the values table, session_data only holds an
+		// empty, signed map
+		return m.encodeMap(session.Name(), map[interface{}]interface{}{})
+	}
+	return m.encodeMap(session.Name(), session.Values)
+}
+
+// encodeMap encodes the values of the session named name.
+func (m *Store) encodeMap(name string, values map[interface{}]interface{}) (string, error) {
+	values, err := m.sealValues(m.writeValues(values))
+	if err != nil {
+		return "", err
+	}
+	var encoded string
+	if m.Serializer != nil {
+		encoded, err = m.Serializer.Serialize(name, values)
+	} else {
+		encoded, err = securecookie.EncodeMulti(name, values, m.codecs()...)
+	}
+	if err != nil {
+		return "", err
+	}
+	if encoded, err = m.seal(encoded); err != nil {
+		return "", err
+	}
+	if m.MaxLength > 0 && len(encoded) > m.MaxLength {
+		return "", ErrSessionTooLarge
+	}
+	return encoded, nil
+}
+
+// decodeValues decodes session_data into session values.
+func (m *Store) decodeValues(session *sessions.Session, data string) error {
+	return m.decodeMap(session.Name(), data, &session.Values)
+}
+
+// DecodeSessionData decodes the raw session_data of a session named name,
+// such as the data CreateFTSIndex hands its extract function, into the
+// session values.
+func (m *Store) DecodeSessionData(name, data string) (map[interface{}]interface{}, error) {
+	values := make(map[interface{}]interface{})
+	if err := m.decodeMap(name, data, &values); err != nil {
+		return nil, err
+	}
+	return values, nil
+}
+
+// decodeMap decodes data encoded by encodeMap into values.
+func (m *Store) decodeMap(name, data string, values *map[interface{}]interface{}) error {
+	if m.MaxLength > 0 && len(data) > m.MaxLength {
+		return ErrSessionTooLarge
+	}
+	encoded, err := m.open(data)
+	if err != nil {
+		return err
+	}
+	if m.Serializer != nil {
+		err = m.Serializer.Deserialize(name, encoded, values)
+	} else {
+		err = securecookie.DecodeMulti(name, encoded, values, m.codecs()...)
+	}
+	if err != nil {
+		return err
+	}
+	if err := m.openValues(*values); err != nil {
+		return err
+	}
+	m.readValues(*values)
+	return nil
+}
+
+func (m *Store) seal(data string) (string, error) {
+	if m.atRest == nil {
+		return data, nil
+	}
+	nonce := make([]byte, m.atRest.NonceSize(), 1+m.atRest.NonceSize()+len(data)+m.atRest.Overhead())
+	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+		return "", err
+	}
+	out := append([]byte{encryptedMagic}, nonce...)
+	return string(m.atRest.Seal(out, nonce, []byte(data), nil)), nil
+}
+
+func (m *Store) open(data string) (string, error) {
+	if len(data) == 0 || data[0] != encryptedMagic {
+		return data, nil
+	}
+	if m.atRest == nil {
+		return "", errNoAtRestKey
+	}
+	raw := []byte(data[1:])
+	size := m.atRest.NonceSize()
+	if len(raw) < size {
+		return "", errors.New("sqlitestore: encrypted session data is truncated")
+	}
+	plain, err := m.atRest.Open(nil, raw[:size], raw[size:], nil)
+	if err != nil {
+		return "", err
+	}
+	return string(plain), nil
+}
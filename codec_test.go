@@ -0,0 +1,103 @@
+package sqlitestore
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/gorilla/securecookie"
+	"github.com/gorilla/sessions"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func rawSessionData(t testing.TB, store *Store, id string) string {
+	var data string
+	require.NoError(t, store.db.QueryRowContext(context.Background(), "SELECT session_data FROM sessions WHERE id = ?", id).Scan(&data))
+	return data
+}
+
+func TestEncryptionAtRest(t *testing.T) {
+	db := newTestDB(t)
+	key := securecookie.GenerateRandomKey(32)
+	plainStore := newTestStoreDB(t, db)
+	store, err := New(db, [][]byte{key}, WithEncryptionAtRest(securecookie.GenerateRandomKey(32)))
+	require.NoError(t, err)
+	store.Codecs = plainStore.Codecs
+
+	// rows written before encryption was enabled stay readable
+	legacy := insertTestSession(t, plainStore, map[interface{}]interface{}{"email": "a@example.com"})
+	sealed := insertTestSession(t, store, map[interface{}]interface{}{"email": "b@example.com"})
+
+	data := rawSessionData(t, store, sealed.ID)
+	assert.Equal(t, byte(encryptedMagic), data[0])
+	plain, err := securecookie.EncodeMulti("test", sealed.Values, store.codecs()...)
+	require.NoError(t, err)
+	assert.False(t, strings.Contains(data, plain))
+
+	for id, email := range map[string]string{legacy.ID: "a@example.com", sealed.ID: "b@example.com"} {
+		loaded := sessions.NewSession(store, "test")
+		loaded.ID = id
+		require.NoError(t, store.load(context.Background(), loaded))
+		assert.Equal(t, email, loaded.Values["email"])
+	}
+
+	// a store without the key refuses encrypted rows instead of misdecoding them
+	loaded := sessions.NewSession(plainStore, "test")
+	loaded.ID = sealed.ID
+	assert.Equal(t, errNoAtRestKey, plainStore.load(context.Background(), loaded))
+}
+
+func TestEncryptionAtRestKeySize(t *testing.T) {
+	_, err := New(nil, nil, WithEncryptionAtRest([]byte("short")))
+	assert.Error(t, err)
+}
+
+func TestEncryptedValues(t *testing.T) {
+	store := newTestStore(t, WithEncryptedValues("email"))
+
+	sess := insertTestSession(t, store, map[interface{}]interface{}{
+		"email": "alice@example.com",
+		"theme": "dark",
+	})
+
+	// the raw row decodes with the codecs alone, but the email stays sealed
+	raw := make(map[interface{}]interface{})
+	require.NoError(t, securecookie.DecodeMulti("test", rawSessionData(t, store, sess.ID), &raw, store.codecs()...))
+	assert.Equal(t, "dark", raw["theme"])
+	sealed, ok := raw["email"].(encryptedValue)
+	require.True(t, ok)
+	assert.False(t, strings.Contains(string(sealed), "alice@example.com"))
+
+	loaded := loadTestSession(t, store, sess.ID)
+	assert.Equal(t, "alice@example.com", loaded.Values["email"])
+	assert.Equal(t, "dark", loaded.Values["theme"])
+	// encoding must not have replaced the caller's values
+	assert.Equal(t, "alice@example.com", sess.Values["email"])
+}
+
+func TestMaxLength(t *testing.T) {
+	store := newTestStore(t, WithMaxLength(8192))
+	ctx := context.Background()
+
+	// larger than securecookie's default limit
+	big := insertTestSession(t, store, map[interface{}]interface{}{"data": strings.Repeat("a", 3000)})
+	loadTestSession(t, store, big.ID)
+
+	huge := sessions.NewSession(store, "test")
+	huge.Options = &sessions.Options{MaxAge: 3600}
+	huge.Values["data"] = strings.Repeat("a", 8192)
+	assert.Equal(t, ErrSessionTooLarge, store.insert(ctx, huge))
+	big.Values["data"] = strings.Repeat("a", 8192)
+	big.IsNew = false
+	assert.Equal(t, ErrSessionTooLarge, store.save(ctx, big))
+
+	// rows written before the limit was lowered are not decoded
+	store.MaxLength = 1024
+	loaded := sessions.NewSession(store, "test")
+	loaded.ID = big.ID
+	assert.Equal(t, ErrSessionTooLarge, store.load(ctx, loaded))
+
+	_, err := New(newTestDB(t), nil, WithMaxLength(0))
+	assert.Error(t, err)
+}
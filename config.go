@@ -0,0 +1,189 @@
+package sqlitestore
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// StoreOptions holds the configuration of a store as plain fields, for
+// applications that load their configuration from a file. Fields missing
+// from a JSON or YAML document keep the values of DefaultStoreOptions. Key
+// pairs are base64 encoded in both formats.
+type StoreOptions struct {
+	// DSN is the SQLite data source name passed to Open, usually a file path.
+	DSN      string   `json:"dsn" yaml:"dsn"`
+	KeyPairs [][]byte `json:"key_pairs" yaml:"-"`
+	// TableName is the table sessions are stored in, see WithTableName.
+	TableName string `json:"table_name" yaml:"table_name"`
+	// MaxAge is the session lifetime in seconds.
+	MaxAge int `json:"max_age" yaml:"max_age"`
+	// MaxSessionBytes limits the size of an encoded session.
+	MaxSessionBytes int `json:"max_session_bytes" yaml:"max_session_bytes"`
+	// LRUCapacity enables a session cache of that many rows when positive.
+	LRUCapacity int `json:"lru_capacity" yaml:"lru_capacity"`
+	// JournalMode and SynchronousMode set the journal_mode and synchronous
+	// pragmas on every connection when not empty.
+	JournalMode     string `json:"journal_mode" yaml:"journal_mode"`
+	SynchronousMode string `json:"synchronous_mode" yaml:"synchronous_mode"`
+	// BusyTimeoutMs sets the busy_timeout pragma on every connection when
+	// positive.
+	BusyTimeoutMs int `json:"busy_timeout_ms" yaml:"busy_timeout_ms"`
+	// CleanupIntervalSecs starts the cleanup goroutine when positive.
+	CleanupIntervalSecs int  `json:"cleanup_interval_secs" yaml:"cleanup_interval_secs"`
+	ReadOnly            bool `json:"read_only" yaml:"read_only"`
+}
+
+// DefaultStoreOptions returns the options NewStore uses, without a DSN or
+// key pairs.
+func DefaultStoreOptions() StoreOptions {
+	return StoreOptions{
+		TableName:       defaultTableName,
+		MaxAge:          60 * 60 * 24 * 14,
+		MaxSessionBytes: 4096,
+	}
+}
+
+// plainStoreOptions has the fields of StoreOptions without its unmarshal
+// methods.
+type plainStoreOptions StoreOptions
+
+// UnmarshalJSON decodes data over DefaultStoreOptions.
+func (o *StoreOptions) UnmarshalJSON(data []byte) error {
+	p := plainStoreOptions(DefaultStoreOptions())
+	if err := json.Unmarshal(data, &p); err != nil {
+		return err
+	}
+	*o = StoreOptions(p)
+	return nil
+}
+
+// UnmarshalYAML decodes a YAML document over DefaultStoreOptions. It
+// implements the Unmarshaler interface of gopkg.in/yaml.v2, which
+// gopkg.in/yaml.v3 also accepts.
+func (o *StoreOptions) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	doc := struct {
+		plainStoreOptions `yaml:",inline"`
+		KeyPairs          []string `yaml:"key_pairs"`
+	}{plainStoreOptions: plainStoreOptions(DefaultStoreOptions())}
+	if err := unmarshal(&doc); err != nil {
+		return err
+	}
+	p := doc.plainStoreOptions
+	p.KeyPairs = nil
+	for i, k := range doc.KeyPairs {
+		key, err := base64.StdEncoding.DecodeString(k)
+		if err != nil {
+			return fmt.Errorf("sqlitestore: key pair %d: %v", i, err)
+		}
+		p.KeyPairs = append(p.KeyPairs, key)
+	}
+	*o = StoreOptions(p)
+	return nil
+}
+
+var (
+	journalModes     = []string{"DELETE", "TRUNCATE", "PERSIST", "MEMORY", "WAL", "OFF"}
+	synchronousModes = []string{"OFF", "NORMAL", "FULL", "EXTRA"}
+)
+
+func oneOf(v string, allowed []string) bool {
+	for _, a := range allowed {
+		if strings.EqualFold(v, a) {
+			return true
+		}
+	}
+	return false
+}
+
+// Validate returns every problem with o, or nil if there is none.
+func (o StoreOptions) Validate() []error {
+	var errs []error
+	invalid := func(format string, args ...interface{}) {
+		errs = append(errs, fmt.Errorf("sqlitestore: "+format, args...))
+	}
+	if o.DSN == "" {
+		invalid("DSN must not be empty")
+	}
+	if len(o.KeyPairs) == 0 {
+		invalid("at least one key pair is required")
+	}
+	for i, key := range o.KeyPairs {
+		switch {
+		case i%2 == 0 && len(key) == 0:
+			invalid("hash key %d must not be empty", i)
+		case i%2 == 1 && len(key) != 0 && len(key) != 16 && len(key) != 24 && len(key) != 32:
+			invalid("block key %d must be 16, 24 or 32 bytes, got %d", i, len(key))
+		}
+	}
+	if !identifier.MatchString(o.TableName) {
+		invalid("invalid table name %q", o.TableName)
+	}
+	if o.MaxAge < 0 {
+		invalid("max age must not be negative")
+	}
+	if o.MaxSessionBytes < 0 {
+		invalid("max session bytes must not be negative")
+	}
+	if o.LRUCapacity < 0 {
+		invalid("LRU capacity must not be negative")
+	}
+	if o.JournalMode != "" && !oneOf(o.JournalMode, journalModes) {
+		invalid("invalid journal mode %q", o.JournalMode)
+	}
+	if o.SynchronousMode != "" && !oneOf(o.SynchronousMode, synchronousModes) {
+		invalid("invalid synchronous mode %q", o.SynchronousMode)
+	}
+	if o.BusyTimeoutMs < 0 {
+		invalid("busy timeout must not be negative")
+	}
+	if o.CleanupIntervalSecs < 0 {
+		invalid("cleanup interval must not be negative")
+	}
+	return errs
+}
+
+// NewStoreWithOptions opens the database at opts.DSN and creates a store
+// configured by opts. It fails with all problems Validate reports.
+func NewStoreWithOptions(opts StoreOptions) (*Store, error) {
+	if errs := opts.Validate(); len(errs) > 0 {
+		msgs := make([]string, len(errs))
+		for i, err := range errs {
+			msgs[i] = err.Error()
+		}
+		return nil, errors.New(strings.Join(msgs, "; "))
+	}
+
+	dsn := opts.DSN
+	if opts.JournalMode != "" {
+		dsn = appendDSNParam(dsn, "_journal_mode="+strings.ToUpper(opts.JournalMode))
+	}
+	if opts.SynchronousMode != "" {
+		dsn = appendDSNParam(dsn, "_sync="+strings.ToUpper(opts.SynchronousMode))
+	}
+	if opts.BusyTimeoutMs > 0 {
+		dsn = appendDSNParam(dsn, "_busy_timeout="+strconv.Itoa(opts.BusyTimeoutMs))
+	}
+
+	storeOpts := []StoreOption{WithTableName(opts.TableName)}
+	if opts.LRUCapacity > 0 {
+		storeOpts = append(storeOpts, WithLRUCache(opts.LRUCapacity))
+	}
+	if opts.CleanupIntervalSecs > 0 {
+		storeOpts = append(storeOpts, WithCleanupInterval(time.Duration(opts.CleanupIntervalSecs)*time.Second))
+	}
+	if opts.ReadOnly {
+		storeOpts = append(storeOpts, WithReadOnly())
+	}
+	if opts.MaxSessionBytes > 0 {
+		storeOpts = append(storeOpts, WithMaxLength(opts.MaxSessionBytes))
+	}
+	if opts.MaxAge > 0 {
+		storeOpts = append(storeOpts, WithMaxAge(opts.MaxAge))
+	}
+	return Open(dsn, opts.KeyPairs, storeOpts...)
+}
@@ -0,0 +1,65 @@
+package sqlitestore
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"testing"
+
+	"github.com/gorilla/securecookie"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/yaml.v3"
+)
+
+func TestStoreOptionsUnmarshal(t *testing.T) {
+	key := securecookie.GenerateRandomKey(32)
+	encoded := base64.StdEncoding.EncodeToString(key)
+
+	var fromJSON StoreOptions
+	require.NoError(t, json.Unmarshal([]byte(`{"dsn": "sessions.db", "key_pairs": ["`+encoded+`"], "lru_capacity": 100}`), &fromJSON))
+	var fromYAML StoreOptions
+	require.NoError(t, yaml.Unmarshal([]byte("dsn: sessions.db\nkey_pairs:\n  - "+encoded+"\nlru_capacity: 100\n"), &fromYAML))
+
+	want := DefaultStoreOptions()
+	want.DSN = "sessions.db"
+	want.KeyPairs = [][]byte{key}
+	want.LRUCapacity = 100
+	assert.Equal(t, want, fromJSON)
+	assert.Equal(t, want, fromYAML)
+}
+
+func TestStoreOptionsValidate(t *testing.T) {
+	opts := DefaultStoreOptions()
+	opts.KeyPairs = [][]byte{securecookie.GenerateRandomKey(32), []byte("short")}
+	opts.MaxAge = -1
+	opts.JournalMode = "sideways"
+	errs := opts.Validate()
+	assert.Len(t, errs, 4)
+
+	_, err := NewStoreWithOptions(opts)
+	assert.Error(t, err)
+}
+
+func TestNewStoreWithOptions(t *testing.T) {
+	opts := DefaultStoreOptions()
+	opts.DSN = tempDBPath(t)
+	opts.KeyPairs = [][]byte{securecookie.GenerateRandomKey(32)}
+	opts.MaxAge = 600
+	opts.LRUCapacity = 10
+	opts.JournalMode = "wal"
+	opts.SynchronousMode = "normal"
+	require.Empty(t, opts.Validate())
+
+	store, err := NewStoreWithOptions(opts)
+	require.NoError(t, err)
+	defer store.Close()
+	assert.Equal(t, 600, store.Options.MaxAge)
+	assert.Equal(t, 10, store.CacheStats().Capacity)
+	mode, err := store.GetPragma(context.Background(), "journal_mode")
+	require.NoError(t, err)
+	assert.Equal(t, "wal", mode)
+	sync, err := store.GetPragma(context.Background(), "synchronous")
+	require.NoError(t, err)
+	assert.Equal(t, "1", sync)
+}
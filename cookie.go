@@ -0,0 +1,55 @@
+package sqlitestore
+
+import (
+	"errors"
+	"strings"
+
+	"github.com/gorilla/securecookie"
+)
+
+// GetSessionCookieValue returns the cookie value Save would send to the
+// browser for the session named name with the given ID.
+func (m *Store) GetSessionCookieValue(name, sessionID string) (string, error) {
+	return securecookie.EncodeMulti(name, sessionID, m.codecs()...)
+}
+
+// DecodeSessionCookie returns the session ID held in a cookie value for the
+// session named name.
+func (m *Store) DecodeSessionCookie(name, cookieValue string) (string, error) {
+	var id string
+	if err := securecookie.DecodeMulti(name, cookieValue, &id, m.codecs()...); err != nil {
+		return "", err
+	}
+	return id, nil
+}
+
+// rowID returns the database row ID for a session ID. IDs carrying another
+// store's prefix map to an ID that matches no row.
+func (m *Store) rowID(sessionID string) string {
+	if m.prefix == "" {
+		return sessionID
+	}
+	if !strings.HasPrefix(sessionID, m.prefix) {
+		return ""
+	}
+	return strings.TrimPrefix(sessionID, m.prefix)
+}
+
+// VerifyCodecs round-trips a value through the store's codecs so that
+// misconfigured keys, such as a block key of the wrong length, are reported
+// when the store is created rather than on the first request.
+func (m *Store) VerifyCodecs() error {
+	codecs := m.codecs()
+	encoded, err := securecookie.EncodeMulti("_verify", "_verify_value_", codecs...)
+	if err != nil {
+		return err
+	}
+	var out string
+	if err := securecookie.DecodeMulti("_verify", encoded, &out, codecs...); err != nil {
+		return err
+	}
+	if out != "_verify_value_" {
+		return errors.New("sqlitestore: codecs did not round-trip the verification value")
+	}
+	return nil
+}
@@ -0,0 +1,124 @@
+package sqlitestore
+
+import (
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gorilla/securecookie"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGetSessionCookieValue(t *testing.T) {
+	store := newTestStore(t)
+
+	r := httptest.NewRequest("GET", "/", nil)
+	sess, err := store.New(r, "test")
+	require.NoError(t, err)
+	w := httptest.NewRecorder()
+	require.NoError(t, store.Save(r, w, sess))
+	cookies := w.Result().Cookies()
+	require.Len(t, cookies, 1)
+
+	value, err := store.GetSessionCookieValue("test", sess.ID)
+	require.NoError(t, err)
+
+	// the encoding is timestamped, so compare what the values decode to
+	var fromSave, fromHelper string
+	require.NoError(t, securecookie.DecodeMulti("test", cookies[0].Value, &fromSave, store.codecs()...))
+	require.NoError(t, securecookie.DecodeMulti("test", value, &fromHelper, store.codecs()...))
+	assert.Equal(t, fromSave, fromHelper)
+	assert.Equal(t, sess.ID, fromHelper)
+}
+
+func TestDecodeSessionCookie(t *testing.T) {
+	store := newTestStore(t)
+
+	value, err := store.GetSessionCookieValue("test", "42")
+	require.NoError(t, err)
+	id, err := store.DecodeSessionCookie("test", value)
+	require.NoError(t, err)
+	assert.Equal(t, "42", id)
+
+	_, err = store.DecodeSessionCookie("other", value)
+	assert.Error(t, err)
+}
+
+func TestIDPrefix(t *testing.T) {
+	key := securecookie.GenerateRandomKey(32)
+	acme, err := New(newTestDB(t), [][]byte{key}, WithIDPrefix("acme"))
+	require.NoError(t, err)
+	defer acme.Close()
+	globex, err := New(newTestDB(t), [][]byte{key}, WithIDPrefix("globex"))
+	require.NoError(t, err)
+	defer globex.Close()
+
+	r := httptest.NewRequest("GET", "/", nil)
+	var cookies []string
+	for _, store := range []*Store{acme, globex} {
+		sess, err := store.New(r, "test")
+		require.NoError(t, err)
+		sess.Values["tenant"] = store.prefix
+		w := httptest.NewRecorder()
+		require.NoError(t, store.Save(r, w, sess))
+		cookies = append(cookies, w.Header().Get("Set-Cookie"))
+	}
+
+	// both stores hand out row 1, but the IDs differ
+	acmeSess := loadTestSession(t, acme, "acme:1")
+	assert.Equal(t, "acme:", acmeSess.Values["tenant"])
+	loadTestSession(t, globex, "globex:1")
+
+	// a cookie from one tenant yields a new session on the other
+	r2 := httptest.NewRequest("GET", "/", nil)
+	r2.Header.Add("Cookie", cookies[0])
+	sess, err := globex.New(r2, "test")
+	require.NoError(t, err)
+	assert.True(t, sess.IsNew)
+	sess, err = acme.New(r2, "test")
+	require.NoError(t, err)
+	assert.False(t, sess.IsNew)
+	assert.Equal(t, "acme:1", sess.ID)
+
+	require.NoError(t, globex.Delete(r2, httptest.NewRecorder(), sess))
+	assert.Equal(t, 1, countRows(t, acme))
+
+	_, err = New(nil, nil, WithIDPrefix("a:b"))
+	assert.Error(t, err)
+}
+
+func TestVerifyCodecs(t *testing.T) {
+	store := newTestStore(t)
+	assert.NoError(t, store.VerifyCodecs())
+
+	db := newTestDB(t)
+	defer db.Close()
+	_, err := NewStore(db, [][]byte{securecookie.GenerateRandomKey(32), []byte("short")})
+	assert.Error(t, err)
+}
+
+func TestCookieOptions(t *testing.T) {
+	store := newTestStore(t,
+		WithMaxAge(600),
+		WithPath("/app"),
+		WithDomain("example.com"),
+		WithSecure(true),
+		WithHTTPOnly(true),
+	)
+
+	r := httptest.NewRequest("GET", "/", nil)
+	sess, err := store.New(r, "test")
+	require.NoError(t, err)
+	w := httptest.NewRecorder()
+	require.NoError(t, store.Save(r, w, sess))
+	cookies := w.Result().Cookies()
+	require.Len(t, cookies, 1)
+	assert.Equal(t, 600, cookies[0].MaxAge)
+	assert.Equal(t, "/app", cookies[0].Path)
+	assert.Equal(t, "example.com", cookies[0].Domain)
+	assert.True(t, cookies[0].Secure)
+	assert.True(t, cookies[0].HttpOnly)
+
+	_, err = New(newTestDB(t), nil, WithMaxAge(0))
+	assert.Error(t, err)
+}
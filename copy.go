@@ -0,0 +1,51 @@
+package sqlitestore
+
+import (
+	"context"
+	"strings"
+)
+
+// copyBatchSize is the number of sessions CopySessionsTo reads at a time.
+const copyBatchSize = 100
+
+// CopySessionsTo copies every unexpired session into dst, for example to move
+// to a new database file or to new keys without logging users out. Sessions
+// are decoded with this store's codecs, re-encoded with dst's, and written
+// under their existing IDs with their created_on and expires_on preserved,
+// replacing any row dst already has with the same ID. Sessions that fail to
+// copy are reported to the error handler and skipped. It returns the number
+// of sessions copied.
+func (m *Store) CopySessionsTo(ctx context.Context, dst *Store) (int64, error) {
+	if dst.readOnly {
+		return 0, ErrReadOnly
+	}
+	cols := dst.insertColumns()
+	insQ := "INSERT OR REPLACE INTO " + dst.table + " (id, " + strings.Join(cols, ", ") +
+		") VALUES (?" + strings.Repeat(", ?", len(cols)) + ")"
+
+	var copied int64
+	var afterID int64
+	for {
+		batch, lastID, err := m.LoadBatch(ctx, afterID, copyBatchSize)
+		if err != nil {
+			return copied, err
+		}
+		for _, session := range batch {
+			rowID := m.rowID(session.ID)
+			args, err := dst.insertArgs(session)
+			if err != nil {
+				m.handleError(err)
+				continue
+			}
+			args = append([]interface{}{rowID}, dst.transformArgs(cols, args)...)
+			if _, err := dst.db.ExecContext(ctx, insQ, args...); err != nil {
+				return copied, err
+			}
+			copied++
+		}
+		if lastID == afterID {
+			return copied, nil
+		}
+		afterID = lastID
+	}
+}
@@ -0,0 +1,34 @@
+package sqlitestore
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCopySessionsTo(t *testing.T) {
+	src := newTestStore(t)
+	dst := newTestStore(t)
+
+	var live []string
+	for i := 0; i < 250; i++ {
+		live = append(live, insertTestSession(t, src, map[interface{}]interface{}{"n": i}).ID)
+	}
+	insertTestSession(t, src, map[interface{}]interface{}{"expires_on": time.Now().Add(-time.Hour)})
+
+	n, err := src.CopySessionsTo(context.Background(), dst)
+	require.NoError(t, err)
+	assert.Equal(t, int64(250), n)
+	assert.Equal(t, 250, countRows(t, dst))
+
+	for i, id := range live {
+		orig := loadTestSession(t, src, id)
+		copied := loadTestSession(t, dst, id)
+		assert.Equal(t, i, copied.Values["n"])
+		assert.True(t, orig.Values["created_on"].(time.Time).Equal(copied.Values["created_on"].(time.Time)))
+		assert.True(t, orig.Values["expires_on"].(time.Time).Equal(copied.Values["expires_on"].(time.Time)))
+	}
+}
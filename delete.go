@@ -0,0 +1,91 @@
+package sqlitestore
+
+import (
+	"context"
+	"fmt"
+)
+
+// DeleteAll deletes every session, logging out all users, and returns the
+// number of sessions deleted.
+func (m *Store) DeleteAll() (int64, error) {
+	return m.deleteWhere(context.Background(), "1")
+}
+
+// DeleteByFilter deletes every session whose column col equals val and
+// returns the number of sessions deleted.
+func (m *Store) DeleteByFilter(col string, val interface{}) (int64, error) {
+	if !identifier.MatchString(col) {
+		return 0, fmt.Errorf("sqlitestore: invalid column name %q", col)
+	}
+	return m.deleteWhere(context.Background(), col+" = ?", m.transform(col, val))
+}
+
+// deleteWhere deletes the sessions matching the SQL condition where. The
+// OnDelete hook is called with the ID of each deleted session.
+func (m *Store) deleteWhere(ctx context.Context, where string, args ...interface{}) (n int64, err error) {
+	if m.readOnly {
+		return 0, ErrReadOnly
+	}
+
+	tx, err := m.db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, err
+	}
+	defer func() {
+		if err != nil {
+			tx.Rollback()
+		}
+	}()
+
+	rows, err := tx.QueryContext(ctx, "SELECT id FROM "+m.table+" WHERE "+where, args...)
+	if err != nil {
+		return 0, err
+	}
+	var ids []string
+	for rows.Next() {
+		var id string
+		if err = rows.Scan(&id); err != nil {
+			rows.Close()
+			return 0, err
+		}
+		ids = append(ids, m.prefix+id)
+	}
+	rows.Close()
+	if err = rows.Err(); err != nil {
+		return 0, err
+	}
+
+	res, err := tx.ExecContext(ctx, "DELETE FROM "+m.table+" WHERE "+where, args...)
+	if err != nil {
+		return 0, err
+	}
+	if n, err = res.RowsAffected(); err != nil {
+		return 0, err
+	}
+	if err = tx.Commit(); err != nil {
+		return 0, err
+	}
+	for _, id := range ids {
+		m.evict(id)
+		if m.hasValueIndex() {
+			if err := m.InvalidateValueIndex(id); err != nil {
+				m.handleError(err)
+			}
+		}
+		m.runOnDelete(id)
+	}
+	return n, nil
+}
+
+// evict drops the pending write and cached row of the deleted session id. It
+// holds the session's lock, so a load that read the row before it was deleted
+// can't put it back in the cache afterwards.
+func (m *Store) evict(id string) {
+	if err := m.lock(context.Background(), id); err != nil {
+		m.handleError(err)
+	} else {
+		defer m.locks.Unlock(id)
+	}
+	m.cancelWrite(id)
+	m.cache.remove(id)
+}
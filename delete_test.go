@@ -0,0 +1,61 @@
+package sqlitestore
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDeleteAll(t *testing.T) {
+	store := newTestStore(t)
+	for i := 0; i < 3; i++ {
+		insertTestSession(t, store, nil)
+	}
+	n, err := store.DeleteAll()
+	require.NoError(t, err)
+	assert.Equal(t, int64(3), n)
+	assert.Equal(t, 0, countRows(t, store))
+}
+
+func TestDeleteByFilter(t *testing.T) {
+	store := newTestStore(t)
+	keep := insertTestSession(t, store, nil)
+	drop := insertTestSession(t, store, nil)
+
+	n, err := store.DeleteByFilter("id", drop.ID)
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), n)
+	assert.Equal(t, 1, countRows(t, store))
+	loadTestSession(t, store, keep.ID)
+
+	_, err = store.DeleteByFilter("id = id OR 1", 1)
+	assert.Error(t, err)
+	_, err = store.DeleteByFilter("no_such_column", 1)
+	assert.Error(t, err)
+	assert.Equal(t, 1, countRows(t, store))
+}
+
+func TestDeleteAllShardedCache(t *testing.T) {
+	store := newTestStore(t, WithShardedMutex(16), WithLRUCache(10))
+	sess := insertTestSession(t, store, nil)
+	row, err := store.getRow(context.Background(), sess.ID)
+	require.NoError(t, err)
+
+	// a load on another shard that has read the row and is about to cache it
+	store.locks.RLock(sess.ID)
+	deleted := make(chan error)
+	go func() {
+		_, err := store.DeleteAll()
+		deleted <- err
+	}()
+	time.Sleep(20 * time.Millisecond)
+	store.cache.add(sess.ID, row)
+	store.locks.RUnlock(sess.ID)
+	require.NoError(t, <-deleted)
+
+	_, cached := store.cache.get(sess.ID)
+	assert.False(t, cached)
+}
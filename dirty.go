@@ -0,0 +1,77 @@
+package sqlitestore
+
+import (
+	"bytes"
+	"hash/crc32"
+	"sort"
+	"time"
+
+	"github.com/gorilla/sessions"
+)
+
+// dirtyEntry is the checksum of the values of a session as last loaded or
+// saved, kept while the session is tracked by WithDirtyTracking.
+type dirtyEntry struct {
+	sum       uint32
+	expiresOn time.Time
+}
+
+// valuesChecksum returns a CRC32 of the values of session, leaving out the
+// timestamps load adds. Keys are hashed in a fixed order, as gob encodes maps
+// in iteration order.
+func valuesChecksum(session *sessions.Session) (uint32, error) {
+	entries := make([][]byte, 0, len(session.Values))
+	for k, v := range session.Values {
+		switch k {
+		case "created_on", "modified_on", "expires_on", chunkSnapshot{}:
+			continue
+		}
+		key, err := gobBytes(k)
+		if err != nil {
+			return 0, err
+		}
+		value, err := gobBytes(v)
+		if err != nil {
+			return 0, err
+		}
+		entries = append(entries, append(key, value...))
+	}
+	sort.Slice(entries, func(i, j int) bool { return bytes.Compare(entries[i], entries[j]) < 0 })
+	h := crc32.NewIEEE()
+	for _, e := range entries {
+		h.Write(e)
+	}
+	return h.Sum32(), nil
+}
+
+// trackValues records the checksum of the values of session. Sessions whose
+// values can't be encoded are not tracked and are always written.
+func (m *Store) trackValues(session *sessions.Session, expiresOn time.Time) {
+	sum, err := valuesChecksum(session)
+	if err != nil {
+		m.checksums.Delete(session.ID)
+		return
+	}
+	m.checksums.Store(session.ID, dirtyEntry{sum: sum, expiresOn: expiresOn})
+}
+
+// unchanged reports whether the values of session match the checksum
+// recorded when it was last loaded or saved.
+func (m *Store) unchanged(session *sessions.Session) bool {
+	v, ok := m.checksums.Load(session.ID)
+	if !ok {
+		return false
+	}
+	sum, err := valuesChecksum(session)
+	return err == nil && sum == v.(dirtyEntry).sum
+}
+
+// pruneChecksums forgets the checksums of sessions that expired before now.
+func (m *Store) pruneChecksums(now time.Time) {
+	m.checksums.Range(func(key, value interface{}) bool {
+		if value.(dirtyEntry).expiresOn.Before(now) {
+			m.checksums.Delete(key)
+		}
+		return true
+	})
+}
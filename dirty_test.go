@@ -0,0 +1,56 @@
+package sqlitestore
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDirtyTracking(t *testing.T) {
+	store := newTestStore(t, WithDirtyTracking())
+	clock := &fakeClock{t: time.Date(2020, 1, 1, 12, 0, 0, 0, time.UTC)}
+	store.Clock = clock.Now
+	ctx := context.Background()
+
+	// count the updates that rewrite session_data
+	for _, q := range []string{
+		"CREATE TABLE data_writes (n INTEGER)",
+		"INSERT INTO data_writes VALUES (0)",
+		"CREATE TRIGGER count_data_writes AFTER UPDATE OF session_data ON sessions BEGIN UPDATE data_writes SET n = n + 1; END",
+	} {
+		_, err := store.db.ExecContext(ctx, q)
+		require.NoError(t, err)
+	}
+	writes := func() int {
+		var n int
+		require.NoError(t, store.db.QueryRowContext(ctx, "SELECT n FROM data_writes").Scan(&n))
+		return n
+	}
+
+	sess := insertTestSession(t, store, map[interface{}]interface{}{"name": "alice", "n": 1})
+	clock.Advance(time.Minute)
+	loaded := loadTestSession(t, store, sess.ID)
+	loaded.IsNew = false
+	require.NoError(t, store.save(ctx, loaded))
+	assert.Equal(t, 0, writes())
+
+	// the expiry still slides
+	row, err := store.getRow(ctx, sess.ID)
+	require.NoError(t, err)
+	assert.True(t, clock.t.Add(time.Hour).Equal(row.expiresOn))
+
+	loaded = loadTestSession(t, store, sess.ID)
+	loaded.IsNew = false
+	loaded.Values["n"] = 2
+	require.NoError(t, store.save(ctx, loaded))
+	assert.Equal(t, 1, writes())
+	require.NoError(t, store.save(ctx, loaded))
+	assert.Equal(t, 1, writes())
+
+	store.TrackDirty = false
+	require.NoError(t, store.save(ctx, loaded))
+	assert.Equal(t, 2, writes())
+}
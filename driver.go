@@ -0,0 +1,114 @@
+package sqlitestore
+
+import (
+	"database/sql"
+	"strconv"
+	"strings"
+	"sync"
+
+	sqlite3 "github.com/mattn/go-sqlite3"
+)
+
+const defaultDriverName = "sqlite3"
+
+// registeredDrivers tracks driver names registered by this package so that
+// stores sharing a name only register it once.
+var registeredDrivers sync.Map
+
+// registerDriver registers the SQLite driver under name unless a driver by
+// that name already exists.
+func registerDriver(name string) {
+	if _, loaded := registeredDrivers.LoadOrStore(name, true); loaded {
+		return
+	}
+	for _, d := range sql.Drivers() {
+		if d == name {
+			return
+		}
+	}
+	sql.Register(name, &sqlite3.SQLiteDriver{})
+}
+
+// Open opens the SQLite database at path and creates a store on it. The
+// database is closed again if the store cannot be created.
+func Open(path string, keyPairs [][]byte, opts ...StoreOption) (*Store, error) {
+	cfg := defaultConfig()
+	for _, opt := range opts {
+		if err := opt(cfg); err != nil {
+			return nil, err
+		}
+	}
+	if cfg.driverName != defaultDriverName {
+		registerDriver(cfg.driverName)
+	}
+	if cfg.readOnly {
+		path = readOnlyDSN(path)
+	}
+	if cfg.walMode && !cfg.readOnly {
+		path = appendDSNParam(path, "_journal_mode=WAL")
+	}
+	if cfg.busyTimeout > 0 {
+		path = appendDSNParam(path, "_busy_timeout="+strconv.FormatInt(cfg.busyTimeout.Milliseconds(), 10))
+	}
+	db, err := sql.Open(cfg.driverName, path)
+	if err != nil {
+		return nil, err
+	}
+	if cfg.onOpen != nil {
+		if err := cfg.onOpen(db); err != nil {
+			db.Close()
+			return nil, err
+		}
+	}
+	store, err := New(db, keyPairs, opts...)
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+	return store, nil
+}
+
+// NewStoreFromPath opens the SQLite database at path and creates a store on
+// it like NewStore. Every connection uses WAL journaling and a 5 second busy
+// timeout, which concurrent request handlers need to avoid "database is
+// locked" errors. The database is closed again if the store cannot be
+// created.
+func NewStoreFromPath(path string, keyPairs ...[]byte) (*Store, error) {
+	path = appendDSNParam(path, "_journal_mode=WAL")
+	return Open(appendDSNParam(path, "_busy_timeout=5000"), keyPairs)
+}
+
+// NewMemoryStore creates a store on a new in-memory database, for tests. An
+// in-memory database lives only as long as the connection that created it,
+// so the pool is limited to that one connection, and every store gets its
+// own database. Closing the store discards it.
+func NewMemoryStore(keyPairs ...[]byte) (*Store, error) {
+	db, err := sql.Open(defaultDriverName, ":memory:")
+	if err != nil {
+		return nil, err
+	}
+	db.SetMaxOpenConns(1)
+	db.SetConnMaxLifetime(0)
+	store, err := NewStore(db, keyPairs)
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+	return store, nil
+}
+
+// readOnlyDSN turns path into a URI filename opened with mode=ro.
+func readOnlyDSN(path string) string {
+	if !strings.HasPrefix(path, "file:") {
+		path = "file:" + path
+	}
+	return appendDSNParam(path, "mode=ro")
+}
+
+// appendDSNParam adds the query parameter param to dsn.
+func appendDSNParam(dsn, param string) string {
+	if strings.Contains(dsn, "?") {
+		return dsn + "&" + param
+	}
+	return dsn + "?" + param
+}
@@ -0,0 +1,178 @@
+package sqlitestore
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"net/http/httptest"
+	"path/filepath"
+	"sync"
+	"testing"
+
+	"github.com/gorilla/securecookie"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCustomDriverName(t *testing.T) {
+	var stores []*Store
+	for _, name := range []string{"sqlitestore_a", "sqlitestore_b", "sqlitestore_a"} {
+		store, err := Open(tempDBPath(t), [][]byte{securecookie.GenerateRandomKey(32)}, WithCustomDriverName(name))
+		require.NoError(t, err)
+		defer store.Close()
+		assert.Contains(t, sql.Drivers(), name)
+		stores = append(stores, store)
+	}
+
+	var wg sync.WaitGroup
+	for _, store := range stores {
+		wg.Add(1)
+		go func(store *Store) {
+			defer wg.Done()
+			for i := 0; i < 20; i++ {
+				r := httptest.NewRequest("GET", "/", nil)
+				sess, err := store.New(r, "test")
+				assert.NoError(t, err)
+				assert.NoError(t, store.Save(r, httptest.NewRecorder(), sess))
+			}
+		}(store)
+	}
+	wg.Wait()
+	for _, store := range stores {
+		assert.Equal(t, 20, countRows(t, store))
+	}
+}
+
+func TestReadOnly(t *testing.T) {
+	path := tempDBPath(t)
+	key := securecookie.GenerateRandomKey(32)
+	writer, err := Open(path, [][]byte{key})
+	require.NoError(t, err)
+	defer writer.Close()
+
+	r := httptest.NewRequest("GET", "/", nil)
+	sess, err := writer.New(r, "test")
+	require.NoError(t, err)
+	sess.Values["foo"] = "bar"
+	w := httptest.NewRecorder()
+	require.NoError(t, writer.Save(r, w, sess))
+
+	reader, err := Open(path, [][]byte{key}, WithReadOnly())
+	require.NoError(t, err)
+	defer reader.Close()
+
+	r2 := httptest.NewRequest("GET", "/", nil)
+	r2.Header.Add("Cookie", w.Header().Get("Set-Cookie"))
+	sess2, err := reader.New(r2, "test")
+	require.NoError(t, err)
+	assert.False(t, sess2.IsNew)
+	assert.Equal(t, "bar", sess2.Values["foo"])
+
+	assert.Equal(t, ErrReadOnly, reader.Save(r2, httptest.NewRecorder(), sess2))
+	assert.Equal(t, ErrReadOnly, reader.Delete(r2, httptest.NewRecorder(), sess2))
+	_, err = reader.db.ExecContext(context.Background(), "DELETE FROM sessions")
+	assert.Error(t, err)
+}
+
+func TestReadOnlyMaintenance(t *testing.T) {
+	path := tempDBPath(t)
+	key := securecookie.GenerateRandomKey(32)
+	writer, err := Open(path, [][]byte{key}, WithSessionTagging(), WithUserIDColumn())
+	require.NoError(t, err)
+	id := insertTestSession(t, writer, map[interface{}]interface{}{UserIDKey: "u1"}).ID
+	writer.Close()
+
+	reader, err := Open(path, [][]byte{key}, WithReadOnly(), WithSessionTagging(), WithUserIDColumn())
+	require.NoError(t, err)
+	defer reader.Close()
+	ctx := context.Background()
+
+	_, err = reader.DeleteExpiredInBatches(ctx, 10, 0)
+	assert.Equal(t, ErrReadOnly, err)
+	_, err = reader.GarbageCollect(ctx)
+	assert.Equal(t, ErrReadOnly, err)
+	assert.Equal(t, ErrReadOnly, reader.TagSession(ctx, id, "beta"))
+	assert.Equal(t, ErrReadOnly, reader.UntagSession(ctx, id, "beta"))
+	_, err = reader.AnonymizeSessions(ctx, "u1")
+	assert.Equal(t, ErrReadOnly, err)
+}
+
+func TestConnectionLifecycleHooks(t *testing.T) {
+	var opened, closed *sql.DB
+	onOpen := func(db *sql.DB) error {
+		opened = db
+		_, err := db.Exec("PRAGMA user_version = 7")
+		return err
+	}
+	onClose := func(db *sql.DB) error {
+		closed = db
+		return db.Ping()
+	}
+	store, err := Open(tempDBPath(t), [][]byte{securecookie.GenerateRandomKey(32)},
+		WithConnectionLifecycleHooks(onOpen, onClose))
+	require.NoError(t, err)
+	require.NotNil(t, opened)
+	version, err := store.GetSchemaVersion(context.Background())
+	require.NoError(t, err)
+	assert.True(t, version >= 7)
+
+	store.Close()
+	assert.Equal(t, opened, closed)
+
+	failing := func(*sql.DB) error { return errors.New("extension not found") }
+	_, err = Open(tempDBPath(t), [][]byte{securecookie.GenerateRandomKey(32)},
+		WithConnectionLifecycleHooks(failing, nil))
+	assert.EqualError(t, err, "extension not found")
+}
+
+func TestNewStoreFromPath(t *testing.T) {
+	store, err := NewStoreFromPath(tempDBPath(t), securecookie.GenerateRandomKey(32))
+	require.NoError(t, err)
+	defer store.Close()
+	ctx := context.Background()
+
+	mode, err := store.GetPragma(ctx, "journal_mode")
+	require.NoError(t, err)
+	assert.Equal(t, "wal", mode)
+	timeout, err := store.GetPragma(ctx, "busy_timeout")
+	require.NoError(t, err)
+	assert.Equal(t, "5000", timeout)
+
+	id := insertTestSession(t, store, map[interface{}]interface{}{"n": 1}).ID
+	assert.Equal(t, 1, loadTestSession(t, store, id).Values["n"])
+
+	_, err = NewStoreFromPath(filepath.Join(tempDBPath(t), "missing", "test.db"), securecookie.GenerateRandomKey(32))
+	assert.Error(t, err)
+}
+
+func ExampleNewMemoryStore() {
+	store, err := NewMemoryStore(securecookie.GenerateRandomKey(32))
+	if err != nil {
+		panic(err)
+	}
+	defer store.Close()
+
+	r := httptest.NewRequest("GET", "/", nil)
+	session, _ := store.New(r, "example")
+	session.Values["user"] = "alice"
+	if err := store.Save(r, httptest.NewRecorder(), session); err != nil {
+		panic(err)
+	}
+	n, _ := store.CountActive()
+	fmt.Println(n)
+	// Output: 1
+}
+
+func TestNewMemoryStore(t *testing.T) {
+	a, err := NewMemoryStore(securecookie.GenerateRandomKey(32))
+	require.NoError(t, err)
+	defer a.Close()
+	b, err := NewMemoryStore(securecookie.GenerateRandomKey(32))
+	require.NoError(t, err)
+	defer b.Close()
+
+	insertTestSession(t, a, nil)
+	assert.Equal(t, 1, countRows(t, a))
+	assert.Equal(t, 0, countRows(t, b))
+}
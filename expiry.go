@@ -0,0 +1,320 @@
+package sqlitestore
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/gorilla/sessions"
+)
+
+func (m *Store) clock() time.Time {
+	if m.Clock != nil {
+		return m.Clock()
+	}
+	return time.Now()
+}
+
+// slideExpiry moves the expiry of a freshly loaded row forward by the sliding
+// window, capped at the absolute lifetime measured from creation.
+func (m *Store) slideExpiry(ctx context.Context, sess *sessionRow) error {
+	expiresOn := m.clock().Add(m.sliding)
+	if ceiling := sess.createdOn.Add(m.absolute); ceiling.Before(expiresOn) {
+		expiresOn = ceiling
+	}
+	expiresOn = m.capExpiry(sess.createdOn, expiresOn)
+	if !expiresOn.After(sess.expiresOn) {
+		return nil
+	}
+	start := time.Now()
+	_, err := m.db.ExecContext(ctx, "UPDATE "+m.table+" SET expires_on = ? WHERE id = ?", m.transform("expires_on", expiresOn), sess.id)
+	m.observeQuery("slide", "", start, err)
+	if err != nil {
+		return err
+	}
+	sess.expiresOn = expiresOn
+	return nil
+}
+
+// capExpiry limits expiresOn to the maximum lifetime set with
+// WithAbsoluteExpiry, measured from createdOn.
+func (m *Store) capExpiry(createdOn, expiresOn time.Time) time.Time {
+	if m.maxLifetime <= 0 {
+		return expiresOn
+	}
+	if ceiling := createdOn.Add(m.maxLifetime); ceiling.Before(expiresOn) {
+		return ceiling
+	}
+	return expiresOn
+}
+
+// Touch moves the expiry of session to MaxAge from now without re-encoding
+// its values. It returns ErrSessionNotFound if the session has no unexpired
+// row. The new expiry is still capped by WithAbsoluteExpiry.
+func (m *Store) Touch(session *sessions.Session) error {
+	if session.ID == "" {
+		return ErrSessionNotSaved
+	}
+	if m.readOnly {
+		return ErrReadOnly
+	}
+	if err := m.checkConn(); err != nil {
+		return err
+	}
+	ctx := context.Background()
+	if err := m.lock(ctx, session.ID); err != nil {
+		return err
+	}
+	defer m.locks.Unlock(session.ID)
+	// a pending coalesced write would overwrite the new expiry
+	if err := m.flushWrite(ctx, session.ID); err != nil {
+		return err
+	}
+
+	now := m.clock()
+	expiresOn := now.Add(time.Second * time.Duration(session.Options.MaxAge))
+	if createdOn, ok := session.Values["created_on"].(time.Time); ok {
+		expiresOn = m.capExpiry(createdOn, expiresOn)
+	}
+	res, err := m.db.ExecContext(ctx, "UPDATE "+m.table+" SET expires_on = ?, modified_on = ? WHERE id = ? AND expires_on > ?",
+		m.transform("expires_on", expiresOn), m.transform("modified_on", now), m.rowID(session.ID), m.transform("expires_on", now))
+	m.cache.remove(session.ID)
+	if err != nil {
+		return err
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return ErrSessionNotFound
+	}
+	session.Values["expires_on"] = expiresOn
+	session.Values["modified_on"] = now
+	return nil
+}
+
+// LoadWithTTLExtension loads session like Get does and pushes its expiry
+// extension further out, in one transaction, so no other writer can expire
+// or delete the row between the read and the extension. The new expiry is
+// still capped by WithAbsoluteExpiry. An expired session is left untouched
+// and SessionExpired is returned.
+func (m *Store) LoadWithTTLExtension(ctx context.Context, session *sessions.Session, extension time.Duration) (err error) {
+	if m.readOnly {
+		return ErrReadOnly
+	}
+	if err := m.checkConn(); err != nil {
+		return err
+	}
+	if err := m.lock(ctx, session.ID); err != nil {
+		return err
+	}
+	defer m.locks.Unlock(session.ID)
+	// write any coalesced update first so the row read below is current
+	if err := m.flushWrite(ctx, session.ID); err != nil {
+		return err
+	}
+
+	tx, err := m.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err != nil {
+			tx.Rollback()
+		}
+	}()
+
+	var sess sessionRow
+	if err = m.scanSession(tx.StmtContext(ctx, m.get).QueryRowContext(ctx, m.rowID(session.ID)), &sess); err != nil {
+		return err
+	}
+	now := m.clock()
+	if m.capExpiry(sess.createdOn, sess.expiresOn).Before(now) {
+		m.runOnExpire(session.ID)
+		return SessionExpired
+	}
+	if err = m.decodeValues(session, sess.data); err != nil {
+		return err
+	}
+	if m.chunked {
+		if err = m.readChunks(ctx, tx, session); err != nil {
+			return err
+		}
+	}
+	expiresOn := m.capExpiry(sess.createdOn, sess.expiresOn.Add(extension))
+	_, err = tx.ExecContext(ctx, "UPDATE "+m.table+" SET expires_on = ?, modified_on = ? WHERE id = ?",
+		m.transform("expires_on", expiresOn), m.transform("modified_on", now), sess.id)
+	if err != nil {
+		return err
+	}
+	err = tx.Commit()
+	m.cache.remove(session.ID)
+	if err != nil {
+		return err
+	}
+	session.Values["created_on"] = sess.createdOn
+	session.Values["modified_on"] = now
+	session.Values["expires_on"] = expiresOn
+	session.IsNew = false
+	return nil
+}
+
+// ResetExpiryForAll recomputes the expiry of every unexpired session as its
+// creation time plus the store's current Options.MaxAge, for example after
+// MaxAge was raised, capped by WithAbsoluteExpiry. Expired sessions are left
+// alone. It returns the number of sessions updated.
+func (m *Store) ResetExpiryForAll(ctx context.Context) (n int64, err error) {
+	if m.readOnly {
+		return 0, ErrReadOnly
+	}
+	if err := m.checkConn(); err != nil {
+		return 0, err
+	}
+	// pending coalesced writes would overwrite the new expiries
+	if err := m.flushWrites(ctx); err != nil {
+		return 0, err
+	}
+	defer m.cache.purge()
+
+	tx, err := m.db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, err
+	}
+	defer func() {
+		if err != nil {
+			tx.Rollback()
+			n = 0
+		}
+	}()
+
+	rows, err := tx.QueryContext(ctx, "SELECT id, created_on FROM "+m.table+" WHERE expires_on > ?",
+		m.transform("expires_on", m.clock()))
+	if err != nil {
+		return 0, err
+	}
+	type reset struct {
+		id        string
+		createdOn time.Time
+	}
+	var resets []reset
+	for rows.Next() {
+		var r reset
+		var raw interface{}
+		if err = rows.Scan(&r.id, &raw); err != nil {
+			rows.Close()
+			return 0, err
+		}
+		if r.createdOn, err = parseTime("created_on", m.untransform("created_on", raw)); err != nil {
+			rows.Close()
+			return 0, err
+		}
+		resets = append(resets, r)
+	}
+	rows.Close()
+	if err = rows.Err(); err != nil {
+		return 0, err
+	}
+
+	maxAge := time.Duration(m.Options.MaxAge) * time.Second
+	stmt, err := tx.PrepareContext(ctx, "UPDATE "+m.table+" SET expires_on = ? WHERE id = ?")
+	if err != nil {
+		return 0, err
+	}
+	defer stmt.Close()
+	for _, r := range resets {
+		expiresOn := m.capExpiry(r.createdOn, r.createdOn.Add(maxAge))
+		if _, err = stmt.ExecContext(ctx, m.transform("expires_on", expiresOn), r.id); err != nil {
+			return 0, err
+		}
+	}
+	if err = tx.Commit(); err != nil {
+		return 0, err
+	}
+	return int64(len(resets)), nil
+}
+
+// BatchRenewExpiry pushes the expiry of each listed session extension further
+// out, for keep-alive endpoints that refresh many sessions at once. The new
+// expiry is still capped by WithAbsoluteExpiry. Sessions that have already
+// expired, or don't exist, are not revived. It returns the number of sessions
+// extended.
+func (m *Store) BatchRenewExpiry(ctx context.Context, sessionIDs []string, extension time.Duration) (int64, error) {
+	if m.readOnly {
+		return 0, ErrReadOnly
+	}
+	if err := m.checkConn(); err != nil {
+		return 0, err
+	}
+	var n int64
+	for _, id := range sessionIDs {
+		renewed, err := m.renewExpiry(ctx, id, extension)
+		if err != nil {
+			return n, err
+		}
+		if renewed {
+			n++
+		}
+	}
+	return n, nil
+}
+
+// renewExpiry pushes the expiry of the session id extension further out and
+// reports whether it had an unexpired row to extend.
+func (m *Store) renewExpiry(ctx context.Context, id string, extension time.Duration) (bool, error) {
+	if err := m.lock(ctx, id); err != nil {
+		return false, err
+	}
+	defer m.locks.Unlock(id)
+	// a coalesced write still pending would overwrite the new expiry
+	if err := m.flushWrite(ctx, id); err != nil {
+		return false, err
+	}
+	defer m.cache.remove(id)
+
+	sess, err := m.getRow(ctx, id)
+	if err == sql.ErrNoRows {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	now := m.clock()
+	if !sess.expiresOn.After(now) {
+		return false, nil
+	}
+	expiresOn := m.capExpiry(sess.createdOn, sess.expiresOn.Add(extension))
+	res, err := m.db.ExecContext(ctx, "UPDATE "+m.table+" SET expires_on = ?, modified_on = ? WHERE id = ? AND expires_on > ?",
+		m.transform("expires_on", expiresOn), m.transform("modified_on", now), m.rowID(id), m.transform("expires_on", now))
+	if err != nil {
+		return false, err
+	}
+	affected, err := res.RowsAffected()
+	return affected > 0, err
+}
+
+// GetOldestSession returns the unexpired session created first, or nil if
+// there is none. A very old result suggests expired sessions are not being
+// collected.
+func (m *Store) GetOldestSession(ctx context.Context) (*sessionRow, error) {
+	return m.sessionByAge(ctx, "ASC")
+}
+
+// GetNewestSession returns the unexpired session created last, or nil if
+// there is none.
+func (m *Store) GetNewestSession(ctx context.Context) (*sessionRow, error) {
+	return m.sessionByAge(ctx, "DESC")
+}
+
+func (m *Store) sessionByAge(ctx context.Context, order string) (*sessionRow, error) {
+	row := m.db.QueryRowContext(ctx, "SELECT id, session_data, created_on, modified_on, expires_on FROM "+m.table+
+		" WHERE expires_on > ? ORDER BY created_on "+order+" LIMIT 1", m.transform("expires_on", m.clock()))
+	var sess sessionRow
+	if err := m.scanSession(row, &sess); err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &sess, nil
+}
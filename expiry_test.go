@@ -0,0 +1,265 @@
+package sqlitestore
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/gorilla/sessions"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type fakeClock struct {
+	t time.Time
+}
+
+func (c *fakeClock) Now() time.Time          { return c.t }
+func (c *fakeClock) Advance(d time.Duration) { c.t = c.t.Add(d) }
+
+func TestSlidingExpiration(t *testing.T) {
+	store := newTestStore(t, WithSlidingExpiration(10*time.Minute, 30*time.Minute))
+	clock := &fakeClock{t: time.Date(2020, 1, 1, 12, 0, 0, 0, time.UTC)}
+	store.Clock = clock.Now
+	start := clock.t
+
+	sess := insertTestSession(t, store, map[interface{}]interface{}{
+		"created_on": start,
+		"expires_on": start.Add(10 * time.Minute),
+	})
+
+	steps := []struct {
+		advance time.Duration
+		expires time.Duration
+	}{
+		{5 * time.Minute, 15 * time.Minute},
+		{9 * time.Minute, 24 * time.Minute},
+		{9 * time.Minute, 30 * time.Minute},
+		{6 * time.Minute, 30 * time.Minute},
+	}
+	for _, step := range steps {
+		clock.Advance(step.advance)
+		loaded := sessions.NewSession(store, "test")
+		loaded.ID = sess.ID
+		require.NoError(t, store.load(context.Background(), loaded))
+		assert.True(t, start.Add(step.expires).Equal(loaded.Values["expires_on"].(time.Time)),
+			"at %s expected expiry %s, got %s", clock.t.Sub(start), step.expires, loaded.Values["expires_on"].(time.Time).Sub(start))
+	}
+
+	clock.Advance(2 * time.Minute)
+	loaded := sessions.NewSession(store, "test")
+	loaded.ID = sess.ID
+	assert.Equal(t, SessionExpired, store.load(context.Background(), loaded))
+}
+
+func TestAbsoluteExpiry(t *testing.T) {
+	maxLifetime := 2 * time.Hour
+	store := newTestStore(t, WithAbsoluteExpiry(maxLifetime))
+	clock := &fakeClock{t: time.Date(2020, 1, 1, 12, 0, 0, 0, time.UTC)}
+	store.Clock = clock.Now
+	start := clock.t
+
+	sess := insertTestSession(t, store, nil)
+	maxAge := time.Duration(sess.Options.MaxAge) * time.Second
+
+	accesses := int(maxLifetime/maxAge) + 2
+	for i := 0; i < accesses; i++ {
+		// every access lands well inside MaxAge of the previous save
+		clock.Advance(50 * time.Minute)
+		loaded := sessions.NewSession(store, "test")
+		loaded.ID = sess.ID
+		loaded.Options = sess.Options
+		err := store.load(context.Background(), loaded)
+		if clock.t.Sub(start) > maxLifetime {
+			assert.Equal(t, SessionExpired, err, "access %d", i)
+			return
+		}
+		require.NoError(t, err)
+		require.NoError(t, store.save(context.Background(), loaded))
+	}
+	t.Fatal("session outlived its maximum lifetime")
+}
+
+func TestFixedExpiry(t *testing.T) {
+	store := newTestStore(t)
+	store.SlidingExpiry = false
+	clock := &fakeClock{t: time.Date(2020, 1, 1, 12, 0, 0, 0, time.UTC)}
+	store.Clock = clock.Now
+
+	sess := sessions.NewSession(store, "test")
+	sess.Options = &sessions.Options{MaxAge: 5}
+	require.NoError(t, store.insert(context.Background(), sess))
+
+	clock.Advance(3 * time.Second)
+	loaded := sessions.NewSession(store, "test")
+	loaded.ID = sess.ID
+	loaded.Options = sess.Options
+	require.NoError(t, store.load(context.Background(), loaded))
+	require.NoError(t, store.save(context.Background(), loaded))
+
+	clock.Advance(3 * time.Second)
+	loaded = sessions.NewSession(store, "test")
+	loaded.ID = sess.ID
+	loaded.Options = sess.Options
+	assert.Equal(t, SessionExpired, store.load(context.Background(), loaded))
+}
+
+func TestLoadWithTTLExtension(t *testing.T) {
+	store := newTestStore(t)
+	clock := &fakeClock{t: time.Date(2020, 1, 1, 12, 0, 0, 0, time.UTC)}
+	store.Clock = clock.Now
+	start := clock.t
+
+	sess := insertTestSession(t, store, map[interface{}]interface{}{"foo": "bar"})
+	clock.Advance(10 * time.Minute)
+
+	loaded := sessions.NewSession(store, "test")
+	loaded.ID = sess.ID
+	require.NoError(t, store.LoadWithTTLExtension(context.Background(), loaded, 30*time.Minute))
+	assert.Equal(t, "bar", loaded.Values["foo"])
+	assert.True(t, start.Add(90*time.Minute).Equal(loaded.Values["expires_on"].(time.Time)))
+
+	// the extension was committed together with the read
+	row := loadTestSession(t, store, sess.ID)
+	assert.True(t, start.Add(90*time.Minute).Equal(row.Values["expires_on"].(time.Time)))
+	assert.True(t, clock.t.Equal(row.Values["modified_on"].(time.Time)))
+
+	clock.Advance(2 * time.Hour)
+	expired := sessions.NewSession(store, "test")
+	expired.ID = sess.ID
+	assert.Equal(t, SessionExpired, store.LoadWithTTLExtension(context.Background(), expired, time.Hour))
+	clock.t = start
+	assert.True(t, start.Add(90*time.Minute).Equal(loadTestSession(t, store, sess.ID).Values["expires_on"].(time.Time)))
+}
+
+func TestResetExpiryForAll(t *testing.T) {
+	store := newTestStore(t)
+	store.Options.MaxAge = 3600
+	var ids []string
+	for i := 0; i < 3; i++ {
+		ids = append(ids, insertTestSession(t, store, nil).ID)
+	}
+	expired := insertTestSession(t, store, map[interface{}]interface{}{"expires_on": time.Now().Add(-time.Hour)})
+
+	store.Options.MaxAge += 24 * 3600
+	n, err := store.ResetExpiryForAll(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, int64(3), n)
+
+	for _, id := range ids {
+		sess := loadTestSession(t, store, id)
+		createdOn := sess.Values["created_on"].(time.Time)
+		assert.WithinDuration(t, createdOn.Add(25*time.Hour), sess.Values["expires_on"].(time.Time), time.Second)
+	}
+	var expiresOn time.Time
+	require.NoError(t, store.db.QueryRowContext(context.Background(),
+		"SELECT expires_on FROM sessions WHERE id = ?", expired.ID).Scan(&expiresOn))
+	assert.True(t, expiresOn.Before(time.Now()))
+}
+
+func TestResetExpiryForAllUnixTimestamps(t *testing.T) {
+	store := newTestStore(t, WithColumnTransformer(UnixTimestampTransformer{}),
+		WithLRUCache(10), WithAbsoluteExpiry(12*time.Hour))
+	store.Options.MaxAge = 3600
+	sess := insertTestSession(t, store, nil)
+	loadTestSession(t, store, sess.ID)
+
+	store.Options.MaxAge = 24 * 3600
+	n, err := store.ResetExpiryForAll(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), n)
+
+	loaded := loadTestSession(t, store, sess.ID)
+	createdOn := loaded.Values["created_on"].(time.Time)
+	assert.Equal(t, createdOn.Add(12*time.Hour).Unix(), loaded.Values["expires_on"].(time.Time).Unix())
+	exists, err := store.Exists(sess.ID)
+	require.NoError(t, err)
+	assert.True(t, exists)
+}
+
+func TestOldestAndNewestSession(t *testing.T) {
+	store := newTestStore(t)
+	ctx := context.Background()
+
+	oldest, err := store.GetOldestSession(ctx)
+	require.NoError(t, err)
+	assert.Nil(t, oldest)
+
+	now := time.Now()
+	insertTestSession(t, store, map[interface{}]interface{}{
+		"created_on": now.Add(-72 * time.Hour),
+		"expires_on": now.Add(-time.Hour),
+	})
+	old := insertTestSession(t, store, map[interface{}]interface{}{"created_on": now.Add(-48 * time.Hour)})
+	insertTestSession(t, store, map[interface{}]interface{}{"created_on": now.Add(-24 * time.Hour)})
+	newest := insertTestSession(t, store, nil)
+
+	oldest, err = store.GetOldestSession(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, old.ID, oldest.ID())
+	assert.WithinDuration(t, now.Add(-48*time.Hour), oldest.CreatedOn(), time.Second)
+
+	row, err := store.GetNewestSession(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, newest.ID, row.ID())
+}
+
+func TestBatchRenewExpiry(t *testing.T) {
+	store := newTestStore(t)
+	now := time.Now()
+	live := insertTestSession(t, store, nil)
+	other := insertTestSession(t, store, nil)
+	expired := insertTestSession(t, store, map[interface{}]interface{}{"expires_on": now.Add(-time.Hour)})
+	untouched := insertTestSession(t, store, nil)
+
+	n, err := store.BatchRenewExpiry(context.Background(), []string{live.ID, other.ID, expired.ID}, 2*time.Hour)
+	require.NoError(t, err)
+	assert.Equal(t, int64(2), n)
+
+	for _, id := range []string{live.ID, other.ID} {
+		sess := loadTestSession(t, store, id)
+		assert.WithinDuration(t, now.Add(3*time.Hour), sess.Values["expires_on"].(time.Time), 2*time.Second)
+	}
+	sess := loadTestSession(t, store, untouched.ID)
+	assert.WithinDuration(t, now.Add(time.Hour), sess.Values["expires_on"].(time.Time), 2*time.Second)
+
+	var expiresOn time.Time
+	require.NoError(t, store.db.QueryRowContext(context.Background(),
+		"SELECT expires_on FROM sessions WHERE id = ?", expired.ID).Scan(&expiresOn))
+	assert.True(t, expiresOn.Before(now))
+}
+
+func TestBatchRenewExpiryUnixTimestamps(t *testing.T) {
+	store := newTestStore(t, WithColumnTransformer(UnixTimestampTransformer{}), WithLRUCache(10))
+	clock := &fakeClock{t: time.Date(2020, 1, 1, 12, 0, 0, 0, time.UTC)}
+	store.Clock = clock.Now
+	sess := insertTestSession(t, store, nil)
+	expiresOn := loadTestSession(t, store, sess.ID).Values["expires_on"].(time.Time)
+
+	n, err := store.BatchRenewExpiry(context.Background(), []string{sess.ID, "12345"}, time.Hour)
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), n)
+	assert.Equal(t, expiresOn.Add(time.Hour).Unix(), loadTestSession(t, store, sess.ID).Values["expires_on"].(time.Time).Unix())
+}
+
+func TestTouch(t *testing.T) {
+	store := newTestStore(t)
+	clock := &fakeClock{t: time.Date(2020, 1, 1, 12, 0, 0, 0, time.UTC)}
+	store.Clock = clock.Now
+	ctx := context.Background()
+
+	sess := insertTestSession(t, store, map[interface{}]interface{}{"name": "alice"})
+	data := rawSessionData(t, store, sess.ID)
+	clock.Advance(30 * time.Minute)
+	require.NoError(t, store.Touch(sess))
+
+	row, err := store.getRow(ctx, sess.ID)
+	require.NoError(t, err)
+	assert.True(t, clock.t.Add(time.Hour).Equal(row.expiresOn))
+	assert.True(t, clock.t.Equal(row.modifiedOn))
+	assert.Equal(t, data, rawSessionData(t, store, sess.ID))
+
+	clock.Advance(2 * time.Hour)
+	assert.Equal(t, ErrSessionNotFound, store.Touch(sess))
+	assert.Equal(t, ErrSessionNotSaved, store.Touch(sessions.NewSession(store, "test")))
+}
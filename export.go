@@ -0,0 +1,274 @@
+package sqlitestore
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+)
+
+// ExportRecord is a session row as written by the JSON export methods, one
+// record per line. Data holds the raw session_data and is base64 encoded in
+// JSON.
+type ExportRecord struct {
+	ID         string    `json:"id"`
+	Name       string    `json:"name"`
+	Data       []byte    `json:"session_data"`
+	CreatedOn  time.Time `json:"created_on"`
+	ModifiedOn time.Time `json:"modified_on"`
+	ExpiresOn  time.Time `json:"expires_on"`
+}
+
+// StreamExportJSON writes every session to w as newline-delimited JSON,
+// reading batchSize rows at a time so memory use stays flat. w is flushed
+// after each batch if it supports flushing, and progress, when not nil,
+// receives the running row count. It returns the number of rows written.
+func (m *Store) StreamExportJSON(ctx context.Context, w io.Writer, batchSize int, progress chan<- int64) (int64, error) {
+	if batchSize <= 0 {
+		return 0, errors.New("sqlitestore: batch size must be positive")
+	}
+	enc := json.NewEncoder(w)
+	var total int64
+	var lastID *string
+	for {
+		if err := ctx.Err(); err != nil {
+			return total, err
+		}
+		batch, err := m.exportBatch(ctx, batchSize, lastID)
+		if err != nil {
+			return total, err
+		}
+		if len(batch) > 0 {
+			lastID = &batch[len(batch)-1].ID
+		}
+		for _, rec := range batch {
+			if err := enc.Encode(rec); err != nil {
+				return total, err
+			}
+			total++
+		}
+		if err := flush(w); err != nil {
+			return total, err
+		}
+		if progress != nil && len(batch) > 0 {
+			progress <- total
+		}
+		if len(batch) < batchSize {
+			return total, nil
+		}
+	}
+}
+
+// exportBatch reads up to limit rows after lastID, in id order.
+func (m *Store) exportBatch(ctx context.Context, limit int, lastID *string) ([]ExportRecord, error) {
+	after, args := afterID(lastID)
+	rows, err := m.db.QueryContext(ctx, "SELECT id, name, session_data, created_on, modified_on, expires_on FROM "+
+		m.table+" WHERE "+after+" ORDER BY id LIMIT ?", append(args, limit)...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var batch []ExportRecord
+	for rows.Next() {
+		var rec ExportRecord
+		if err := rows.Scan(&rec.ID, &rec.Name, &rec.Data, &rec.CreatedOn, &rec.ModifiedOn, &rec.ExpiresOn); err != nil {
+			return nil, err
+		}
+		batch = append(batch, rec)
+	}
+	return batch, rows.Err()
+}
+
+func flush(w io.Writer) error {
+	switch f := w.(type) {
+	case interface{ Flush() error }:
+		return f.Flush()
+	case http.Flusher:
+		f.Flush()
+	}
+	return nil
+}
+
+// warmupBatchSize is the number of sessions WarmupFromJSON writes per
+// transaction.
+const warmupBatchSize = 100
+
+type progressKey struct{}
+
+// ContextWithProgress returns a copy of ctx that makes WarmupFromJSON send
+// its running import count to progress after each batch.
+func ContextWithProgress(ctx context.Context, progress chan<- int64) context.Context {
+	return context.WithValue(ctx, progressKey{}, progress)
+}
+
+// WarmupFromJSON imports sessions from newline-delimited JSON in the format
+// written by StreamExportJSON, for example to fill a freshly deployed
+// instance. Sessions keep their IDs, so existing cookies stay valid, and
+// replace any row with the same ID. Records that are malformed, have no ID
+// or carry invalid base64 session data are reported to the error handler and
+// skipped; expired sessions are skipped silently. Rows are written in
+// batched transactions, with progress reported through a channel attached
+// using ContextWithProgress. It returns the number of sessions imported.
+func (m *Store) WarmupFromJSON(ctx context.Context, r io.Reader) (int64, error) {
+	if m.readOnly {
+		return 0, ErrReadOnly
+	}
+	progress, _ := ctx.Value(progressKey{}).(chan<- int64)
+	br := bufio.NewReader(r)
+	var total int64
+	var batch []ExportRecord
+	for line := 1; ; line++ {
+		data, readErr := br.ReadBytes('\n')
+		if readErr != nil && readErr != io.EOF {
+			return total, readErr
+		}
+		if data = bytes.TrimSpace(data); len(data) > 0 {
+			var rec ExportRecord
+			if err := json.Unmarshal(data, &rec); err != nil {
+				m.handleError(fmt.Errorf("sqlitestore: import line %d: %v", line, err))
+			} else if rec.ID == "" {
+				m.handleError(fmt.Errorf("sqlitestore: import line %d: missing session ID", line))
+			} else if rec.ExpiresOn.After(m.clock()) {
+				batch = append(batch, rec)
+			}
+		}
+		if len(batch) == warmupBatchSize || (readErr == io.EOF && len(batch) > 0) {
+			if _, err := m.importBatch(ctx, "INSERT OR REPLACE", batch); err != nil {
+				return total, err
+			}
+			total += int64(len(batch))
+			batch = batch[:0]
+			if progress != nil {
+				progress <- total
+			}
+		}
+		if readErr == io.EOF {
+			return total, nil
+		}
+	}
+}
+
+// importBatch writes batch in one transaction with the given INSERT verb
+// and returns the number of rows written.
+func (m *Store) importBatch(ctx context.Context, verb string, batch []ExportRecord) (n int64, err error) {
+	tx, err := m.db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, err
+	}
+	defer func() {
+		if err != nil {
+			tx.Rollback()
+		}
+	}()
+	stmt, err := tx.PrepareContext(ctx, verb+" INTO "+m.table+
+		" (id, name, session_data, created_on, modified_on, expires_on) VALUES (?, ?, ?, ?, ?, ?)")
+	if err != nil {
+		return 0, err
+	}
+	defer stmt.Close()
+	for _, rec := range batch {
+		var res sql.Result
+		res, err = stmt.ExecContext(ctx, rec.ID, rec.Name, string(rec.Data),
+			m.transform("created_on", rec.CreatedOn),
+			m.transform("modified_on", rec.ModifiedOn),
+			m.transform("expires_on", rec.ExpiresOn))
+		if err != nil {
+			return 0, err
+		}
+		var affected int64
+		if affected, err = res.RowsAffected(); err != nil {
+			return 0, err
+		}
+		n += affected
+	}
+	// a pending coalesced write would overwrite the imported rows
+	for _, rec := range batch {
+		m.cancelWrite(m.prefix + rec.ID)
+	}
+	if err = tx.Commit(); err != nil {
+		return 0, err
+	}
+	// replaced rows must not be served from the cache
+	for _, rec := range batch {
+		m.cache.remove(m.prefix + rec.ID)
+	}
+	return n, nil
+}
+
+// exportBatchSize is the number of rows Export reads per query and Import
+// writes per transaction.
+const exportBatchSize = 100
+
+// Export writes every session, expired ones included, to w as
+// newline-delimited JSON, for Import to read into another database.
+func (m *Store) Export(w io.Writer) error {
+	_, err := m.StreamExportJSON(context.Background(), w, exportBatchSize, nil)
+	return err
+}
+
+// ImportResult summarizes an Import.
+type ImportResult struct {
+	// Imported is the number of sessions written.
+	Imported int64
+	// Skipped is the number of sessions that had expired or whose ID was
+	// already taken.
+	Skipped int64
+}
+
+// Import reads sessions written by Export from r, one line at a time, and
+// adds them to the store with their IDs, so existing cookies stay valid.
+// Sessions that have expired or whose ID is already in use are skipped. A
+// malformed line stops the import with an error; the sessions of earlier
+// batches stay imported.
+func (m *Store) Import(r io.Reader) (ImportResult, error) {
+	var result ImportResult
+	if m.readOnly {
+		return result, ErrReadOnly
+	}
+	ctx := context.Background()
+	dec := json.NewDecoder(r)
+	var batch []ExportRecord
+	write := func() error {
+		n, err := m.importBatch(ctx, "INSERT OR IGNORE", batch)
+		if err != nil {
+			return err
+		}
+		result.Imported += n
+		result.Skipped += int64(len(batch)) - n
+		batch = batch[:0]
+		return nil
+	}
+	for record := 1; ; record++ {
+		var rec ExportRecord
+		if err := dec.Decode(&rec); err == io.EOF {
+			break
+		} else if err != nil {
+			return result, fmt.Errorf("sqlitestore: import record %d: %v", record, err)
+		}
+		if rec.ID == "" {
+			return result, fmt.Errorf("sqlitestore: import record %d: missing session ID", record)
+		}
+		if !rec.ExpiresOn.After(m.clock()) {
+			result.Skipped++
+			continue
+		}
+		if batch = append(batch, rec); len(batch) == exportBatchSize {
+			if err := write(); err != nil {
+				return result, err
+			}
+		}
+	}
+	if len(batch) > 0 {
+		if err := write(); err != nil {
+			return result, err
+		}
+	}
+	return result, nil
+}
@@ -0,0 +1,159 @@
+package sqlitestore
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStreamExportJSON(t *testing.T) {
+	store := newTestStore(t)
+	for i := 0; i < 25; i++ {
+		insertTestSession(t, store, map[interface{}]interface{}{"i": i})
+	}
+
+	var buf bytes.Buffer
+	w := bufio.NewWriter(&buf)
+	progress := make(chan int64, 10)
+	n, err := store.StreamExportJSON(context.Background(), w, 10, progress)
+	require.NoError(t, err)
+	assert.Equal(t, int64(25), n)
+	close(progress)
+	var counts []int64
+	for c := range progress {
+		counts = append(counts, c)
+	}
+	assert.Equal(t, []int64{10, 20, 25}, counts)
+
+	scanner := bufio.NewScanner(&buf)
+	var lines int
+	for scanner.Scan() {
+		var rec ExportRecord
+		require.NoError(t, json.Unmarshal(scanner.Bytes(), &rec))
+		assert.Equal(t, "test", rec.Name)
+		assert.Equal(t, rawSessionData(t, store, rec.ID), string(rec.Data))
+		lines++
+	}
+	assert.Equal(t, 25, lines)
+}
+
+func TestStreamExportJSONCanceled(t *testing.T) {
+	store := newTestStore(t)
+	insertTestSession(t, store, nil)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	var buf bytes.Buffer
+	_, err := store.StreamExportJSON(ctx, &buf, 10, nil)
+	assert.Equal(t, context.Canceled, err)
+	assert.Zero(t, buf.Len())
+}
+
+func TestWarmupFromJSON(t *testing.T) {
+	var reported []error
+	store := newTestStore(t, WithErrorHandler(func(err error) { reported = append(reported, err) }))
+	ctx := context.Background()
+	var ids []string
+	for i := 0; i < 500; i++ {
+		ids = append(ids, insertTestSession(t, store, map[interface{}]interface{}{"i": i}).ID)
+	}
+	var buf bytes.Buffer
+	_, err := store.StreamExportJSON(ctx, &buf, 100, nil)
+	require.NoError(t, err)
+
+	expired, err := json.Marshal(ExportRecord{ID: "9999", Name: "test", Data: []byte("x"), ExpiresOn: time.Now().Add(-time.Hour)})
+	require.NoError(t, err)
+	buf.Write(expired)
+	buf.WriteString("\n{\"id\": \"10000\", \"session_data\": \"not base64!\"}\n")
+	buf.WriteString("{\"name\": \"test\"}\n")
+
+	_, err = store.db.ExecContext(ctx, "DELETE FROM sessions")
+	require.NoError(t, err)
+
+	progress := make(chan int64, 10)
+	n, err := store.WarmupFromJSON(ContextWithProgress(ctx, progress), strings.NewReader(buf.String()))
+	require.NoError(t, err)
+	assert.Equal(t, int64(500), n)
+	assert.Equal(t, 500, countRows(t, store))
+	assert.Len(t, reported, 2)
+	close(progress)
+	var last int64
+	for c := range progress {
+		last = c
+	}
+	assert.Equal(t, int64(500), last)
+
+	assert.Equal(t, 42, loadTestSession(t, store, ids[42]).Values["i"])
+}
+
+func TestWarmupFromJSONInvalidatesCache(t *testing.T) {
+	store := newTestStore(t, WithLRUCache(10))
+	ctx := context.Background()
+	sess := insertTestSession(t, store, map[interface{}]interface{}{"v": "new"})
+	var buf bytes.Buffer
+	_, err := store.StreamExportJSON(ctx, &buf, 100, nil)
+	require.NoError(t, err)
+
+	sess.Values["v"] = "old"
+	require.NoError(t, store.save(ctx, sess))
+	assert.Equal(t, "old", loadTestSession(t, store, sess.ID).Values["v"])
+
+	_, err = store.WarmupFromJSON(ctx, &buf)
+	require.NoError(t, err)
+	assert.Equal(t, "new", loadTestSession(t, store, sess.ID).Values["v"])
+}
+
+func TestWarmupFromJSONPendingWrite(t *testing.T) {
+	store := newTestStore(t, WithWriteCoalescing(time.Hour))
+	ctx := context.Background()
+	sess := insertTestSession(t, store, map[interface{}]interface{}{"v": "new"})
+	var buf bytes.Buffer
+	_, err := store.StreamExportJSON(ctx, &buf, 100, nil)
+	require.NoError(t, err)
+
+	sess.IsNew = false
+	sess.Values["v"] = "old"
+	require.NoError(t, store.save(ctx, sess))
+
+	_, err = store.WarmupFromJSON(ctx, &buf)
+	require.NoError(t, err)
+	require.NoError(t, store.flushWrites(ctx))
+	assert.Equal(t, "new", loadTestSession(t, store, sess.ID).Values["v"])
+}
+
+func TestExportImport(t *testing.T) {
+	src := newTestStore(t)
+	var ids []string
+	for i := 0; i < 3; i++ {
+		ids = append(ids, insertTestSession(t, src, map[interface{}]interface{}{"i": i}).ID)
+	}
+	insertTestSession(t, src, map[interface{}]interface{}{"expires_on": time.Now().Add(-time.Hour)})
+
+	var buf bytes.Buffer
+	require.NoError(t, src.Export(&buf))
+	assert.Equal(t, 4, strings.Count(buf.String(), "\n"))
+
+	dst := newTestStore(t)
+	dst.Codecs = src.Codecs
+	// takes the ID of the first exported session
+	taken := insertTestSession(t, dst, map[interface{}]interface{}{"i": "dst"})
+	require.Equal(t, ids[0], taken.ID)
+
+	result, err := dst.Import(&buf)
+	require.NoError(t, err)
+	assert.Equal(t, ImportResult{Imported: 2, Skipped: 2}, result)
+	assert.Equal(t, "dst", loadTestSession(t, dst, ids[0]).Values["i"])
+	for i, id := range ids[1:] {
+		assert.Equal(t, i+1, loadTestSession(t, dst, id).Values["i"])
+	}
+
+	_, err = dst.Import(strings.NewReader(`{"id": "9"}` + "\n" + "not json\n"))
+	assert.Error(t, err)
+}
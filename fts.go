@@ -0,0 +1,107 @@
+package sqlitestore
+
+import (
+	"context"
+)
+
+const ftsBatchSize = 500
+
+func (m *Store) ftsTable() string {
+	return m.table + "_fts"
+}
+
+// CreateFTSIndex creates an FTS5 table alongside the sessions table and fills
+// it with the text extractFn returns for each session's raw session_data,
+// which DecodeSessionData turns back into session values.
+// Existing index contents are replaced. The database must be built with FTS5
+// support (the sqlite_fts5 build tag for go-sqlite3).
+func (m *Store) CreateFTSIndex(ctx context.Context, extractFn func(sessionData string) (text string, err error)) error {
+	_, err := m.db.ExecContext(ctx, "CREATE VIRTUAL TABLE IF NOT EXISTS "+m.ftsTable()+" USING fts5(session_id UNINDEXED, body)")
+	if err != nil {
+		return err
+	}
+	if _, err := m.db.ExecContext(ctx, "DELETE FROM "+m.ftsTable()); err != nil {
+		return err
+	}
+
+	var lastID *string
+	for {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		n, last, err := m.indexFTSBatch(ctx, extractFn, lastID)
+		if err != nil || n < ftsBatchSize {
+			return err
+		}
+		lastID = last
+	}
+}
+
+// indexFTSBatch indexes the next batch of sessions after lastID, or the
+// first batch when it is nil, and returns the ID of the last one indexed.
+func (m *Store) indexFTSBatch(ctx context.Context, extractFn func(string) (string, error), lastID *string) (n int, last *string, err error) {
+	tx, err := m.db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, nil, err
+	}
+	defer func() {
+		if err != nil {
+			tx.Rollback()
+		}
+	}()
+
+	after, args := afterID(lastID)
+	rows, err := tx.QueryContext(ctx, "SELECT id, session_data FROM "+m.table+" WHERE "+after+" ORDER BY id LIMIT ?",
+		append(args, ftsBatchSize)...)
+	if err != nil {
+		return 0, nil, err
+	}
+	var batch []sessionRow
+	for rows.Next() {
+		var row sessionRow
+		if err = rows.Scan(&row.id, &row.data); err != nil {
+			rows.Close()
+			return 0, nil, err
+		}
+		batch = append(batch, row)
+	}
+	rows.Close()
+	if err = rows.Err(); err != nil {
+		return 0, nil, err
+	}
+
+	insQ := "INSERT INTO " + m.ftsTable() + " (session_id, body) VALUES (?, ?)"
+	for _, row := range batch {
+		var text string
+		if text, err = extractFn(row.data); err != nil {
+			return 0, nil, err
+		}
+		if _, err = tx.ExecContext(ctx, insQ, row.id, text); err != nil {
+			return 0, nil, err
+		}
+	}
+	if len(batch) > 0 {
+		last = &batch[len(batch)-1].id
+	}
+	return len(batch), last, tx.Commit()
+}
+
+// SearchSessions returns the IDs of up to limit sessions whose indexed text
+// matches the FTS5 query.
+func (m *Store) SearchSessions(ctx context.Context, query string, limit int) ([]string, error) {
+	rows, err := m.db.QueryContext(ctx, "SELECT session_id FROM "+m.ftsTable()+" WHERE body MATCH ? LIMIT ?", query, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var ids []string
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		ids = append(ids, m.prefix+id)
+	}
+	return ids, rows.Err()
+}
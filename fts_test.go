@@ -0,0 +1,74 @@
+//go:build sqlite_fts5
+// +build sqlite_fts5
+
+package sqlitestore
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFTSIndex(t *testing.T) {
+	store := newTestStore(t)
+	ctx := context.Background()
+
+	alice := insertTestSession(t, store, map[interface{}]interface{}{"name": "Alice Liddell"})
+	insertTestSession(t, store, map[interface{}]interface{}{"name": "Bob Builder"})
+
+	require.NoError(t, store.CreateFTSIndex(ctx, func(data string) (string, error) {
+		values, err := store.DecodeSessionData("test", data)
+		if err != nil {
+			return "", err
+		}
+		name, _ := values["name"].(string)
+		return name, nil
+	}))
+
+	ids, err := store.SearchSessions(ctx, "alice", 10)
+	require.NoError(t, err)
+	assert.Equal(t, []string{alice.ID}, ids)
+
+	ids, err = store.SearchSessions(ctx, "carol", 10)
+	require.NoError(t, err)
+	assert.Empty(t, ids)
+}
+
+func TestFTSIndexTextIDs(t *testing.T) {
+	store := newTestStore(t, WithTableOptions(TableOptions{WithoutRowid: true}))
+	ctx := context.Background()
+	alice := insertTestSession(t, store, map[interface{}]interface{}{"name": "Alice Liddell"})
+
+	require.NoError(t, store.CreateFTSIndex(ctx, func(data string) (string, error) {
+		values, err := store.DecodeSessionData("test", data)
+		if err != nil {
+			return "", err
+		}
+		name, _ := values["name"].(string)
+		return name, nil
+	}))
+	ids, err := store.SearchSessions(ctx, "alice", 10)
+	require.NoError(t, err)
+	assert.Equal(t, []string{alice.ID}, ids)
+}
+
+func TestFTSIndexIDPrefix(t *testing.T) {
+	store := newTestStore(t, WithIDPrefix("app_"))
+	ctx := context.Background()
+	alice := insertTestSession(t, store, map[interface{}]interface{}{"name": "Alice Liddell"})
+
+	require.NoError(t, store.CreateFTSIndex(ctx, func(data string) (string, error) {
+		values, err := store.DecodeSessionData("test", data)
+		if err != nil {
+			return "", err
+		}
+		name, _ := values["name"].(string)
+		return name, nil
+	}))
+	ids, err := store.SearchSessions(ctx, "alice", 10)
+	require.NoError(t, err)
+	assert.Equal(t, []string{alice.ID}, ids)
+	assert.Equal(t, "Alice Liddell", loadTestSession(t, store, ids[0]).Values["name"])
+}
@@ -0,0 +1,101 @@
+package sqlitestore
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+// growthWindowDays is the number of days of history PredictGrowth fits.
+const growthWindowDays = 30
+
+// GrowthPoint is a forecast of the number of sessions created on Date.
+type GrowthPoint struct {
+	Date          time.Time
+	EstimatedRows int64
+}
+
+// PredictGrowth fits a straight line to the number of sessions created per
+// day over the last 30 days and extrapolates it forecastDays past today. Days
+// without new sessions count as zero from the first day that has any.
+func (m *Store) PredictGrowth(ctx context.Context, forecastDays int) ([]GrowthPoint, error) {
+	if forecastDays <= 0 {
+		return nil, errors.New("sqlitestore: forecast days must be positive")
+	}
+	now := m.clock().UTC()
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC)
+	since := today.AddDate(0, 0, -growthWindowDays+1)
+
+	day := "date(created_on)"
+	if _, ok := m.transformer.(UnixTimestampTransformer); ok {
+		day = "date(created_on, 'unixepoch')"
+	}
+	rows, err := m.db.QueryContext(ctx, "SELECT "+day+", COUNT(*) FROM "+m.table+
+		" WHERE "+day+" >= ? GROUP BY "+day, since.Format("2006-01-02"))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	counts := make(map[int]int64)
+	first := growthWindowDays
+	for rows.Next() {
+		var day string
+		var n int64
+		if err := rows.Scan(&day, &n); err != nil {
+			return nil, err
+		}
+		d, err := time.Parse("2006-01-02", day)
+		if err != nil {
+			return nil, err
+		}
+		x := int(d.Sub(since).Hours() / 24)
+		counts[x] = n
+		if x < first {
+			first = x
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	var xs, ys []float64
+	for x := first; x < growthWindowDays; x++ {
+		xs = append(xs, float64(x))
+		ys = append(ys, float64(counts[x]))
+	}
+	slope, intercept := linearFit(xs, ys)
+
+	points := make([]GrowthPoint, forecastDays)
+	for i := range points {
+		x := growthWindowDays + i
+		estimate := int64(slope*float64(x) + intercept + 0.5)
+		if estimate < 0 {
+			estimate = 0
+		}
+		points[i] = GrowthPoint{Date: since.AddDate(0, 0, x), EstimatedRows: estimate}
+	}
+	return points, nil
+}
+
+// linearFit returns the least squares line through the points. With fewer
+// than two points the line is flat at their mean.
+func linearFit(xs, ys []float64) (slope, intercept float64) {
+	n := float64(len(xs))
+	if n == 0 {
+		return 0, 0
+	}
+	var sumX, sumY, sumXY, sumXX float64
+	for i := range xs {
+		sumX += xs[i]
+		sumY += ys[i]
+		sumXY += xs[i] * ys[i]
+		sumXX += xs[i] * xs[i]
+	}
+	denom := n*sumXX - sumX*sumX
+	if denom == 0 {
+		return 0, sumY / n
+	}
+	slope = (n*sumXY - sumX*sumY) / denom
+	return slope, (sumY - slope*sumX) / n
+}
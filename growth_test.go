@@ -0,0 +1,49 @@
+package sqlitestore
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPredictGrowth(t *testing.T) {
+	store := newTestStore(t)
+	clock := &fakeClock{t: time.Date(2020, 1, 10, 12, 0, 0, 0, time.UTC)}
+	store.Clock = clock.Now
+
+	// one more session each day over the last week
+	for day := 0; day < 7; day++ {
+		created := clock.t.AddDate(0, 0, day-6)
+		for i := 0; i <= day; i++ {
+			insertTestSession(t, store, map[interface{}]interface{}{"created_on": created})
+		}
+	}
+
+	points, err := store.PredictGrowth(context.Background(), 3)
+	require.NoError(t, err)
+	require.Len(t, points, 3)
+	assert.True(t, points[0].Date.Equal(time.Date(2020, 1, 11, 0, 0, 0, 0, time.UTC)))
+	assert.Equal(t, int64(8), points[0].EstimatedRows)
+	assert.True(t, points[1].EstimatedRows > points[0].EstimatedRows)
+	assert.True(t, points[2].EstimatedRows > points[1].EstimatedRows)
+}
+
+func TestPredictGrowthUnixTimestamps(t *testing.T) {
+	store := newTestStore(t, WithColumnTransformer(UnixTimestampTransformer{}))
+	clock := &fakeClock{t: time.Date(2020, 1, 10, 12, 0, 0, 0, time.UTC)}
+	store.Clock = clock.Now
+
+	for day := 0; day < 7; day++ {
+		created := clock.t.AddDate(0, 0, day-6)
+		for i := 0; i <= day; i++ {
+			insertTestSession(t, store, map[interface{}]interface{}{"created_on": created})
+		}
+	}
+
+	points, err := store.PredictGrowth(context.Background(), 1)
+	require.NoError(t, err)
+	assert.Equal(t, int64(8), points[0].EstimatedRows)
+}
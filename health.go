@@ -0,0 +1,243 @@
+package sqlitestore
+
+import (
+	"context"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+// Ping verifies that the database is reachable.
+func (m *Store) Ping(ctx context.Context) error {
+	return m.db.PingContext(ctx)
+}
+
+func (m *Store) checkConn() error {
+	if atomic.LoadInt32(&m.connLost) == 1 {
+		return ErrConnectionLost
+	}
+	return nil
+}
+
+func (m *Store) startConnectionWatcher(interval time.Duration, onLost func(error), onRestored func()) {
+	m.wg.Add(1)
+	go func() {
+		defer m.wg.Done()
+
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-m.done:
+				return
+			case <-ticker.C:
+			}
+			ctx, cancel := context.WithTimeout(context.Background(), interval)
+			err := m.Ping(ctx)
+			cancel()
+			if err != nil {
+				if atomic.CompareAndSwapInt32(&m.connLost, 0, 1) && onLost != nil {
+					m.runHook("OnLost", func() { onLost(err) })
+				}
+			} else if atomic.CompareAndSwapInt32(&m.connLost, 1, 0) && onRestored != nil {
+				m.runHook("OnRestored", onRestored)
+			}
+		}
+	}()
+}
+
+// IntegrityCheck runs PRAGMA integrity_check and returns the problems it
+// reports. An empty result means the database is intact.
+func (m *Store) IntegrityCheck(ctx context.Context) ([]string, error) {
+	rows, err := m.db.QueryContext(ctx, "PRAGMA integrity_check")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var problems []string
+	for rows.Next() {
+		var msg string
+		if err := rows.Scan(&msg); err != nil {
+			return nil, err
+		}
+		if msg != "ok" {
+			problems = append(problems, msg)
+		}
+	}
+	return problems, rows.Err()
+}
+
+// HealthReport is the operational status of a store. A check that fails
+// leaves its field at the zero value.
+type HealthReport struct {
+	DBReachable              bool
+	IntegrityOK              bool
+	ActiveSessions           int64
+	ExpiredUncleanedSessions int64
+	// CacheHitRate is the fraction of loads served from the session cache.
+	// It is zero when the store has no cache.
+	CacheHitRate  float64
+	SchemaVersion int
+	// LastCleanupAt is the time expired sessions were last removed by
+	// GarbageCollect or DeleteExpiredInBatches, or zero if they never were.
+	LastCleanupAt time.Time
+	// SlowQueriesLast5m counts queries slower than the WithSlowQueryThreshold
+	// threshold over the last five minutes.
+	SlowQueriesLast5m int64
+}
+
+// HealthReport runs each health check independently and returns their
+// results. Errors from individual checks are passed to the error handler;
+// the returned error is only set if ctx ends before the checks complete.
+func (m *Store) HealthReport(ctx context.Context) (HealthReport, error) {
+	var report HealthReport
+	check := func(err error) bool {
+		if err != nil {
+			m.handleError(err)
+			return false
+		}
+		return true
+	}
+
+	report.DBReachable = check(m.Ping(ctx))
+	if problems, err := m.IntegrityCheck(ctx); check(err) {
+		report.IntegrityOK = len(problems) == 0
+	}
+	now := m.transform("expires_on", m.clock())
+	var active int64
+	if check(m.db.QueryRowContext(ctx, "SELECT COUNT(*) FROM "+m.table+" WHERE expires_on > ?", now).Scan(&active)) {
+		report.ActiveSessions = active
+	}
+	var expired int64
+	if check(m.db.QueryRowContext(ctx, "SELECT COUNT(*) FROM "+m.table+" WHERE expires_on <= ?", now).Scan(&expired)) {
+		report.ExpiredUncleanedSessions = expired
+	}
+	if version, err := m.GetSchemaVersion(ctx); check(err) {
+		report.SchemaVersion = version
+	}
+	if stats := m.CacheStats(); stats.Hits+stats.Misses > 0 {
+		report.CacheHitRate = float64(stats.Hits) / float64(stats.Hits+stats.Misses)
+	}
+	if ns := atomic.LoadInt64(&m.lastCleanup); ns != 0 {
+		report.LastCleanupAt = time.Unix(0, ns)
+	}
+	report.SlowQueriesLast5m = m.slowQueriesSince(time.Now())
+	return report, ctx.Err()
+}
+
+// StatusCounts breaks the sessions table down by expiry and frozen state.
+type StatusCounts struct {
+	Active        int64
+	Expired       int64
+	FrozenActive  int64
+	FrozenExpired int64
+}
+
+// CountByStatus counts active and expired sessions in a single query,
+// separating out sessions whose frozen column is set. Tables without a
+// frozen column report every session as not frozen.
+func (m *Store) CountByStatus(ctx context.Context) (StatusCounts, error) {
+	cols, err := m.DescribeSchema(ctx)
+	if err != nil {
+		return StatusCounts{}, err
+	}
+	notFrozen, frozen := "1", "0"
+	for _, col := range cols {
+		if strings.EqualFold(col.Name, "frozen") {
+			notFrozen, frozen = "(frozen IS NULL OR frozen = 0)", "(frozen IS NOT NULL AND frozen != 0)"
+		}
+	}
+	q := "SELECT " +
+		"COALESCE(SUM(CASE WHEN expires_on > ? AND " + notFrozen + " THEN 1 END), 0), " +
+		"COALESCE(SUM(CASE WHEN expires_on <= ? AND " + notFrozen + " THEN 1 END), 0), " +
+		"COALESCE(SUM(CASE WHEN expires_on > ? AND " + frozen + " THEN 1 END), 0), " +
+		"COALESCE(SUM(CASE WHEN expires_on <= ? AND " + frozen + " THEN 1 END), 0) " +
+		"FROM " + m.table
+	now := m.transform("expires_on", m.clock())
+	var c StatusCounts
+	if err := m.db.QueryRowContext(ctx, q, now, now, now, now).Scan(&c.Active, &c.Expired, &c.FrozenActive, &c.FrozenExpired); err != nil {
+		return StatusCounts{}, err
+	}
+	return c, nil
+}
+
+// TableSizeStats reports the storage used by the store. SQLite does not
+// track space per table, so TotalBytes and FreeBytes cover the whole
+// database file.
+type TableSizeStats struct {
+	RowCount   int64
+	TotalBytes int64
+	// FreeBytes is the space in unused pages that VACUUM or incremental
+	// vacuum would return to the file system.
+	FreeBytes int64
+}
+
+// TableSize returns the number of rows in the sessions table and the size of
+// the database.
+func (m *Store) TableSize(ctx context.Context) (TableSizeStats, error) {
+	var stats TableSizeStats
+	if err := m.db.QueryRowContext(ctx, "SELECT COUNT(*) FROM "+m.table).Scan(&stats.RowCount); err != nil {
+		return stats, err
+	}
+	var pageSize, pageCount, freePages int64
+	for _, p := range []struct {
+		name string
+		dst  *int64
+	}{
+		{"page_size", &pageSize},
+		{"page_count", &pageCount},
+		{"freelist_count", &freePages},
+	} {
+		if err := m.db.QueryRowContext(ctx, "PRAGMA "+p.name).Scan(p.dst); err != nil {
+			return stats, err
+		}
+	}
+	stats.TotalBytes = pageCount * pageSize
+	stats.FreeBytes = freePages * pageSize
+	return stats, nil
+}
+
+// SessionInfo describes a stored session without its data.
+type SessionInfo struct {
+	ID         string
+	CreatedOn  time.Time
+	ModifiedOn time.Time
+	ExpiresOn  time.Time
+}
+
+// CountActive returns the number of unexpired sessions.
+func (m *Store) CountActive() (int64, error) {
+	var n int64
+	err := m.db.QueryRowContext(context.Background(), "SELECT COUNT(*) FROM "+m.table+" WHERE expires_on > ?",
+		m.transform("expires_on", m.clock())).Scan(&n)
+	return n, err
+}
+
+// ListActive returns up to limit unexpired sessions, newest first, skipping
+// the first offset. Session data is not read.
+func (m *Store) ListActive(limit, offset int) ([]SessionInfo, error) {
+	ctx := context.Background()
+	rows, err := m.db.QueryContext(ctx, "SELECT id, '', created_on, modified_on, expires_on FROM "+m.table+
+		" WHERE expires_on > ? ORDER BY created_on DESC, id DESC LIMIT ? OFFSET ?",
+		m.transform("expires_on", m.clock()), limit, offset)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var infos []SessionInfo
+	for rows.Next() {
+		var sess sessionRow
+		if err := m.scanSession(rows, &sess); err != nil {
+			return nil, err
+		}
+		infos = append(infos, SessionInfo{
+			ID:         m.prefix + sess.id,
+			CreatedOn:  sess.createdOn,
+			ModifiedOn: sess.modifiedOn,
+			ExpiresOn:  sess.expiresOn,
+		})
+	}
+	return infos, rows.Err()
+}
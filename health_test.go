@@ -0,0 +1,173 @@
+package sqlitestore
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/gorilla/securecookie"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// flakyDB is a DB whose pings fail while down is set.
+type flakyDB struct {
+	*sql.DB
+	down int32
+}
+
+func (db *flakyDB) PingContext(ctx context.Context) error {
+	if atomic.LoadInt32(&db.down) == 1 {
+		return errors.New("stale file handle")
+	}
+	return db.DB.PingContext(ctx)
+}
+
+func TestConnectionWatcher(t *testing.T) {
+	db := &flakyDB{DB: newTestDB(t)}
+	lost := make(chan error, 1)
+	restored := make(chan struct{}, 1)
+	store := newTestStoreDB(t, db, WithConnectionWatcher(10*time.Millisecond,
+		func(err error) { lost <- err },
+		func() { restored <- struct{}{} },
+	))
+	require.NoError(t, store.Ping(context.Background()))
+
+	atomic.StoreInt32(&db.down, 1)
+	select {
+	case err := <-lost:
+		assert.EqualError(t, err, "stale file handle")
+	case <-time.After(time.Second):
+		t.Fatal("onLost was not called")
+	}
+
+	r := httptest.NewRequest("GET", "/", nil)
+	sess, err := store.New(r, "test")
+	assert.Equal(t, ErrConnectionLost, err)
+	assert.Equal(t, ErrConnectionLost, store.Save(r, httptest.NewRecorder(), sess))
+
+	atomic.StoreInt32(&db.down, 0)
+	select {
+	case <-restored:
+	case <-time.After(time.Second):
+		t.Fatal("onRestored was not called")
+	}
+	assert.NoError(t, store.Save(r, httptest.NewRecorder(), sess))
+}
+
+func TestHealthReport(t *testing.T) {
+	store, err := NewStore(newTestDB(t), [][]byte{securecookie.GenerateRandomKey(32)})
+	require.NoError(t, err)
+	defer store.Close()
+
+	report, err := store.HealthReport(context.Background())
+	require.NoError(t, err)
+	assert.True(t, report.DBReachable)
+	assert.True(t, report.IntegrityOK)
+	assert.True(t, report.LastCleanupAt.IsZero())
+
+	insertTestSession(t, store, nil)
+	insertTestSession(t, store, map[interface{}]interface{}{"expires_on": time.Now().Add(-time.Hour)})
+	report, err = store.HealthReport(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), report.ActiveSessions)
+	assert.Equal(t, int64(1), report.ExpiredUncleanedSessions)
+
+	_, err = store.GarbageCollect(context.Background())
+	require.NoError(t, err)
+	report, err = store.HealthReport(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, int64(0), report.ExpiredUncleanedSessions)
+	assert.False(t, report.LastCleanupAt.IsZero())
+}
+
+func TestHealthReportExpiresNow(t *testing.T) {
+	store := newTestStore(t)
+	clock := &fakeClock{t: time.Date(2020, 1, 1, 12, 0, 0, 0, time.UTC)}
+	store.Clock = clock.Now
+	insertTestSession(t, store, map[interface{}]interface{}{"expires_on": clock.t})
+
+	report, err := store.HealthReport(context.Background())
+	require.NoError(t, err)
+	active, err := store.CountActive()
+	require.NoError(t, err)
+	assert.Equal(t, active, report.ActiveSessions)
+	assert.Equal(t, int64(0), report.ActiveSessions)
+	assert.Equal(t, int64(1), report.ExpiredUncleanedSessions)
+}
+
+func TestCountByStatus(t *testing.T) {
+	store := newTestStore(t)
+	ctx := context.Background()
+
+	counts, err := store.CountByStatus(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, StatusCounts{}, counts)
+
+	past := map[interface{}]interface{}{"expires_on": time.Now().Add(-time.Hour)}
+	var ids []string
+	for i := 0; i < 4; i++ {
+		ids = append(ids, insertTestSession(t, store, nil).ID)
+	}
+	for i := 0; i < 3; i++ {
+		ids = append(ids, insertTestSession(t, store, past).ID)
+	}
+	counts, err = store.CountByStatus(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, StatusCounts{Active: 4, Expired: 3}, counts)
+
+	_, err = store.db.ExecContext(ctx, "UPDATE sessions SET frozen = 1 WHERE id IN (?, ?, ?)", ids[0], ids[1], ids[4])
+	require.NoError(t, err)
+	counts, err = store.CountByStatus(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, StatusCounts{Active: 2, Expired: 2, FrozenActive: 2, FrozenExpired: 1}, counts)
+}
+
+func TestTableSize(t *testing.T) {
+	store := newTestStore(t)
+	for i := 0; i < 20; i++ {
+		insertTestSession(t, store, map[interface{}]interface{}{"n": i})
+	}
+	stats, err := store.TableSize(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, int64(20), stats.RowCount)
+	assert.True(t, stats.TotalBytes > 0)
+	assert.True(t, stats.FreeBytes >= 0 && stats.FreeBytes < stats.TotalBytes)
+}
+
+func TestCountAndListActive(t *testing.T) {
+	store := newTestStore(t)
+	clock := &fakeClock{t: time.Date(2020, 1, 1, 12, 0, 0, 0, time.UTC)}
+	store.Clock = clock.Now
+
+	var ids []string
+	for i := 0; i < 5; i++ {
+		ids = append(ids, insertTestSession(t, store, nil).ID)
+		clock.Advance(time.Minute)
+	}
+	for _, id := range ids[1:3] {
+		_, err := store.db.Exec("UPDATE sessions SET expires_on = ? WHERE id = ?", clock.t.Add(-time.Hour), id)
+		require.NoError(t, err)
+	}
+
+	n, err := store.CountActive()
+	require.NoError(t, err)
+	assert.Equal(t, int64(3), n)
+
+	infos, err := store.ListActive(10, 0)
+	require.NoError(t, err)
+	require.Len(t, infos, 3)
+	for i, id := range []string{ids[4], ids[3], ids[0]} {
+		assert.Equal(t, id, infos[i].ID)
+		assert.True(t, infos[i].ExpiresOn.After(clock.t))
+	}
+
+	infos, err = store.ListActive(1, 1)
+	require.NoError(t, err)
+	require.Len(t, infos, 1)
+	assert.Equal(t, ids[3], infos[0].ID)
+}
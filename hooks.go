@@ -0,0 +1,97 @@
+package sqlitestore
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/gorilla/sessions"
+)
+
+// ErrPreSaveHookRejected is returned from Save when the pre-save hook
+// cancels the write.
+type ErrPreSaveHookRejected struct {
+	Cause error
+}
+
+func (e ErrPreSaveHookRejected) Error() string {
+	return fmt.Sprintf("sqlitestore: save rejected by pre-save hook: %v", e.Cause)
+}
+
+func (e ErrPreSaveHookRejected) Unwrap() error {
+	return e.Cause
+}
+
+// Hooks are called as sessions are created, loaded, deleted and found to be
+// expired, for audit logs or metrics. Any of them may be nil. They run
+// synchronously, so they should return quickly. Set them through Store.Hooks,
+// WithHooks or the options for single hooks, WithOnDelete and WithOnLoad.
+// Each event calls one hook, except a load, which calls OnLoadRow and then
+// OnLoad.
+type Hooks struct {
+	// OnCreate is called after a new session is inserted.
+	OnCreate func(session *sessions.Session)
+	// OnLoadRow is called with the raw row of a loaded session, whose
+	// session data is still encoded.
+	OnLoadRow func(ctx context.Context, sess *sessionRow)
+	// OnLoad is called after a session is loaded and decoded.
+	OnLoad func(session *sessions.Session)
+	// OnDelete is called with the ID of each deleted session.
+	OnDelete func(id string)
+	// OnExpire is called with the ID of a session that load found expired.
+	OnExpire func(id string)
+}
+
+// runHook calls a user supplied hook. With WithRecoveryCallback configured,
+// a panicking hook is reported and treated as having returned normally.
+func (m *Store) runHook(name string, fn func()) {
+	if m.onRecover != nil {
+		defer func() {
+			if r := recover(); r != nil {
+				m.onRecover(name, r)
+			}
+		}()
+	}
+	fn()
+}
+
+func (m *Store) runPreSave(session *sessions.Session) error {
+	if m.preSave == nil {
+		return nil
+	}
+	var err error
+	m.runHook("PreSave", func() { err = m.preSave(session) })
+	if err != nil {
+		return ErrPreSaveHookRejected{Cause: err}
+	}
+	return nil
+}
+
+func (m *Store) runOnCreate(session *sessions.Session) {
+	if m.Hooks.OnCreate == nil {
+		return
+	}
+	m.runHook("OnCreate", func() { m.Hooks.OnCreate(session) })
+}
+
+func (m *Store) runOnDelete(id string) {
+	m.cache.remove(id)
+	if m.Hooks.OnDelete != nil {
+		m.runHook("OnDelete", func() { m.Hooks.OnDelete(id) })
+	}
+}
+
+func (m *Store) runOnLoad(ctx context.Context, session *sessions.Session, sess *sessionRow) {
+	if m.Hooks.OnLoadRow != nil {
+		m.runHook("OnLoad", func() { m.Hooks.OnLoadRow(ctx, sess) })
+	}
+	if m.Hooks.OnLoad != nil {
+		m.runHook("OnLoad", func() { m.Hooks.OnLoad(session) })
+	}
+}
+
+func (m *Store) runOnExpire(id string) {
+	if m.Hooks.OnExpire == nil {
+		return
+	}
+	m.runHook("OnExpire", func() { m.Hooks.OnExpire(id) })
+}
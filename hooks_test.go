@@ -0,0 +1,149 @@
+package sqlitestore
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gorilla/sessions"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPreSaveHook(t *testing.T) {
+	errTokenExpired := errors.New("token expired")
+	store := newTestStore(t, WithPreSaveHook(func(session *sessions.Session) error {
+		if session.Values["token"] == "expired" {
+			return errTokenExpired
+		}
+		return nil
+	}))
+
+	r := httptest.NewRequest("GET", "/", nil)
+	sess, err := store.New(r, "test")
+	require.NoError(t, err)
+	sess.Values["token"] = "expired"
+	w := httptest.NewRecorder()
+	err = store.Save(r, w, sess)
+
+	var rejected ErrPreSaveHookRejected
+	require.True(t, errors.As(err, &rejected))
+	assert.Equal(t, errTokenExpired, rejected.Cause)
+	assert.True(t, errors.Is(err, errTokenExpired))
+	assert.Empty(t, sess.ID)
+	assert.Empty(t, w.Header().Get("Set-Cookie"))
+	assert.Equal(t, 0, countRows(t, store))
+
+	sess.Values["token"] = "valid"
+	require.NoError(t, store.Save(r, w, sess))
+	assert.Equal(t, 1, countRows(t, store))
+}
+
+func TestRecoveryCallback(t *testing.T) {
+	var recovered []interface{}
+	store := newTestStore(t,
+		WithPreSaveHook(func(session *sessions.Session) error { panic("boom") }),
+		WithRecoveryCallback(func(hookName string, r interface{}) {
+			assert.Equal(t, "PreSave", hookName)
+			recovered = append(recovered, r)
+		}),
+	)
+
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		sess, err := store.Get(r, "test")
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		if err := sess.Save(r, w); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	})
+
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest("GET", "/", nil))
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, []interface{}{"boom"}, recovered)
+	assert.Equal(t, 1, countRows(t, store))
+}
+
+func TestOnLoadHook(t *testing.T) {
+	var loaded []string
+	var recovered []string
+	store := newTestStore(t,
+		WithOnLoad(func(ctx context.Context, sess *sessionRow) {
+			loaded = append(loaded, sess.ID())
+			assert.NotEmpty(t, sess.data)
+			panic("boom")
+		}),
+		WithRecoveryCallback(func(hookName string, r interface{}) {
+			recovered = append(recovered, hookName)
+		}),
+	)
+	id := insertTestSession(t, store, map[interface{}]interface{}{"user": "alice"}).ID
+
+	sess := loadTestSession(t, store, id)
+	assert.Equal(t, "alice", sess.Values["user"])
+	assert.Equal(t, []string{id}, loaded)
+	assert.Equal(t, []string{"OnLoad"}, recovered)
+
+	missing := sessions.NewSession(store, "test")
+	missing.ID = "999"
+	assert.Error(t, store.load(context.Background(), missing))
+	assert.Len(t, loaded, 1)
+}
+
+func TestLifecycleHooks(t *testing.T) {
+	store := newTestStore(t)
+	var events []string
+	store.Hooks = Hooks{
+		OnCreate: func(session *sessions.Session) { events = append(events, "create "+session.ID) },
+		OnLoad:   func(session *sessions.Session) { events = append(events, "load "+session.ID) },
+		OnDelete: func(id string) { events = append(events, "delete "+id) },
+		OnExpire: func(id string) { events = append(events, "expire "+id) },
+	}
+	ctx := context.Background()
+
+	sess := insertTestSession(t, store, nil)
+	loadTestSession(t, store, sess.ID)
+	require.NoError(t, store.Delete(httptest.NewRequest("GET", "/", nil), httptest.NewRecorder(), sess))
+	expired := insertTestSession(t, store, map[interface{}]interface{}{"expires_on": time.Now().Add(-time.Hour)})
+	loaded := sessions.NewSession(store, "test")
+	loaded.ID = expired.ID
+	assert.Equal(t, SessionExpired, store.load(ctx, loaded))
+
+	assert.Equal(t, []string{
+		"create " + sess.ID,
+		"load " + sess.ID,
+		"delete " + sess.ID,
+		"create " + expired.ID,
+		"expire " + expired.ID,
+	}, events)
+
+	// hooks are optional
+	store.Hooks = Hooks{}
+	loadTestSession(t, store, insertTestSession(t, store, nil).ID)
+}
+
+func TestHookOptions(t *testing.T) {
+	var events []string
+	store := newTestStore(t,
+		WithHooks(Hooks{
+			OnLoad:   func(session *sessions.Session) { events = append(events, "load") },
+			OnDelete: func(id string) { events = append(events, "replaced") },
+		}),
+		WithOnLoad(func(ctx context.Context, sess *sessionRow) { events = append(events, "load row") }),
+		WithOnDelete(func(id string) { events = append(events, "delete") }),
+	)
+	assert.NotNil(t, store.Hooks.OnLoadRow)
+
+	sess := insertTestSession(t, store, nil)
+	loadTestSession(t, store, sess.ID)
+	require.NoError(t, store.Delete(httptest.NewRequest("GET", "/", nil), httptest.NewRecorder(), sess))
+	assert.Equal(t, []string{"load row", "load", "delete"}, events)
+}
@@ -0,0 +1,135 @@
+package sqlitestore
+
+import (
+	"context"
+	"hash/fnv"
+	"sync"
+	"time"
+)
+
+// locker guards concurrent access to session rows, keyed by session ID.
+type locker interface {
+	Lock(id string)
+	Unlock(id string)
+	RLock(id string)
+	RUnlock(id string)
+	TryLock(id string) bool
+	TryRLock(id string) bool
+	// LockAll takes the write lock for every session at once.
+	LockAll()
+	UnlockAll()
+}
+
+// mutexLocker serializes all sessions behind a single mutex.
+type mutexLocker struct {
+	mu sync.RWMutex
+}
+
+func (l *mutexLocker) Lock(string)          { l.mu.Lock() }
+func (l *mutexLocker) Unlock(string)        { l.mu.Unlock() }
+func (l *mutexLocker) RLock(string)         { l.mu.RLock() }
+func (l *mutexLocker) RUnlock(string)       { l.mu.RUnlock() }
+func (l *mutexLocker) TryLock(string) bool  { return l.mu.TryLock() }
+func (l *mutexLocker) TryRLock(string) bool { return l.mu.TryRLock() }
+func (l *mutexLocker) LockAll()             { l.mu.Lock() }
+func (l *mutexLocker) UnlockAll()           { l.mu.Unlock() }
+
+// shardedLocker spreads sessions across a power-of-two number of mutexes.
+type shardedLocker struct {
+	shards []sync.RWMutex
+	mask   uint32
+}
+
+func newShardedLocker(n int) *shardedLocker {
+	return &shardedLocker{
+		shards: make([]sync.RWMutex, n),
+		mask:   uint32(n - 1),
+	}
+}
+
+func (l *shardedLocker) shard(id string) *sync.RWMutex {
+	h := fnv.New32()
+	h.Write([]byte(id))
+	return &l.shards[h.Sum32()&l.mask]
+}
+
+func (l *shardedLocker) Lock(id string)          { l.shard(id).Lock() }
+func (l *shardedLocker) Unlock(id string)        { l.shard(id).Unlock() }
+func (l *shardedLocker) RLock(id string)         { l.shard(id).RLock() }
+func (l *shardedLocker) RUnlock(id string)       { l.shard(id).RUnlock() }
+func (l *shardedLocker) TryLock(id string) bool  { return l.shard(id).TryLock() }
+func (l *shardedLocker) TryRLock(id string) bool { return l.shard(id).TryRLock() }
+
+// LockAll locks the shards in order, so concurrent callers cannot deadlock.
+func (l *shardedLocker) LockAll() {
+	for i := range l.shards {
+		l.shards[i].Lock()
+	}
+}
+
+func (l *shardedLocker) UnlockAll() {
+	for i := range l.shards {
+		l.shards[i].Unlock()
+	}
+}
+
+// noopLocker does no locking, for stores whose callers synchronize access
+// themselves.
+type noopLocker struct{}
+
+func (noopLocker) Lock(string)          {}
+func (noopLocker) Unlock(string)        {}
+func (noopLocker) RLock(string)         {}
+func (noopLocker) RUnlock(string)       {}
+func (noopLocker) TryLock(string) bool  { return true }
+func (noopLocker) TryRLock(string) bool { return true }
+func (noopLocker) LockAll()             {}
+func (noopLocker) UnlockAll()           {}
+
+// disabledLockWarning is logged by New for stores created with
+// WithDisabledMutex.
+const disabledLockWarning = "sqlitestore: WARNING: session locking is disabled by WithDisabledMutex; " +
+	"the caller must synchronize all access to the store"
+
+// maxLockBackoff caps the sleep between attempts to take a contended lock.
+const maxLockBackoff = 10 * time.Millisecond
+
+// lock takes the write lock for id. With WithGlobalLockTimeout set it gives
+// up with ErrLockTimeout once the timeout passes, or when ctx ends.
+func (m *Store) lock(ctx context.Context, id string) error {
+	return m.acquire(ctx, id, m.locks.TryLock, m.locks.Lock)
+}
+
+// rlock takes the read lock for id, with the same timeout as lock.
+func (m *Store) rlock(ctx context.Context, id string) error {
+	return m.acquire(ctx, id, m.locks.TryRLock, m.locks.RLock)
+}
+
+func (m *Store) acquire(ctx context.Context, id string, try func(string) bool, block func(string)) error {
+	if m.lockTimeout <= 0 {
+		block(id)
+		return nil
+	}
+	deadline := time.Now().Add(m.lockTimeout)
+	backoff := 100 * time.Microsecond
+	for !try(id) {
+		remaining := time.Until(deadline)
+		if remaining <= 0 {
+			return ErrLockTimeout
+		}
+		if backoff > remaining {
+			backoff = remaining
+		}
+		timer := time.NewTimer(backoff)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return ctx.Err()
+		case <-timer.C:
+		}
+		if backoff *= 2; backoff > maxLockBackoff {
+			backoff = maxLockBackoff
+		}
+	}
+	return nil
+}
@@ -0,0 +1,109 @@
+package sqlitestore
+
+import (
+	"context"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/gorilla/sessions"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestShardedMutexValidation(t *testing.T) {
+	for _, n := range []int{0, -1, 3, 12} {
+		_, err := New(nil, nil, WithShardedMutex(n))
+		assert.Error(t, err, "shards=%d", n)
+	}
+}
+
+func TestShardedMutexSession(t *testing.T) {
+	store := newTestStore(t, WithShardedMutex(16))
+
+	r := httptest.NewRequest("GET", "/", nil)
+	sess, err := store.New(r, "test")
+	require.NoError(t, err)
+	sess.Values["foo"] = "bar"
+	w := httptest.NewRecorder()
+	require.NoError(t, sess.Save(r, w))
+
+	r2 := httptest.NewRequest("GET", "/", nil)
+	r2.Header.Add("Cookie", w.Header().Get("Set-Cookie"))
+	sess2, err := store.New(r2, "test")
+	require.NoError(t, err)
+	assert.False(t, sess2.IsNew)
+	assert.Equal(t, "bar", sess2.Values["foo"])
+}
+
+func TestGlobalLockTimeout(t *testing.T) {
+	store := newTestStore(t, WithGlobalLockTimeout(50*time.Millisecond))
+	sess := insertTestSession(t, store, map[interface{}]interface{}{"foo": "bar"})
+
+	// saturate the lock as a long running writer would
+	store.locks.Lock(sess.ID)
+	start := time.Now()
+	err := store.save(context.Background(), sess)
+	elapsed := time.Since(start)
+	assert.Equal(t, ErrLockTimeout, err)
+	assert.True(t, elapsed >= 50*time.Millisecond && elapsed < time.Second, "waited %s", elapsed)
+	assert.Equal(t, ErrLockTimeout, store.load(context.Background(), sess))
+
+	store.locks.Unlock(sess.ID)
+	sess.Values["foo"] = "baz"
+	require.NoError(t, store.save(context.Background(), sess))
+	assert.Equal(t, "baz", loadTestSession(t, store, sess.ID).Values["foo"])
+}
+
+// benchmarkSave saves distinct sessions from many goroutines at once, so
+// writers only contend when they share a lock. Writes are coalesced, so a
+// save encodes under the session lock without reaching the database and the
+// numbers measure the store's locking rather than SQLite's.
+func benchmarkSave(b *testing.B, opts ...StoreOption) {
+	store := newTestStore(b, append([]StoreOption{WithWriteCoalescing(time.Hour)}, opts...)...)
+
+	const n = 64
+	ids := make([]string, n)
+	for i := range ids {
+		sess := sessions.NewSession(store, "bench")
+		sess.Options = &sessions.Options{MaxAge: 3600}
+		sess.Values["i"] = i
+		require.NoError(b, store.insert(context.Background(), sess))
+		ids[i] = sess.ID
+	}
+
+	var next int32
+	b.SetParallelism(n)
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		sess := sessions.NewSession(store, "bench")
+		sess.ID = ids[int(atomic.AddInt32(&next, 1)-1)%n]
+		sess.Options = &sessions.Options{MaxAge: 3600}
+		for i := 0; pb.Next(); i++ {
+			sess.Values["i"] = i
+			if err := store.save(context.Background(), sess); err != nil {
+				b.Errorf("save %s: %v", sess.ID, err)
+				return
+			}
+		}
+	})
+}
+
+func BenchmarkSaveSingleMutex(b *testing.B)  { benchmarkSave(b) }
+func BenchmarkSaveShardedMutex(b *testing.B) { benchmarkSave(b, WithShardedMutex(64)) }
+
+func TestDisabledMutex(t *testing.T) {
+	logger := &recordingLogger{}
+	store := newTestStore(t, WithDisabledMutex(), WithLogger(logger))
+	require.Len(t, logger.warnings, 1)
+	assert.Equal(t, disabledLockWarning, logger.warnings[0].msg)
+
+	// a held lock no longer excludes anyone
+	store.locks.Lock("1")
+	assert.True(t, store.locks.TryLock("1"))
+	assert.True(t, store.locks.TryRLock("1"))
+
+	sess := insertTestSession(t, store, map[interface{}]interface{}{"n": 1})
+	assert.Equal(t, 1, loadTestSession(t, store, sess.ID).Values["n"])
+}
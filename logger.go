@@ -0,0 +1,68 @@
+package sqlitestore
+
+import (
+	"log"
+	"time"
+)
+
+// slowQueryWindow is how far back HealthReport counts slow queries.
+const slowQueryWindow = 5 * time.Minute
+
+// Logger receives log entries from the store. keyvals alternate between keys
+// and values.
+type Logger interface {
+	Warn(msg string, keyvals ...interface{})
+}
+
+// warn logs msg to the logger, or to the standard logger when none is set,
+// for problems that must not go unnoticed.
+func (m *Store) warn(msg string, keyvals ...interface{}) {
+	if m.logger != nil {
+		m.logger.Warn(msg, keyvals...)
+		return
+	}
+	log.Print(append([]interface{}{msg}, keyvals...)...)
+}
+
+// observeQuery reports an operation that started at start and finished with
+// err to the logger if it took longer than the slow query threshold.
+func (m *Store) observeQuery(op, sessionID string, start time.Time, err error) {
+	if m.slowThreshold <= 0 {
+		return
+	}
+	elapsed := time.Since(start)
+	if elapsed <= m.slowThreshold {
+		return
+	}
+	m.recordSlowQuery(start.Add(elapsed))
+	if m.logger != nil {
+		m.logger.Warn("sqlitestore: slow query",
+			"op", op, "duration", elapsed, "session_id", sessionID, "success", err == nil)
+	}
+}
+
+func (m *Store) recordSlowQuery(at time.Time) {
+	m.slowMu.Lock()
+	defer m.slowMu.Unlock()
+	m.slowQueries = append(m.pruneSlowQueries(at), at)
+}
+
+// slowQueriesSince returns the number of slow queries in the window ending at
+// now.
+func (m *Store) slowQueriesSince(now time.Time) int64 {
+	m.slowMu.Lock()
+	defer m.slowMu.Unlock()
+	m.slowQueries = m.pruneSlowQueries(now)
+	return int64(len(m.slowQueries))
+}
+
+// pruneSlowQueries drops recorded slow queries older than the window ending
+// at now. The caller must hold slowMu.
+func (m *Store) pruneSlowQueries(now time.Time) []time.Time {
+	cutoff := now.Add(-slowQueryWindow)
+	i := 0
+	for i < len(m.slowQueries) && m.slowQueries[i].Before(cutoff) {
+		i++
+	}
+	return m.slowQueries[i:]
+}
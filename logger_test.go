@@ -0,0 +1,63 @@
+package sqlitestore
+
+import (
+	"context"
+	"database/sql"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type warning struct {
+	msg     string
+	keyvals []interface{}
+}
+
+type recordingLogger struct {
+	mu       sync.Mutex
+	warnings []warning
+}
+
+func (l *recordingLogger) Warn(msg string, keyvals ...interface{}) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.warnings = append(l.warnings, warning{msg, keyvals})
+}
+
+// delayDB is a DB whose ExecContext takes at least delay.
+type delayDB struct {
+	*sql.DB
+	delay time.Duration
+}
+
+func (db delayDB) ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error) {
+	time.Sleep(db.delay)
+	return db.DB.ExecContext(ctx, query, args...)
+}
+
+func TestSlowQueryThreshold(t *testing.T) {
+	logger := &recordingLogger{}
+	store := newTestStoreDB(t, delayDB{newTestDB(t), 100 * time.Millisecond},
+		WithLogger(logger), WithSlowQueryThreshold(50*time.Millisecond))
+
+	insertTestSession(t, store, nil)
+	assert.Empty(t, logger.warnings)
+
+	for i := 0; i < 2; i++ {
+		_, err := store.GarbageCollect(context.Background())
+		require.NoError(t, err)
+	}
+	require.Len(t, logger.warnings, 2)
+	kv := logger.warnings[0].keyvals
+	assert.Equal(t, []interface{}{"op", "gc"}, kv[:2])
+	assert.Equal(t, "duration", kv[2])
+	assert.True(t, kv[3].(time.Duration) >= 100*time.Millisecond)
+	assert.Equal(t, []interface{}{"session_id", "", "success", true}, kv[4:])
+
+	report, err := store.HealthReport(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, int64(2), report.SlowQueriesLast5m)
+}
@@ -0,0 +1,28 @@
+package sqlitestore
+
+import (
+	"time"
+)
+
+// Metrics receives counts and latencies of store operations. op is one of
+// "insert", "save", "load", "delete", "delete_expired" or, counted by IncOp
+// only, "expire" for a session found expired on load. See WithMetrics and,
+// built with the prometheus tag, PrometheusMetrics.
+type Metrics interface {
+	IncOp(op string)
+	ObserveLatency(op string, d time.Duration)
+	IncError(op string)
+}
+
+// observeOp reports an operation that started at start and finished with
+// *err to the metrics, if any. Expired sessions are not errors.
+func (m *Store) observeOp(op string, start time.Time, err *error) {
+	if m.metrics == nil {
+		return
+	}
+	m.metrics.IncOp(op)
+	m.metrics.ObserveLatency(op, time.Since(start))
+	if *err != nil && *err != SessionExpired {
+		m.metrics.IncError(op)
+	}
+}
@@ -0,0 +1,80 @@
+//go:build prometheus
+// +build prometheus
+
+package sqlitestore
+
+import (
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// PrometheusMetrics is a Metrics that exports operation counts, errors and
+// latencies as Prometheus metrics labelled by op. It is a
+// prometheus.Collector, so register it with the registry the rest of the
+// application uses:
+//
+//	metrics := sqlitestore.NewPrometheusMetrics("myapp")
+//	registry.MustRegister(metrics)
+//	store, err := sqlitestore.New(db, keyPairs, sqlitestore.WithMetrics(metrics))
+//
+// It is only built with the prometheus build tag.
+type PrometheusMetrics struct {
+	ops     *prometheus.CounterVec
+	errors  *prometheus.CounterVec
+	latency *prometheus.HistogramVec
+}
+
+// NewPrometheusMetrics returns metrics named
+// <namespace>_sqlitestore_operations_total,
+// <namespace>_sqlitestore_errors_total and
+// <namespace>_sqlitestore_operation_duration_seconds. namespace may be empty.
+func NewPrometheusMetrics(namespace string) *PrometheusMetrics {
+	return &PrometheusMetrics{
+		ops: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Subsystem: "sqlitestore",
+			Name:      "operations_total",
+			Help:      "Session store operations by op.",
+		}, []string{"op"}),
+		errors: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Subsystem: "sqlitestore",
+			Name:      "errors_total",
+			Help:      "Failed session store operations by op.",
+		}, []string{"op"}),
+		latency: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Subsystem: "sqlitestore",
+			Name:      "operation_duration_seconds",
+			Help:      "Latency of session store operations by op.",
+			Buckets:   prometheus.ExponentialBuckets(0.0001, 4, 8),
+		}, []string{"op"}),
+	}
+}
+
+func (p *PrometheusMetrics) IncOp(op string) {
+	p.ops.WithLabelValues(op).Inc()
+}
+
+func (p *PrometheusMetrics) ObserveLatency(op string, d time.Duration) {
+	p.latency.WithLabelValues(op).Observe(d.Seconds())
+}
+
+func (p *PrometheusMetrics) IncError(op string) {
+	p.errors.WithLabelValues(op).Inc()
+}
+
+// Describe implements prometheus.Collector.
+func (p *PrometheusMetrics) Describe(ch chan<- *prometheus.Desc) {
+	p.ops.Describe(ch)
+	p.errors.Describe(ch)
+	p.latency.Describe(ch)
+}
+
+// Collect implements prometheus.Collector.
+func (p *PrometheusMetrics) Collect(ch chan<- prometheus.Metric) {
+	p.ops.Collect(ch)
+	p.errors.Collect(ch)
+	p.latency.Collect(ch)
+}
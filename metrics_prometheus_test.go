@@ -0,0 +1,39 @@
+//go:build prometheus
+// +build prometheus
+
+package sqlitestore
+
+import (
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPrometheusMetrics(t *testing.T) {
+	metrics := NewPrometheusMetrics("test")
+	registry := prometheus.NewRegistry()
+	require.NoError(t, registry.Register(metrics))
+	store := newTestStore(t, WithMetrics(metrics))
+
+	insertTestSession(t, store, nil)
+	_, err := store.DeleteExpired()
+	require.NoError(t, err)
+
+	families, err := registry.Gather()
+	require.NoError(t, err)
+	counts := map[string]uint64{}
+	for _, family := range families {
+		for _, metric := range family.GetMetric() {
+			op := metric.GetLabel()[0].GetValue()
+			switch family.GetName() {
+			case "test_sqlitestore_operations_total":
+				counts[op] = uint64(metric.GetCounter().GetValue())
+			case "test_sqlitestore_operation_duration_seconds":
+				assert.Equal(t, uint64(1), metric.GetHistogram().GetSampleCount(), op)
+			}
+		}
+	}
+	assert.Equal(t, map[string]uint64{"insert": 1, "delete_expired": 1}, counts)
+}
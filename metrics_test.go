@@ -0,0 +1,66 @@
+package sqlitestore
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gorilla/sessions"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type testMetrics struct {
+	mu        sync.Mutex
+	ops       map[string]int
+	errors    map[string]int
+	latencies map[string]int
+}
+
+func newTestMetrics() *testMetrics {
+	return &testMetrics{ops: map[string]int{}, errors: map[string]int{}, latencies: map[string]int{}}
+}
+
+func (t *testMetrics) IncOp(op string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.ops[op]++
+}
+
+func (t *testMetrics) ObserveLatency(op string, d time.Duration) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.latencies[op]++
+}
+
+func (t *testMetrics) IncError(op string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.errors[op]++
+}
+
+func TestMetrics(t *testing.T) {
+	metrics := newTestMetrics()
+	clock := &fakeClock{t: time.Unix(0, 0)}
+	store := newTestStore(t, WithMetrics(metrics), WithClock(clock.Now))
+	ctx := context.Background()
+
+	sess := insertTestSession(t, store, nil)
+	require.NoError(t, store.save(ctx, sess))
+	loaded := sessions.NewSession(store, "test")
+	loaded.ID = sess.ID
+	require.NoError(t, store.load(ctx, loaded))
+
+	clock.Advance(2 * time.Hour)
+	assert.Equal(t, SessionExpired, store.load(ctx, loaded))
+	_, err := store.DeleteExpired()
+	require.NoError(t, err)
+
+	loaded.ID = "not-a-number"
+	assert.Error(t, store.load(ctx, loaded))
+
+	assert.Equal(t, map[string]int{"insert": 1, "save": 1, "load": 3, "expire": 1, "delete_expired": 1}, metrics.ops)
+	assert.Equal(t, map[string]int{"insert": 1, "save": 1, "load": 3, "delete_expired": 1}, metrics.latencies)
+	assert.Equal(t, map[string]int{"load": 1}, metrics.errors)
+}
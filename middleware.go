@@ -0,0 +1,130 @@
+package sqlitestore
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"net"
+	"net/http"
+
+	"github.com/gorilla/sessions"
+)
+
+// TouchMiddleware calls Touch on the session name after each request the
+// wrapped handler serves, so every request extends the session without the
+// handler saving it. Requests without a stored session are left alone and
+// errors go to the handler set with WithErrorHandler. The cookie is not
+// rewritten, as the response has already been sent.
+func TouchMiddleware(store *Store, name string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			next.ServeHTTP(w, r)
+			session, err := store.Get(r, name)
+			if err != nil {
+				store.handleError(err)
+				return
+			}
+			if session.IsNew || session.ID == "" {
+				return
+			}
+			if err := store.Touch(session); err != nil && err != ErrSessionNotFound {
+				store.handleError(err)
+			}
+		})
+	}
+}
+
+// sessionKey is the request context key Middleware stores the session under.
+type sessionKey struct{}
+
+// SessionFromContext returns the session Middleware stored in ctx.
+func SessionFromContext(ctx context.Context) (*sessions.Session, bool) {
+	session, ok := ctx.Value(sessionKey{}).(*sessions.Session)
+	return session, ok
+}
+
+// Middleware gets the session name before each request, makes it available
+// to the wrapped handler through SessionFromContext and saves it afterwards,
+// so handlers don't have to call Save. The session is saved when the handler
+// starts writing the response, since the cookie must be sent with the
+// headers, or when it returns without writing anything; changes made after
+// the response is started are not saved. A session that expired meanwhile
+// has its cookie deleted and a warning logged. Other errors go to the
+// handler set with WithErrorHandler.
+func Middleware(store *Store, name string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			session, err := store.Get(r, name)
+			if err != nil {
+				// an undecodable cookie still yields a new session
+				store.handleError(err)
+			}
+			r = r.WithContext(context.WithValue(r.Context(), sessionKey{}, session))
+			sw := &savingWriter{ResponseWriter: w, store: store, r: r, session: session}
+			next.ServeHTTP(sw, r)
+			sw.save()
+		})
+	}
+}
+
+// savingWriter saves the session before the first byte of the response is
+// written.
+type savingWriter struct {
+	http.ResponseWriter
+	store   *Store
+	r       *http.Request
+	session *sessions.Session
+	saved   bool
+}
+
+func (w *savingWriter) save() {
+	if w.saved {
+		return
+	}
+	w.saved = true
+	err := w.store.Save(w.r, w.ResponseWriter, w.session)
+	switch {
+	case err == SessionExpired:
+		w.store.warn("sqlitestore: session expired before it was saved", "session_id", w.session.ID)
+		options := *w.session.Options
+		options.MaxAge = -1
+		http.SetCookie(w.ResponseWriter, sessions.NewCookie(w.session.Name(), "", &options))
+	case err != nil:
+		w.store.handleError(err)
+	}
+}
+
+func (w *savingWriter) WriteHeader(code int) {
+	w.save()
+	w.ResponseWriter.WriteHeader(code)
+}
+
+func (w *savingWriter) Write(b []byte) (int, error) {
+	w.save()
+	return w.ResponseWriter.Write(b)
+}
+
+// Flush implements http.Flusher if the wrapped writer does.
+func (w *savingWriter) Flush() {
+	w.save()
+	if f, ok := w.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// Hijack implements http.Hijacker if the wrapped writer does, so handlers
+// can upgrade the connection, for example to a WebSocket. The session is
+// saved first, as no headers can be sent afterwards.
+func (w *savingWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	h, ok := w.ResponseWriter.(http.Hijacker)
+	if !ok {
+		return nil, nil, errors.New("sqlitestore: response writer does not support hijacking")
+	}
+	w.save()
+	return h.Hijack()
+}
+
+// Unwrap returns the wrapped writer, for http.ResponseController.
+func (w *savingWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
@@ -0,0 +1,103 @@
+package sqlitestore
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTouchMiddleware(t *testing.T) {
+	store := newTestStore(t)
+	clock := &fakeClock{t: time.Date(2020, 1, 1, 12, 0, 0, 0, time.UTC)}
+	store.Clock = clock.Now
+
+	r := httptest.NewRequest("GET", "/", nil)
+	sess, err := store.New(r, "test")
+	require.NoError(t, err)
+	w := httptest.NewRecorder()
+	require.NoError(t, store.Save(r, w, sess))
+	maxAge := time.Duration(sess.Options.MaxAge) * time.Second
+
+	served := 0
+	handler := TouchMiddleware(store, "test")(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		served++
+	}))
+	clock.Advance(time.Hour)
+	r = httptest.NewRequest("GET", "/", nil)
+	r.Header.Add("Cookie", w.Header().Get("Set-Cookie"))
+	handler.ServeHTTP(httptest.NewRecorder(), r)
+	assert.Equal(t, 1, served)
+
+	row, err := store.getRow(r.Context(), sess.ID)
+	require.NoError(t, err)
+	assert.True(t, clock.t.Add(maxAge).Equal(row.expiresOn))
+
+	// without a session there is nothing to touch
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/", nil))
+	assert.Equal(t, 2, served)
+}
+
+func TestMiddleware(t *testing.T) {
+	store := newTestStore(t)
+	handler := Middleware(store, "test")(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		session, ok := SessionFromContext(r.Context())
+		require.True(t, ok)
+		n, _ := session.Values["n"].(int)
+		session.Values["n"] = n + 1
+		if r.URL.Path == "/write" {
+			w.Write([]byte("ok"))
+		}
+	}))
+
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest("GET", "/", nil))
+	cookie := w.Header().Get("Set-Cookie")
+	require.NotEmpty(t, cookie)
+
+	// the cookie is set even when the handler writes the response itself
+	r := httptest.NewRequest("GET", "/write", nil)
+	r.Header.Add("Cookie", cookie)
+	w = httptest.NewRecorder()
+	handler.ServeHTTP(w, r)
+	assert.Equal(t, "ok", w.Body.String())
+	assert.NotEmpty(t, w.Header().Get("Set-Cookie"))
+
+	r = httptest.NewRequest("GET", "/", nil)
+	r.Header.Add("Cookie", cookie)
+	session, err := store.New(r, "test")
+	require.NoError(t, err)
+	assert.False(t, session.IsNew)
+	assert.Equal(t, 2, session.Values["n"])
+
+	_, ok := SessionFromContext(r.Context())
+	assert.False(t, ok)
+}
+
+func TestMiddlewareHijack(t *testing.T) {
+	store := newTestStore(t)
+	handler := Middleware(store, "test")(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, ok := w.(interface{ Unwrap() http.ResponseWriter })
+		assert.True(t, ok)
+		conn, buf, err := w.(http.Hijacker).Hijack()
+		require.NoError(t, err)
+		defer conn.Close()
+		buf.WriteString("HTTP/1.1 101 Switching Protocols\r\nConnection: Upgrade\r\nUpgrade: test\r\n\r\n")
+		buf.Flush()
+	}))
+	srv := httptest.NewServer(handler)
+	defer srv.Close()
+
+	req, err := http.NewRequest("GET", srv.URL, nil)
+	require.NoError(t, err)
+	req.Header.Set("Connection", "Upgrade")
+	req.Header.Set("Upgrade", "test")
+	resp, err := http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusSwitchingProtocols, resp.StatusCode)
+	assert.Equal(t, 1, countRows(t, store))
+}
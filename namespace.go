@@ -0,0 +1,63 @@
+package sqlitestore
+
+import (
+	"errors"
+
+	"github.com/gorilla/sessions"
+)
+
+// AddSessionNamespace registers opts for sessions named namespace, so that
+// for example the control and treatment sessions of an A/B test can carry
+// different cookie settings and lifetimes. Sessions with other names keep
+// using the store's Options. Registering a namespace again replaces its
+// options.
+func (m *Store) AddSessionNamespace(namespace string, opts *sessions.Options) error {
+	if namespace == "" {
+		return errors.New("sqlitestore: namespace must not be empty")
+	}
+	if opts == nil {
+		return errors.New("sqlitestore: namespace options must not be nil")
+	}
+	copied := *opts
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.namespaces == nil {
+		m.namespaces = make(map[string]*sessions.Options)
+	}
+	m.namespaces[namespace] = &copied
+	return nil
+}
+
+// SetOptions sets the options of sessions named name, like
+// AddSessionNamespace. A nil opts makes them use the store's Options again.
+func (m *Store) SetOptions(name string, opts *sessions.Options) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if opts == nil {
+		delete(m.namespaces, name)
+		return
+	}
+	if m.namespaces == nil {
+		m.namespaces = make(map[string]*sessions.Options)
+	}
+	copied := *opts
+	m.namespaces[name] = &copied
+}
+
+// GetOptions returns a copy of the options new sessions named name get.
+func (m *Store) GetOptions(name string) *sessions.Options {
+	return m.newOptions(name)
+}
+
+// newOptions returns the options for a new session named name.
+func (m *Store) newOptions(name string) *sessions.Options {
+	m.mu.RLock()
+	ns := m.namespaces[name]
+	m.mu.RUnlock()
+	if ns != nil {
+		opts := *ns
+		return &opts
+	}
+	opts := *m.Options
+	return &opts
+}
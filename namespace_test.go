@@ -0,0 +1,68 @@
+package sqlitestore
+
+import (
+	"context"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gorilla/sessions"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSessionNamespaces(t *testing.T) {
+	store := newTestStore(t)
+	require.NoError(t, store.AddSessionNamespace("control", &sessions.Options{Path: "/", MaxAge: 60}))
+	require.NoError(t, store.AddSessionNamespace("treatment", &sessions.Options{Path: "/", MaxAge: 7200}))
+
+	for name, maxAge := range map[string]int{"control": 60, "treatment": 7200, "other": store.Options.MaxAge} {
+		r := httptest.NewRequest("GET", "/", nil)
+		sess, err := store.New(r, name)
+		require.NoError(t, err)
+		assert.Equal(t, maxAge, sess.Options.MaxAge, name)
+
+		w := httptest.NewRecorder()
+		require.NoError(t, store.Save(r, w, sess))
+		cookies := w.Result().Cookies()
+		require.Len(t, cookies, 1)
+		assert.Equal(t, maxAge, cookies[0].MaxAge, name)
+
+		var expiresOn time.Time
+		require.NoError(t, store.db.QueryRowContext(context.Background(),
+			"SELECT expires_on FROM sessions WHERE id = ?", sess.ID).Scan(&expiresOn))
+		assert.WithinDuration(t, time.Now().Add(time.Duration(maxAge)*time.Second), expiresOn, 5*time.Second, name)
+	}
+}
+
+func TestSetOptions(t *testing.T) {
+	store := newTestStore(t)
+	store.SetOptions("csrf", &sessions.Options{Path: "/", MaxAge: 300})
+	assert.Equal(t, 300, store.GetOptions("csrf").MaxAge)
+	assert.Equal(t, store.Options.MaxAge, store.GetOptions("user").MaxAge)
+
+	expires := make(map[string]time.Time)
+	for name, maxAge := range map[string]int{"csrf": 300, "user": store.Options.MaxAge} {
+		r := httptest.NewRequest("GET", "/", nil)
+		sess, err := store.New(r, name)
+		require.NoError(t, err)
+		w := httptest.NewRecorder()
+		require.NoError(t, store.Save(r, w, sess))
+		cookies := w.Result().Cookies()
+		require.Len(t, cookies, 1)
+		assert.Equal(t, maxAge, cookies[0].MaxAge, name)
+
+		var expiresOn time.Time
+		require.NoError(t, store.db.QueryRowContext(context.Background(),
+			"SELECT expires_on FROM sessions WHERE id = ?", sess.ID).Scan(&expiresOn))
+		expires[name] = expiresOn
+	}
+	assert.True(t, expires["csrf"].Before(expires["user"]))
+
+	// the returned options are a copy
+	store.GetOptions("csrf").MaxAge = 1
+	assert.Equal(t, 300, store.GetOptions("csrf").MaxAge)
+
+	store.SetOptions("csrf", nil)
+	assert.Equal(t, store.Options.MaxAge, store.GetOptions("csrf").MaxAge)
+}
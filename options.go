@@ -0,0 +1,777 @@
+package sqlitestore
+
+import (
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"database/sql"
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/gorilla/sessions"
+)
+
+// StoreOption configures a Store created with New.
+type StoreOption func(*storeConfig) error
+
+type storeConfig struct {
+	driverName  string
+	table       string
+	onOpen      func(*sql.DB) error
+	onClose     func(*sql.DB) error
+	pageSize    int
+	mmapSize    int64
+	mmapSet     bool
+	readOnly    bool
+	transformer ColumnTransformer
+	valueHook   ValueHook
+	timeouts    opTimeouts
+	tableOpts   TableOptions
+	schema      SchemaProvider
+	triggers    []string
+	maxLength   int
+	cookie      sessions.Options
+	trackDirty  bool
+	walMode     bool
+	clock       func() time.Time
+	metrics     Metrics
+	busyTimeout time.Duration
+
+	logger        Logger
+	slowThreshold time.Duration
+
+	versionCheck           bool
+	minVersion, maxVersion int
+
+	shards      int
+	noLocks     bool
+	lockTimeout time.Duration
+	onError     func(error)
+
+	reloadFetch    func(ctx context.Context) ([][]byte, error)
+	reloadInterval time.Duration
+
+	cleanupInterval time.Duration
+	indexRefresh    time.Duration
+	vacuumPages     int
+	analyzeEvery    int
+	gcProbability   float64
+
+	sliding     time.Duration
+	absolute    time.Duration
+	maxLifetime time.Duration
+
+	preSave   func(session *sessions.Session) error
+	hooks     Hooks
+	onRecover func(hookName string, recovered interface{})
+	atRest    cipher.AEAD
+
+	encryptedKeys []interface{}
+
+	prefix    string
+	userIDCol bool
+	tagging   bool
+
+	coalesceWindow time.Duration
+	chunked        bool
+	cacheSize      int
+	piiKeys        []interface{}
+
+	watchInterval time.Duration
+	onLost        func(error)
+	onRestored    func()
+}
+
+func defaultConfig() *storeConfig {
+	return &storeConfig{
+		driverName: defaultDriverName,
+		table:      defaultTableName,
+		cookie: sessions.Options{
+			Path:   "/",
+			MaxAge: 60 * 60 * 24 * 14,
+		},
+	}
+}
+
+func (c *storeConfig) locker() locker {
+	if c.noLocks {
+		return noopLocker{}
+	}
+	if c.shards > 0 {
+		return newShardedLocker(c.shards)
+	}
+	return &mutexLocker{}
+}
+
+// identifier matches the table and column names the store accepts from callers.
+var identifier = regexp.MustCompile(`^[A-Za-z0-9_]+$`)
+
+// WithTableName stores sessions in the table name instead of "sessions", so
+// that several stores or subsystems can share one database file. name may
+// only contain ASCII letters, digits and underscores.
+func WithTableName(name string) StoreOption {
+	return func(c *storeConfig) error {
+		if !identifier.MatchString(name) {
+			return fmt.Errorf("sqlitestore: invalid table name %q", name)
+		}
+		c.table = name
+		return nil
+	}
+}
+
+// WithShardedMutex replaces the single store mutex with shards mutexes,
+// selected by a hash of the session ID. shards must be a power of two.
+func WithShardedMutex(shards int) StoreOption {
+	return func(c *storeConfig) error {
+		if shards <= 0 || shards&(shards-1) != 0 {
+			return errors.New("sqlitestore: shards must be a positive power of two")
+		}
+		c.shards = shards
+		return nil
+	}
+}
+
+// WithDisabledMutex turns the store's session locking into no-ops, for
+// callers that already serialize every use of the store, for example behind
+// their own lock around the DB, and want to avoid locking twice.
+//
+// The caller is then solely responsible for synchronization: concurrent
+// Save, Delete or load calls for the same session can interleave and lose
+// writes. New logs a warning through the Logger, or the standard logger when
+// none is set, whenever this option is used.
+func WithDisabledMutex() StoreOption {
+	return func(c *storeConfig) error {
+		c.noLocks = true
+		return nil
+	}
+}
+
+// WithGlobalLockTimeout bounds how long store operations wait for a session
+// lock. An operation that cannot take its lock within d fails with
+// ErrLockTimeout instead of blocking indefinitely under contention.
+func WithGlobalLockTimeout(d time.Duration) StoreOption {
+	return func(c *storeConfig) error {
+		if d <= 0 {
+			return errors.New("sqlitestore: lock timeout must be positive")
+		}
+		c.lockTimeout = d
+		return nil
+	}
+}
+
+// WithErrorHandler registers fn to receive errors from background work, such
+// as hot key reloads, that have no caller to return them to.
+func WithErrorHandler(fn func(error)) StoreOption {
+	return func(c *storeConfig) error {
+		c.onError = fn
+		return nil
+	}
+}
+
+// WithHotReload starts a goroutine that calls fetch every interval and
+// replaces the store codecs whenever the returned key pairs change.
+func WithHotReload(fetch func(ctx context.Context) ([][]byte, error), interval time.Duration) StoreOption {
+	return func(c *storeConfig) error {
+		if fetch == nil {
+			return errors.New("sqlitestore: hot reload requires a fetch function")
+		}
+		if interval <= 0 {
+			return errors.New("sqlitestore: hot reload interval must be positive")
+		}
+		c.reloadFetch = fetch
+		c.reloadInterval = interval
+		return nil
+	}
+}
+
+// WithCleanupInterval starts a goroutine that deletes expired sessions every
+// interval.
+func WithCleanupInterval(interval time.Duration) StoreOption {
+	return func(c *storeConfig) error {
+		if interval <= 0 {
+			return errors.New("sqlitestore: cleanup interval must be positive")
+		}
+		c.cleanupInterval = interval
+		return nil
+	}
+}
+
+// WithIncrementalVacuumAfterGC makes the background cleanup goroutine run
+// IncrementalVacuum with the given page count after each pass.
+func WithIncrementalVacuumAfterGC(pages int) StoreOption {
+	return func(c *storeConfig) error {
+		if pages <= 0 {
+			return errors.New("sqlitestore: vacuum page count must be positive")
+		}
+		c.vacuumPages = pages
+		return nil
+	}
+}
+
+// WithAutoGCOnInsert makes each insert start a GarbageCollect in the
+// background with the given probability, in (0, 1], spreading cleanup over
+// request traffic when the cleanup goroutine falls behind. With 0.01 about
+// one insert in a hundred collects.
+func WithAutoGCOnInsert(probability float64) StoreOption {
+	return func(c *storeConfig) error {
+		if probability <= 0 || probability > 1 {
+			return fmt.Errorf("sqlitestore: invalid GC probability %v", probability)
+		}
+		c.gcProbability = probability
+		return nil
+	}
+}
+
+// WithLRUCache keeps up to capacity recently loaded session rows in memory,
+// so repeated loads of the same session skip the database. The cache is kept
+// up to date by the store's own writes and deletes; rows changed directly in
+// the database may be served stale until they are evicted or FlushCache is
+// called.
+func WithLRUCache(capacity int) StoreOption {
+	return func(c *storeConfig) error {
+		if capacity <= 0 {
+			return errors.New("sqlitestore: cache capacity must be positive")
+		}
+		c.cacheSize = capacity
+		return nil
+	}
+}
+
+// WithAnalyzeAfterNCleanups makes the background cleanup goroutine run
+// Analyze after every n passes, keeping query planner statistics current as
+// sessions come and go.
+func WithAnalyzeAfterNCleanups(n int) StoreOption {
+	return func(c *storeConfig) error {
+		if n <= 0 {
+			return errors.New("sqlitestore: analyze cleanup count must be positive")
+		}
+		c.analyzeEvery = n
+		return nil
+	}
+}
+
+// WithConnectionWatcher starts a goroutine that pings the database every
+// interval. While pings fail, store operations return ErrConnectionLost
+// without touching the database. onLost and onRestored, when not nil, are
+// called as the connection changes state.
+func WithConnectionWatcher(interval time.Duration, onLost func(error), onRestored func()) StoreOption {
+	return func(c *storeConfig) error {
+		if interval <= 0 {
+			return errors.New("sqlitestore: watcher interval must be positive")
+		}
+		c.watchInterval = interval
+		c.onLost = onLost
+		c.onRestored = onRestored
+		return nil
+	}
+}
+
+// WithSlidingExpiration extends a session's expiry to sliding past each load,
+// but never beyond absolute past its creation.
+func WithSlidingExpiration(sliding, absolute time.Duration) StoreOption {
+	return func(c *storeConfig) error {
+		if sliding <= 0 || absolute < sliding {
+			return errors.New("sqlitestore: sliding expiration requires 0 < sliding <= absolute")
+		}
+		c.sliding = sliding
+		c.absolute = absolute
+		return nil
+	}
+}
+
+// WithAbsoluteExpiry caps every session's expiry at maxLifetime past its
+// creation, however often it is saved or, with WithSlidingExpiration, loaded.
+func WithAbsoluteExpiry(maxLifetime time.Duration) StoreOption {
+	return func(c *storeConfig) error {
+		if maxLifetime <= 0 {
+			return errors.New("sqlitestore: maximum session lifetime must be positive")
+		}
+		c.maxLifetime = maxLifetime
+		return nil
+	}
+}
+
+// WithPreSaveHook registers fn to run before a session is written. A non-nil
+// error cancels the save and is returned wrapped in ErrPreSaveHookRejected.
+func WithPreSaveHook(fn func(session *sessions.Session) error) StoreOption {
+	return func(c *storeConfig) error {
+		c.preSave = fn
+		return nil
+	}
+}
+
+// WithHooks sets Store.Hooks. Options that set a single hook, such as
+// WithOnDelete, override the matching field when they come later.
+func WithHooks(hooks Hooks) StoreOption {
+	return func(c *storeConfig) error {
+		c.hooks = hooks
+		return nil
+	}
+}
+
+// WithOnDelete sets Hooks.OnDelete, called with the ID of every session the
+// store deletes on request, through Delete or the bulk delete methods.
+// Expired sessions removed by garbage collection are not reported.
+func WithOnDelete(fn func(id string)) StoreOption {
+	return func(c *storeConfig) error {
+		c.hooks.OnDelete = fn
+		return nil
+	}
+}
+
+// WithOnLoad sets Hooks.OnLoadRow, called synchronously with the raw row of
+// every session the store loads successfully, for last-access logging or
+// anomaly detection. The row's session data is still encoded.
+func WithOnLoad(fn func(ctx context.Context, sess *sessionRow)) StoreOption {
+	return func(c *storeConfig) error {
+		c.hooks.OnLoadRow = fn
+		return nil
+	}
+}
+
+// WithEncryptionAtRest encrypts session data with AES-256-GCM before it is
+// written to the database. key must be 32 bytes. Rows written without
+// encryption remain readable, so the option can be enabled on a live store.
+func WithEncryptionAtRest(key []byte) StoreOption {
+	return func(c *storeConfig) error {
+		if len(key) != 32 {
+			return errors.New("sqlitestore: encryption at rest requires a 32 byte key")
+		}
+		block, err := aes.NewCipher(key)
+		if err != nil {
+			return err
+		}
+		if c.atRest, err = cipher.NewGCM(block); err != nil {
+			return err
+		}
+		return nil
+	}
+}
+
+// WithUserIDColumn adds a user_id column to the sessions table, written from
+// the value set with SetUserID, so sessions can be managed per user.
+func WithUserIDColumn() StoreOption {
+	return func(c *storeConfig) error {
+		c.userIDCol = true
+		return nil
+	}
+}
+
+// WithPIIKeys registers the session value keys that AnonymizeSessions
+// removes.
+func WithPIIKeys(keys ...interface{}) StoreOption {
+	return func(c *storeConfig) error {
+		c.piiKeys = append(c.piiKeys, keys...)
+		return nil
+	}
+}
+
+// WithCustomDriverName makes Open register the SQLite driver under name and
+// open the database with it, so the store does not clash with other packages
+// that register their own go-sqlite3 variants.
+func WithCustomDriverName(name string) StoreOption {
+	return func(c *storeConfig) error {
+		if name == "" {
+			return errors.New("sqlitestore: driver name must not be empty")
+		}
+		c.driverName = name
+		return nil
+	}
+}
+
+// WithConnectionLifecycleHooks registers onOpen to run on the database Open
+// creates, before the store uses it, and onClose to run just before Close
+// closes it. onOpen is the place to load extensions or set pragmas; if it
+// fails, Open fails. Either hook may be nil.
+func WithConnectionLifecycleHooks(onOpen func(*sql.DB) error, onClose func(*sql.DB) error) StoreOption {
+	return func(c *storeConfig) error {
+		c.onOpen = onOpen
+		c.onClose = onClose
+		return nil
+	}
+}
+
+// WithRecoveryCallback recovers panics raised by user supplied hooks. fn is
+// called with the hook name and the recovered value, and the operation
+// continues as if the hook had returned normally.
+func WithRecoveryCallback(fn func(hookName string, recovered interface{})) StoreOption {
+	return func(c *storeConfig) error {
+		c.onRecover = fn
+		return nil
+	}
+}
+
+// WithSessionTagging adds an indexed tags column to the sessions table so
+// sessions can be labelled with TagSession and found or deleted by label.
+func WithSessionTagging() StoreOption {
+	return func(c *storeConfig) error {
+		c.tagging = true
+		return nil
+	}
+}
+
+// WithChunkedValueStorage stores each session value as its own row in a
+// session_values table instead of encoding all values into session_data, so
+// a save only rewrites the values that changed since the session was loaded.
+// This cuts write I/O for large sessions where a request changes one key.
+//
+// Load records the loaded values under an unexported key in session.Values
+// to detect the changes. Maintenance methods that read session_data
+// directly, such as LoadBatch, the JSON export and import and
+// CopySessionsTo, do not see the values. It cannot be combined with
+// WithWriteCoalescing.
+func WithChunkedValueStorage() StoreOption {
+	return func(c *storeConfig) error {
+		c.chunked = true
+		return nil
+	}
+}
+
+// WithWriteCoalescing delays updates of existing sessions by window and
+// writes only the last update made to a session within it. Loads see the
+// pending data. Pending writes are flushed by Close and GracefulClose.
+func WithWriteCoalescing(window time.Duration) StoreOption {
+	return func(c *storeConfig) error {
+		if window <= 0 {
+			return errors.New("sqlitestore: coalescing window must be positive")
+		}
+		c.coalesceWindow = window
+		return nil
+	}
+}
+
+// WithEncryptedValues encrypts the values stored under keys with AES-256-GCM
+// before the session is encoded, leaving other values readable to anyone
+// holding the codecs. The encryption key is derived from the first hash key
+// the store is created with.
+func WithEncryptedValues(keys ...interface{}) StoreOption {
+	return func(c *storeConfig) error {
+		c.encryptedKeys = append(c.encryptedKeys, keys...)
+		return nil
+	}
+}
+
+// WithIDPrefix namespaces the store's session IDs as "<prefix>:<id>", so
+// cookies issued by stores with different prefixes never resolve to each
+// other's rows. prefix must not contain ":".
+func WithIDPrefix(prefix string) StoreOption {
+	return func(c *storeConfig) error {
+		if prefix == "" || strings.Contains(prefix, ":") {
+			return errors.New("sqlitestore: ID prefix must be non-empty and must not contain \":\"")
+		}
+		c.prefix = prefix + ":"
+		return nil
+	}
+}
+
+// WithReadOnly creates a store that only reads sessions, for example from a
+// replica. Open opens the database file in read-only mode, no schema changes
+// or write statements are prepared, and Save and Delete return ErrReadOnly.
+func WithReadOnly() StoreOption {
+	return func(c *storeConfig) error {
+		c.readOnly = true
+		return nil
+	}
+}
+
+// WithColumnTransformer registers ct to convert column values as they are
+// written to and read from the sessions table, for example to store
+// timestamps as Unix integers with UnixTimestampTransformer.
+func WithColumnTransformer(ct ColumnTransformer) StoreOption {
+	return func(c *storeConfig) error {
+		if ct == nil {
+			return errors.New("sqlitestore: column transformer must not be nil")
+		}
+		c.transformer = ct
+		return nil
+	}
+}
+
+// WithSessionValueHook registers hook to see every session value as sessions
+// are written and loaded.
+func WithSessionValueHook(hook ValueHook) StoreOption {
+	return func(c *storeConfig) error {
+		if hook == nil {
+			return errors.New("sqlitestore: value hook must not be nil")
+		}
+		c.valueHook = hook
+		return nil
+	}
+}
+
+// WithStatementTimeout bounds every statement the store runs by d. A shorter
+// deadline already set on the caller's context still applies.
+func WithStatementTimeout(d time.Duration) StoreOption {
+	return func(c *storeConfig) error {
+		if d <= 0 {
+			return errors.New("sqlitestore: statement timeout must be positive")
+		}
+		c.timeouts.all = d
+		return nil
+	}
+}
+
+// WithInsertTimeout overrides the statement timeout for inserts and updates.
+func WithInsertTimeout(d time.Duration) StoreOption {
+	return func(c *storeConfig) error {
+		if d <= 0 {
+			return errors.New("sqlitestore: insert timeout must be positive")
+		}
+		c.timeouts.insert = d
+		return nil
+	}
+}
+
+// WithSelectTimeout overrides the statement timeout for session loads.
+func WithSelectTimeout(d time.Duration) StoreOption {
+	return func(c *storeConfig) error {
+		if d <= 0 {
+			return errors.New("sqlitestore: select timeout must be positive")
+		}
+		c.timeouts.sel = d
+		return nil
+	}
+}
+
+// WithDeleteTimeout overrides the statement timeout for deletes, including
+// garbage collection.
+func WithDeleteTimeout(d time.Duration) StoreOption {
+	return func(c *storeConfig) error {
+		if d <= 0 {
+			return errors.New("sqlitestore: delete timeout must be positive")
+		}
+		c.timeouts.delete = d
+		return nil
+	}
+}
+
+// WithDatabaseVersionCheck makes New fail with ErrIncompatibleSchemaVersion
+// when PRAGMA user_version is outside [minVersion, maxVersion], so a binary
+// never opens, or migrates, a database written by an incompatible version.
+// The check runs before any migration; a new database has version 0.
+func WithDatabaseVersionCheck(minVersion, maxVersion int) StoreOption {
+	return func(c *storeConfig) error {
+		if minVersion < 0 || maxVersion < minVersion {
+			return errors.New("sqlitestore: version check requires 0 <= minVersion <= maxVersion")
+		}
+		c.versionCheck = true
+		c.minVersion = minVersion
+		c.maxVersion = maxVersion
+		return nil
+	}
+}
+
+// WithLogger sets the logger the store writes warnings to.
+func WithLogger(l Logger) StoreOption {
+	return func(c *storeConfig) error {
+		c.logger = l
+		return nil
+	}
+}
+
+// WithSlowQueryThreshold times every query the store runs and logs a warning
+// for each one that takes longer than d.
+func WithSlowQueryThreshold(d time.Duration) StoreOption {
+	return func(c *storeConfig) error {
+		if d <= 0 {
+			return errors.New("sqlitestore: slow query threshold must be positive")
+		}
+		c.slowThreshold = d
+		return nil
+	}
+}
+
+// WithTableOptions sets the table variant and extra columns New uses when it
+// creates the sessions table.
+func WithTableOptions(opts TableOptions) StoreOption {
+	return func(c *storeConfig) error {
+		for _, col := range opts.ExtraColumns {
+			if col.Name == "" || col.Type == "" {
+				return errors.New("sqlitestore: extra columns need a name and a type")
+			}
+		}
+		c.tableOpts = opts
+		return nil
+	}
+}
+
+// WithSchemaProvider makes New create the sessions table with the DDL from
+// sp instead of the built-in schema. TableOptions are then only used by
+// MigrateSchema to add extra columns missing from the table.
+func WithSchemaProvider(sp SchemaProvider) StoreOption {
+	return func(c *storeConfig) error {
+		if sp == nil {
+			return errors.New("sqlitestore: schema provider must not be nil")
+		}
+		c.schema = sp
+		return nil
+	}
+}
+
+// WithInsertTrigger adds an AFTER INSERT trigger on the sessions table that
+// runs triggerSQL, one or more SQL statements, for each inserted session,
+// for example to maintain a per-user session count. NEW refers to the
+// inserted row. New checks that the trigger compiles before creating it and
+// replaces a trigger left by an earlier configuration.
+func WithInsertTrigger(triggerSQL string) StoreOption {
+	return func(c *storeConfig) error {
+		if strings.TrimSpace(triggerSQL) == "" {
+			return errors.New("sqlitestore: insert trigger SQL must not be empty")
+		}
+		c.triggers = append(c.triggers, triggerSQL)
+		return nil
+	}
+}
+
+// WithPageSize sets PRAGMA page_size before the sessions table is created.
+// bytes must be a power of two between 512 and 65536. It has no effect on an
+// existing database, whose page size can only be changed by VACUUM.
+func WithPageSize(bytes int) StoreOption {
+	return func(c *storeConfig) error {
+		if bytes < 512 || bytes > 65536 || bytes&(bytes-1) != 0 {
+			return fmt.Errorf("sqlitestore: invalid page size %d", bytes)
+		}
+		c.pageSize = bytes
+		return nil
+	}
+}
+
+// WithMmapSize sets PRAGMA mmap_size so SQLite reads the database through
+// memory-mapped I/O of up to bytes; 0 disables it. 256 MB (268435456) suits
+// most session stores.
+func WithMmapSize(bytes int64) StoreOption {
+	return func(c *storeConfig) error {
+		if bytes < 0 {
+			return errors.New("sqlitestore: mmap size must not be negative")
+		}
+		c.mmapSize = bytes
+		c.mmapSet = true
+		return nil
+	}
+}
+
+// WithValueIndexRefresh starts a goroutine that rebuilds the value indexes
+// created with CreateValueIndex every interval.
+func WithValueIndexRefresh(interval time.Duration) StoreOption {
+	return func(c *storeConfig) error {
+		if interval <= 0 {
+			return errors.New("sqlitestore: value index refresh interval must be positive")
+		}
+		c.indexRefresh = interval
+		return nil
+	}
+}
+
+// WithMaxLength rejects session data longer than n bytes once encoded with
+// ErrSessionTooLarge, both when it is saved and when it is loaded. It
+// replaces the 4096 byte limit securecookie applies by default.
+func WithMaxLength(n int) StoreOption {
+	return func(c *storeConfig) error {
+		if n <= 0 {
+			return errors.New("sqlitestore: max length must be positive")
+		}
+		c.maxLength = n
+		return nil
+	}
+}
+
+// WithMaxAge sets the lifetime of new sessions and their cookies in seconds.
+// The default is 14 days.
+func WithMaxAge(seconds int) StoreOption {
+	return func(c *storeConfig) error {
+		if seconds <= 0 {
+			return errors.New("sqlitestore: max age must be positive")
+		}
+		c.cookie.MaxAge = seconds
+		return nil
+	}
+}
+
+// WithPath sets the path of session cookies. The default is "/".
+func WithPath(cookiePath string) StoreOption {
+	return func(c *storeConfig) error {
+		c.cookie.Path = cookiePath
+		return nil
+	}
+}
+
+// WithDomain sets the domain of session cookies.
+func WithDomain(domain string) StoreOption {
+	return func(c *storeConfig) error {
+		c.cookie.Domain = domain
+		return nil
+	}
+}
+
+// WithSecure sets the Secure attribute of session cookies.
+func WithSecure(secure bool) StoreOption {
+	return func(c *storeConfig) error {
+		c.cookie.Secure = secure
+		return nil
+	}
+}
+
+// WithHTTPOnly sets the HttpOnly attribute of session cookies.
+func WithHTTPOnly(httpOnly bool) StoreOption {
+	return func(c *storeConfig) error {
+		c.cookie.HttpOnly = httpOnly
+		return nil
+	}
+}
+
+// WithDirtyTracking sets Store.TrackDirty, so saving a loaded session whose
+// values have not changed does not rewrite its data. With SlidingExpiry the
+// expiry is still moved, as Touch does; without it nothing is written.
+func WithDirtyTracking() StoreOption {
+	return func(c *storeConfig) error {
+		c.trackDirty = true
+		return nil
+	}
+}
+
+// WithWALMode switches the database to write-ahead logging, which lets
+// readers proceed while a session is written. A database that can't be
+// switched, such as an in-memory one, keeps its journal mode and a warning
+// is logged. Open also sets it in the DSN so every connection uses it.
+func WithWALMode() StoreOption {
+	return func(c *storeConfig) error {
+		c.walMode = true
+		return nil
+	}
+}
+
+// WithBusyTimeout makes writers wait up to d for a locked database instead
+// of failing with SQLITE_BUSY. busy_timeout is set per connection: Open sets
+// it in the DSN so every connection uses it, while New can only set it on
+// one connection of the pool, so stores created with New should pass
+// _busy_timeout in the DSN or limit the pool to one connection.
+func WithBusyTimeout(d time.Duration) StoreOption {
+	return func(c *storeConfig) error {
+		if d <= 0 {
+			return errors.New("sqlitestore: busy timeout must be positive")
+		}
+		c.busyTimeout = d
+		return nil
+	}
+}
+
+// WithClock sets Store.Clock, the source of the current time used for
+// expiry. It lets tests move time forward without sleeping.
+func WithClock(f func() time.Time) StoreOption {
+	return func(c *storeConfig) error {
+		c.clock = f
+		return nil
+	}
+}
+
+// WithMetrics reports the count, latency and errors of every insert, save,
+// load, delete and expired session cleanup to metrics.
+func WithMetrics(metrics Metrics) StoreOption {
+	return func(c *storeConfig) error {
+		c.metrics = metrics
+		return nil
+	}
+}
@@ -0,0 +1,131 @@
+package sqlitestore
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/gorilla/sessions"
+)
+
+// pgTableName matches the, optionally schema qualified, table names
+// ConvertFromPGStore accepts.
+var pgTableName = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*(\.[A-Za-z_][A-Za-z0-9_]*)?$`)
+
+// ConvertFromPGStore copies the unexpired sessions in pgTable, a table
+// written by the Gorilla pgstore backend, into dst with their IDs and
+// timestamps preserved, so users stay logged in across a move from
+// PostgreSQL. dst must use the same keys as the pgstore did.
+//
+// pgstore does not record session names, which securecookie binds into the
+// encoded data, so every cookie name the application uses has to be
+// registered with dst.AddSessionNamespace first; each row is decoded under
+// the registered names in turn. Rows that decode under none of them are
+// reported to dst's error handler and skipped. It returns the number of
+// sessions migrated.
+func ConvertFromPGStore(ctx context.Context, pgDB *sql.DB, pgTable string, dst *Store) (int64, error) {
+	if dst.readOnly {
+		return 0, ErrReadOnly
+	}
+	if !pgTableName.MatchString(pgTable) {
+		return 0, fmt.Errorf("sqlitestore: invalid table name %q", pgTable)
+	}
+	names := dst.namespaceNames()
+	if len(names) == 0 {
+		return 0, errors.New("sqlitestore: no session names registered with AddSessionNamespace")
+	}
+
+	rows, err := pgDB.QueryContext(ctx, "SELECT id, session_data, created_on, modified_on, expires_on FROM "+
+		pgTable+" WHERE expires_on > $1", time.Now())
+	if err != nil {
+		return 0, err
+	}
+	defer rows.Close()
+
+	cols := dst.insertColumns()
+	insQ := "INSERT OR REPLACE INTO " + dst.table + " (id, " + strings.Join(cols, ", ") +
+		") VALUES (?" + strings.Repeat(", ?", len(cols)) + ")"
+	var migrated int64
+	for rows.Next() {
+		var sess sessionRow
+		if err := rows.Scan(&sess.id, &sess.data, &sess.createdOn, &sess.modifiedOn, &sess.expiresOn); err != nil {
+			return migrated, err
+		}
+		session := dst.decodeNamed(names, sess.data)
+		if session == nil {
+			dst.handleError(fmt.Errorf("sqlitestore: cannot decode pgstore session %s", sess.id))
+			continue
+		}
+		session.Values["created_on"] = sess.createdOn
+		session.Values["expires_on"] = sess.expiresOn
+		args, err := dst.insertArgs(session)
+		if err != nil {
+			dst.handleError(err)
+			continue
+		}
+		args[2] = sess.modifiedOn
+		args = append([]interface{}{sess.id}, dst.transformArgs(cols, args)...)
+		session.ID = dst.prefix + sess.id
+		// a pending coalesced write would overwrite the converted row
+		dst.cancelWrite(session.ID)
+		if err := dst.insertConverted(ctx, insQ, args, session); err != nil {
+			return migrated, err
+		}
+		dst.cache.remove(session.ID)
+		migrated++
+	}
+	return migrated, rows.Err()
+}
+
+// insertConverted writes a converted session with insQ and args and, with
+// chunked storage, its values to the values table in the same transaction.
+func (m *Store) insertConverted(ctx context.Context, insQ string, args []interface{}, session *sessions.Session) (err error) {
+	if !m.chunked {
+		_, err = m.db.ExecContext(ctx, insQ, args...)
+		return err
+	}
+	tx, err := m.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err != nil {
+			tx.Rollback()
+		}
+	}()
+	if _, err = tx.ExecContext(ctx, insQ, args...); err != nil {
+		return err
+	}
+	if err = m.writeChunks(ctx, tx, session); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// decodeNamed decodes data as a session under the first of names that
+// accepts it, or returns nil.
+func (m *Store) decodeNamed(names []string, data string) *sessions.Session {
+	for _, name := range names {
+		session := sessions.NewSession(m, name)
+		session.Options = m.newOptions(name)
+		if m.decodeValues(session, data) == nil {
+			return session
+		}
+	}
+	return nil
+}
+
+// namespaceNames returns the names registered with AddSessionNamespace.
+func (m *Store) namespaceNames() []string {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	names := make([]string, 0, len(m.namespaces))
+	for name := range m.namespaces {
+		names = append(names, name)
+	}
+	return names
+}
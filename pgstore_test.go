@@ -0,0 +1,98 @@
+package sqlitestore
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/gorilla/securecookie"
+	"github.com/gorilla/sessions"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestConvertFromPGStore(t *testing.T) {
+	keys := [][]byte{securecookie.GenerateRandomKey(32)}
+	srcDB := newTestDB(t)
+	src, err := New(srcDB, keys)
+	require.NoError(t, err)
+	t.Cleanup(src.Close)
+	dst, err := New(newTestDB(t), keys)
+	require.NoError(t, err)
+	t.Cleanup(dst.Close)
+
+	now := time.Now()
+	var live []string
+	for i := 0; i < 10; i++ {
+		live = append(live, insertTestSession(t, src, map[interface{}]interface{}{
+			"n":          i,
+			"created_on": now.Add(-time.Duration(i) * time.Minute),
+		}).ID)
+	}
+	insertTestSession(t, src, map[interface{}]interface{}{"expires_on": now.Add(-time.Hour)})
+
+	_, err = ConvertFromPGStore(context.Background(), srcDB, "sessions", dst)
+	assert.Error(t, err)
+
+	require.NoError(t, dst.AddSessionNamespace("test", &sessions.Options{MaxAge: 3600}))
+	n, err := ConvertFromPGStore(context.Background(), srcDB, "sessions", dst)
+	require.NoError(t, err)
+	assert.Equal(t, int64(10), n)
+	assert.Equal(t, 10, countRows(t, dst))
+
+	for i, id := range live {
+		orig := loadTestSession(t, src, id)
+		migrated := loadTestSession(t, dst, id)
+		assert.Equal(t, i, migrated.Values["n"])
+		for _, key := range []string{"created_on", "modified_on", "expires_on"} {
+			assert.True(t, orig.Values[key].(time.Time).Equal(migrated.Values[key].(time.Time)), key)
+		}
+	}
+
+	_, err = ConvertFromPGStore(context.Background(), srcDB, "sessions; DROP TABLE sessions", dst)
+	assert.Error(t, err)
+}
+
+func TestConvertFromPGStorePendingWrite(t *testing.T) {
+	keys := [][]byte{securecookie.GenerateRandomKey(32)}
+	srcDB := newTestDB(t)
+	src, err := New(srcDB, keys)
+	require.NoError(t, err)
+	t.Cleanup(src.Close)
+	dst, err := New(newTestDB(t), keys, WithWriteCoalescing(time.Hour))
+	require.NoError(t, err)
+	t.Cleanup(dst.Close)
+	require.NoError(t, dst.AddSessionNamespace("test", &sessions.Options{MaxAge: 3600}))
+	ctx := context.Background()
+
+	id := insertTestSession(t, src, map[interface{}]interface{}{"v": "pg"}).ID
+	sess := insertTestSession(t, dst, map[interface{}]interface{}{"v": "a"})
+	require.Equal(t, id, sess.ID)
+	sess.IsNew = false
+	sess.Values["v"] = "b"
+	require.NoError(t, dst.save(ctx, sess))
+
+	n, err := ConvertFromPGStore(ctx, srcDB, "sessions", dst)
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), n)
+	require.NoError(t, dst.flushWrites(ctx))
+	assert.Equal(t, "pg", loadTestSession(t, dst, id).Values["v"])
+}
+
+func TestConvertFromPGStoreChunked(t *testing.T) {
+	keys := [][]byte{securecookie.GenerateRandomKey(32)}
+	srcDB := newTestDB(t)
+	src, err := New(srcDB, keys)
+	require.NoError(t, err)
+	t.Cleanup(src.Close)
+	dst, err := New(newTestDB(t), keys, WithChunkedValueStorage())
+	require.NoError(t, err)
+	t.Cleanup(dst.Close)
+	require.NoError(t, dst.AddSessionNamespace("test", &sessions.Options{MaxAge: 3600}))
+
+	id := insertTestSession(t, src, map[interface{}]interface{}{"user": "alice"}).ID
+	n, err := ConvertFromPGStore(context.Background(), srcDB, "sessions", dst)
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), n)
+	assert.Equal(t, "alice", loadTestSession(t, dst, id).Values["user"])
+}
@@ -0,0 +1,170 @@
+package sqlitestore
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+var checkpointModes = map[string]bool{
+	"PASSIVE":  true,
+	"FULL":     true,
+	"RESTART":  true,
+	"TRUNCATE": true,
+}
+
+// CheckpointWAL runs PRAGMA wal_checkpoint in the given mode (PASSIVE, FULL,
+// RESTART or TRUNCATE) and returns the number of frames in the WAL and the
+// number of frames checkpointed into the database.
+func (m *Store) CheckpointWAL(ctx context.Context, mode string) (pagesWritten, pagesMovedToWAL int, err error) {
+	mode = strings.ToUpper(mode)
+	if !checkpointModes[mode] {
+		return 0, 0, fmt.Errorf("sqlitestore: invalid checkpoint mode %q", mode)
+	}
+	var busy int
+	row := m.db.QueryRowContext(ctx, "PRAGMA wal_checkpoint("+mode+")")
+	if err := row.Scan(&busy, &pagesWritten, &pagesMovedToWAL); err != nil {
+		return 0, 0, err
+	}
+	return pagesWritten, pagesMovedToWAL, nil
+}
+
+// IncrementalVacuum reclaims up to pages free pages from the database file.
+// It only has an effect when the database uses auto_vacuum = INCREMENTAL.
+func (m *Store) IncrementalVacuum(ctx context.Context, pages int) error {
+	if pages < 0 {
+		return fmt.Errorf("sqlitestore: invalid page count %d", pages)
+	}
+	// the pragma frees one page per step, so it has to be drained as a query
+	rows, err := m.db.QueryContext(ctx, fmt.Sprintf("PRAGMA incremental_vacuum(%d)", pages))
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	for rows.Next() {
+	}
+	return rows.Err()
+}
+
+// pragmaName matches the pragma names GetPragma accepts.
+var pragmaName = regexp.MustCompile(`^[A-Za-z_]+$`)
+
+// GetPragma returns the current value of the named pragma, such as
+// journal_mode or auto_vacuum, as SQLite reports it.
+func (m *Store) GetPragma(ctx context.Context, name string) (string, error) {
+	if !pragmaName.MatchString(name) {
+		return "", fmt.Errorf("sqlitestore: invalid pragma name %q", name)
+	}
+	var value string
+	if err := m.db.QueryRowContext(ctx, "PRAGMA "+name).Scan(&value); err != nil {
+		return "", err
+	}
+	return value, nil
+}
+
+var autoVacuumModes = map[string]int{
+	"NONE":        0,
+	"FULL":        1,
+	"INCREMENTAL": 2,
+}
+
+// EnableAutoVacuum sets PRAGMA auto_vacuum to mode (NONE, FULL or
+// INCREMENTAL). Switching to or from NONE only takes effect after the
+// database is rebuilt, so EnableAutoVacuum runs VACUUM in that case, which
+// can take a while on large databases.
+func (m *Store) EnableAutoVacuum(ctx context.Context, mode string) error {
+	mode = strings.ToUpper(mode)
+	want, ok := autoVacuumModes[mode]
+	if !ok {
+		return fmt.Errorf("sqlitestore: invalid auto_vacuum mode %q", mode)
+	}
+	var current int
+	if err := m.db.QueryRowContext(ctx, "PRAGMA auto_vacuum").Scan(&current); err != nil {
+		return err
+	}
+	if current == want {
+		return nil
+	}
+	if _, err := m.db.ExecContext(ctx, "PRAGMA auto_vacuum = "+mode); err != nil {
+		return err
+	}
+	if current == 0 || want == 0 {
+		_, err := m.db.ExecContext(ctx, "VACUUM")
+		return err
+	}
+	return nil
+}
+
+// ConvertToWAL switches the database to write-ahead logging. The change is
+// persistent and safe on a live database. It is a no-op if the database is
+// already in WAL mode.
+func (m *Store) ConvertToWAL(ctx context.Context) error {
+	mode, err := m.GetPragma(ctx, "journal_mode")
+	if err != nil {
+		return err
+	}
+	if strings.EqualFold(mode, "wal") {
+		return nil
+	}
+	if err := m.db.QueryRowContext(ctx, "PRAGMA journal_mode = WAL").Scan(&mode); err != nil {
+		return err
+	}
+	if !strings.EqualFold(mode, "wal") {
+		return fmt.Errorf("sqlitestore: journal mode is %q after switching to WAL", mode)
+	}
+	return nil
+}
+
+// reloadablePragmas are the pragmas ReloadPragmas may change on a live
+// database.
+var reloadablePragmas = map[string]bool{
+	"busy_timeout":       true,
+	"cache_size":         true,
+	"cache_spill":        true,
+	"journal_size_limit": true,
+	"mmap_size":          true,
+	"synchronous":        true,
+	"temp_store":         true,
+	"wal_autocheckpoint": true,
+}
+
+// pragmaValue matches the values ReloadPragmas accepts.
+var pragmaValue = regexp.MustCompile(`^-?[A-Za-z0-9_]+$`)
+
+// ErrUnsafePragma is returned by ReloadPragmas for a pragma it does not allow
+// changing at runtime.
+type ErrUnsafePragma struct {
+	Key string
+}
+
+func (e ErrUnsafePragma) Error() string {
+	return fmt.Sprintf("sqlitestore: pragma %q cannot be reloaded", e.Key)
+}
+
+// ReloadPragmas sets each pragma in pragmas, in key order, so the database
+// can be tuned without restarting. Only pragmas that are safe on a live
+// database, such as synchronous, cache_size and mmap_size, are accepted;
+// nothing is changed if any key is rejected. Most of these pragmas apply per
+// connection, so with a connection pool only the connection that runs them
+// picks up the change.
+func (m *Store) ReloadPragmas(ctx context.Context, pragmas map[string]string) error {
+	keys := make([]string, 0, len(pragmas))
+	for key, value := range pragmas {
+		if !reloadablePragmas[key] {
+			return ErrUnsafePragma{Key: key}
+		}
+		if !pragmaValue.MatchString(value) {
+			return fmt.Errorf("sqlitestore: invalid value %q for pragma %s", value, key)
+		}
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		if _, err := m.db.ExecContext(ctx, "PRAGMA "+key+" = "+pragmas[key]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,197 @@
+package sqlitestore
+
+import (
+	"context"
+	"os"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gorilla/securecookie"
+	"github.com/gorilla/sessions"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCheckpointWAL(t *testing.T) {
+	db := newFileTestDB(t)
+	_, err := db.Exec("PRAGMA journal_mode=WAL")
+	require.NoError(t, err)
+	_, err = db.Exec("PRAGMA wal_autocheckpoint=0")
+	require.NoError(t, err)
+	db.SetMaxOpenConns(1)
+	store := newTestStoreDB(t, db)
+
+	for i := 0; i < 100; i++ {
+		insertTestSession(t, store, map[interface{}]interface{}{"i": i})
+	}
+
+	written, moved, err := store.CheckpointWAL(context.Background(), "PASSIVE")
+	require.NoError(t, err)
+	assert.True(t, written > 0)
+	assert.Equal(t, written, moved)
+
+	_, _, err = store.CheckpointWAL(context.Background(), "SOMETIMES")
+	assert.Error(t, err)
+}
+
+func TestIncrementalVacuum(t *testing.T) {
+	db := newTestDB(t)
+	db.SetMaxOpenConns(1)
+	_, err := db.Exec("PRAGMA auto_vacuum = INCREMENTAL")
+	require.NoError(t, err)
+	store := newTestStoreDB(t, db)
+
+	big := make([]byte, 2048)
+	for i := 0; i < 50; i++ {
+		insertTestSession(t, store, map[interface{}]interface{}{"data": big})
+	}
+	_, err = db.Exec("DELETE FROM sessions")
+	require.NoError(t, err)
+
+	var before, after int
+	require.NoError(t, db.QueryRow("PRAGMA freelist_count").Scan(&before))
+	require.True(t, before > 0)
+	require.NoError(t, store.IncrementalVacuum(context.Background(), 10))
+	require.NoError(t, db.QueryRow("PRAGMA freelist_count").Scan(&after))
+	assert.Equal(t, before-10, after)
+}
+
+func TestEnableAutoVacuum(t *testing.T) {
+	store := newTestStore(t)
+	ctx := context.Background()
+	insertTestSession(t, store, nil)
+
+	mode, err := store.GetPragma(ctx, "auto_vacuum")
+	require.NoError(t, err)
+	assert.Equal(t, "0", mode)
+
+	require.NoError(t, store.EnableAutoVacuum(ctx, "incremental"))
+	mode, err = store.GetPragma(ctx, "auto_vacuum")
+	require.NoError(t, err)
+	assert.Equal(t, "2", mode)
+	assert.Equal(t, 1, countRows(t, store))
+
+	assert.Error(t, store.EnableAutoVacuum(ctx, "SOMETIMES"))
+	_, err = store.GetPragma(ctx, "auto_vacuum; DROP TABLE sessions")
+	assert.Error(t, err)
+}
+
+func TestConvertToWAL(t *testing.T) {
+	path := tempDBPath(t)
+	store, err := Open(path, [][]byte{securecookie.GenerateRandomKey(32)})
+	require.NoError(t, err)
+	defer store.Close()
+	ctx := context.Background()
+
+	mode, err := store.GetPragma(ctx, "journal_mode")
+	require.NoError(t, err)
+	assert.Equal(t, "delete", mode)
+
+	require.NoError(t, store.ConvertToWAL(ctx))
+	require.NoError(t, store.ConvertToWAL(ctx))
+	mode, err = store.GetPragma(ctx, "journal_mode")
+	require.NoError(t, err)
+	assert.Equal(t, "wal", mode)
+
+	insertTestSession(t, store, nil)
+	_, err = os.Stat(path + "-wal")
+	assert.NoError(t, err)
+}
+
+func TestPageSize(t *testing.T) {
+	store := newTestStore(t, WithPageSize(8192))
+	size, err := store.GetPragma(context.Background(), "page_size")
+	require.NoError(t, err)
+	assert.Equal(t, "8192", size)
+
+	for _, bad := range []int{0, 256, 3000, 131072} {
+		_, err := New(newTestDB(t), [][]byte{securecookie.GenerateRandomKey(32)}, WithPageSize(bad))
+		assert.Error(t, err, "page size %d", bad)
+	}
+}
+
+func TestMmapSize(t *testing.T) {
+	db := newFileTestDB(t)
+	db.SetMaxOpenConns(1)
+	store := newTestStoreDB(t, db, WithMmapSize(1<<20))
+	size, err := store.GetPragma(context.Background(), "mmap_size")
+	require.NoError(t, err)
+	assert.Equal(t, "1048576", size)
+
+	_, err = New(newTestDB(t), [][]byte{securecookie.GenerateRandomKey(32)}, WithMmapSize(-1))
+	assert.Error(t, err)
+}
+
+func TestReloadPragmas(t *testing.T) {
+	db := newTestDB(t)
+	db.SetMaxOpenConns(1)
+	store := newTestStoreDB(t, db)
+	ctx := context.Background()
+
+	require.NoError(t, store.ReloadPragmas(ctx, map[string]string{"cache_size": "-4000", "synchronous": "1"}))
+	size, err := store.GetPragma(ctx, "cache_size")
+	require.NoError(t, err)
+	assert.Equal(t, "-4000", size)
+
+	err = store.ReloadPragmas(ctx, map[string]string{"cache_size": "100", "journal_mode": "OFF"})
+	assert.Equal(t, ErrUnsafePragma{Key: "journal_mode"}, err)
+	size, err = store.GetPragma(ctx, "cache_size")
+	require.NoError(t, err)
+	assert.Equal(t, "-4000", size)
+
+	assert.Error(t, store.ReloadPragmas(ctx, map[string]string{"cache_size": "1; DROP TABLE sessions"}))
+}
+
+func TestWALModeAndBusyTimeout(t *testing.T) {
+	store := newTestStoreDB(t, newFileTestDB(t), WithWALMode(), WithBusyTimeout(2*time.Second))
+	mode, err := store.GetPragma(context.Background(), "journal_mode")
+	require.NoError(t, err)
+	assert.Equal(t, "wal", mode)
+
+	// in-memory databases can't use WAL, which only warns
+	_ = newTestStore(t, WithWALMode())
+
+	_, err = New(newTestDB(t), nil, WithBusyTimeout(0))
+	assert.Error(t, err)
+}
+
+func TestWALModeConcurrentWriters(t *testing.T) {
+	path := tempDBPath(t)
+	keys := [][]byte{securecookie.GenerateRandomKey(32)}
+	var stores [2]*Store
+	for i := range stores {
+		store, err := Open(path, keys, WithWALMode(), WithBusyTimeout(5*time.Second))
+		require.NoError(t, err)
+		t.Cleanup(store.Close)
+		stores[i] = store
+	}
+
+	var wg sync.WaitGroup
+	errs := make(chan error, 2*len(stores))
+	for _, store := range stores {
+		wg.Add(1)
+		go func(store *Store) {
+			defer wg.Done()
+			for i := 0; i < 50; i++ {
+				sess := sessions.NewSession(store, "test")
+				sess.Options = &sessions.Options{MaxAge: 3600}
+				if err := store.insert(context.Background(), sess); err != nil {
+					errs <- err
+					return
+				}
+				sess.Values["i"] = i
+				if err := store.save(context.Background(), sess); err != nil {
+					errs <- err
+					return
+				}
+			}
+		}(store)
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		assert.NoError(t, err)
+	}
+	assert.Equal(t, 100, countRows(t, stores[0]))
+}
@@ -0,0 +1,230 @@
+package sqlitestore
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/gorilla/securecookie"
+)
+
+func (m *Store) startHotReload(fetch func(ctx context.Context) ([][]byte, error), interval time.Duration, current [][]byte) {
+	ctx, cancel := context.WithCancel(context.Background())
+	m.wg.Add(1)
+	go func() {
+		defer m.wg.Done()
+		defer cancel()
+
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-m.done:
+				return
+			case <-ticker.C:
+			}
+			keyPairs, err := fetch(ctx)
+			if err != nil {
+				m.handleError(err)
+				continue
+			}
+			if keysEqual(keyPairs, current) {
+				continue
+			}
+			m.mu.Lock()
+			m.Codecs = codecsFromPairs(m.MaxLength, keyPairs...)
+			m.mu.Unlock()
+			current = keyPairs
+		}
+	}()
+}
+
+func keysEqual(a, b [][]byte) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if !bytes.Equal(a[i], b[i]) {
+			return false
+		}
+	}
+	return true
+}
+
+// rotateBatchSize is the number of rows RotateKeys re-encodes per transaction.
+const rotateBatchSize = 100
+
+// RotateKeys re-encodes the data of every unexpired session with codecs built
+// from newKeyPairs and then makes them the store's codecs, so the old keys can
+// be retired without logging everyone out. Rows are rewritten
+// rotateBatchSize at a time and ctx is checked between batches. Rows that
+// can't be decoded with the current codecs are left as they are.
+//
+// Session cookies are signed with the same keys: include the old pairs after
+// the new ones in newKeyPairs until existing cookies have expired. Sessions
+// saved while the rotation runs are written with the old codecs, so keep the
+// old pairs until it has returned. rotated is the number of sessions
+// re-encoded, including those in batches committed before an error.
+func (m *Store) RotateKeys(ctx context.Context, newKeyPairs ...[]byte) (rotated int, err error) {
+	if m.readOnly {
+		return 0, ErrReadOnly
+	}
+	if len(newKeyPairs) == 0 {
+		return 0, errors.New("sqlitestore: no key pairs to rotate to")
+	}
+	// pending writes were encoded with the old codecs
+	if err := m.flushWrites(ctx); err != nil {
+		return 0, err
+	}
+	from := m.codecs()
+	to := codecsFromPairs(m.MaxLength, newKeyPairs...)
+	if m.Serializer != nil {
+		// session data doesn't depend on the codecs, only cookies do
+		m.mu.Lock()
+		m.Codecs = to
+		m.mu.Unlock()
+		return 0, nil
+	}
+	// one cutoff for the whole pass, so no row is skipped by expiring
+	// between batches
+	cutoff := m.transform("expires_on", m.clock())
+	var lastID *string
+	for {
+		if err := ctx.Err(); err != nil {
+			return rotated, err
+		}
+		n, last, err := m.rotateBatch(ctx, from, to, cutoff, lastID)
+		rotated += n
+		if err != nil {
+			return rotated, err
+		}
+		if last == nil {
+			break
+		}
+		lastID = last
+	}
+
+	// cached rows still hold data encoded with the old codecs
+	m.mu.Lock()
+	m.Codecs = to
+	m.cache.purge()
+	m.mu.Unlock()
+	return rotated, nil
+}
+
+// rotateBatch re-encodes the next batch of rows expiring after cutoff that
+// follow lastID, or the first batch when it is nil, in one transaction. It
+// returns the ID of the last row read, or nil when the batch was the last.
+func (m *Store) rotateBatch(ctx context.Context, from, to []securecookie.Codec, cutoff interface{}, lastID *string) (n int, last *string, err error) {
+	tx, err := m.db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, nil, err
+	}
+	defer func() {
+		if err != nil {
+			tx.Rollback()
+			n = 0
+		}
+	}()
+
+	after, args := afterID(lastID)
+	rows, err := tx.QueryContext(ctx, "SELECT id, name, session_data FROM "+m.table+
+		" WHERE expires_on > ? AND "+after+" ORDER BY id LIMIT ?",
+		append(append([]interface{}{cutoff}, args...), rotateBatchSize)...)
+	if err != nil {
+		return 0, nil, err
+	}
+	var batch []sessionRow
+	for rows.Next() {
+		var row sessionRow
+		if err = rows.Scan(&row.id, &row.name, &row.data); err != nil {
+			rows.Close()
+			return 0, nil, err
+		}
+		batch = append(batch, row)
+	}
+	rows.Close()
+	if err = rows.Err(); err != nil {
+		return 0, nil, err
+	}
+
+	updQ := "UPDATE " + m.table + " SET session_data = ? WHERE id = ?"
+	for _, row := range batch {
+		encoded, recodeErr := m.recode(row.name, row.data, from, to)
+		if recodeErr != nil {
+			continue
+		}
+		if _, err = tx.ExecContext(ctx, updQ, encoded, row.id); err != nil {
+			return 0, nil, err
+		}
+		if m.chunked {
+			if err = m.rotateChunks(ctx, tx, row, from, to); err != nil {
+				return 0, nil, err
+			}
+		}
+		n++
+	}
+	if err = tx.Commit(); err != nil {
+		return 0, nil, err
+	}
+	if len(batch) == rotateBatchSize {
+		last = &batch[len(batch)-1].id
+	}
+	return n, last, nil
+}
+
+// rotateChunks re-encodes the values table entries of row.
+func (m *Store) rotateChunks(ctx context.Context, tx *sql.Tx, row sessionRow, from, to []securecookie.Codec) error {
+	rows, err := tx.QueryContext(ctx, "SELECT key, value FROM "+m.valuesTable()+" WHERE session_id = ?", row.id)
+	if err != nil {
+		return err
+	}
+	type chunk struct {
+		key  []byte
+		data string
+	}
+	var chunks []chunk
+	for rows.Next() {
+		var c chunk
+		if err := rows.Scan(&c.key, &c.data); err != nil {
+			rows.Close()
+			return err
+		}
+		chunks = append(chunks, c)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+	for _, c := range chunks {
+		encoded, err := m.recode(row.name, c.data, from, to)
+		if err != nil {
+			continue
+		}
+		if _, err := tx.ExecContext(ctx, "UPDATE "+m.valuesTable()+" SET value = ? WHERE session_id = ? AND key = ?",
+			encoded, row.id, c.key); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// recode decodes data encoded by encodeMap with the from codecs and encodes
+// it again with to. Values encrypted with WithEncryptedValues are carried
+// over still sealed and value hooks are not run.
+func (m *Store) recode(name, data string, from, to []securecookie.Codec) (string, error) {
+	encoded, err := m.open(data)
+	if err != nil {
+		return "", err
+	}
+	values := make(map[interface{}]interface{})
+	if err := securecookie.DecodeMulti(name, encoded, &values, from...); err != nil {
+		return "", err
+	}
+	if encoded, err = securecookie.EncodeMulti(name, values, to...); err != nil {
+		return "", err
+	}
+	return m.seal(encoded)
+}
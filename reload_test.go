@@ -0,0 +1,160 @@
+package sqlitestore
+
+import (
+	"context"
+	"errors"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gorilla/securecookie"
+	"github.com/gorilla/sessions"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHotReload(t *testing.T) {
+	oldKey := securecookie.GenerateRandomKey(32)
+	newKey := securecookie.GenerateRandomKey(32)
+
+	var mu sync.Mutex
+	keys := [][]byte{oldKey}
+	fetchErr := errors.New("vault unavailable")
+	failing := false
+	fetch := func(ctx context.Context) ([][]byte, error) {
+		mu.Lock()
+		defer mu.Unlock()
+		if failing {
+			return nil, fetchErr
+		}
+		return keys, nil
+	}
+	errs := make(chan error, 100)
+
+	store := newTestStore(t,
+		WithHotReload(fetch, 10*time.Millisecond),
+		WithErrorHandler(func(err error) { errs <- err }),
+	)
+	store.mu.Lock()
+	store.Codecs = securecookie.CodecsFromPairs(oldKey)
+	store.mu.Unlock()
+
+	// fetch errors are reported but keep the current codecs
+	mu.Lock()
+	failing = true
+	mu.Unlock()
+	select {
+	case err := <-errs:
+		assert.Equal(t, fetchErr, err)
+	case <-time.After(time.Second):
+		t.Fatal("fetch error was not reported")
+	}
+
+	mu.Lock()
+	failing = false
+	keys = [][]byte{newKey}
+	mu.Unlock()
+
+	newCodecs := securecookie.CodecsFromPairs(newKey)
+	require.Eventually(t, func() bool {
+		encoded, err := securecookie.EncodeMulti("probe", "value", store.codecs()...)
+		if err != nil {
+			return false
+		}
+		var out string
+		return securecookie.DecodeMulti("probe", encoded, &out, newCodecs...) == nil
+	}, time.Second, 10*time.Millisecond)
+
+	r := httptest.NewRequest("GET", "/", nil)
+	sess, err := store.New(r, "test")
+	require.NoError(t, err)
+	sess.Values["foo"] = "bar"
+	sess.Options = &sessions.Options{MaxAge: 3600}
+	w := httptest.NewRecorder()
+	require.NoError(t, store.Save(r, w, sess))
+
+	// a cookie encoded by another instance holding only the new key is valid
+	encoded, err := securecookie.EncodeMulti("test", sess.ID, newCodecs...)
+	require.NoError(t, err)
+	r2 := httptest.NewRequest("GET", "/", nil)
+	r2.Header.Add("Cookie", "test="+encoded)
+	sess2, err := store.New(r2, "test")
+	require.NoError(t, err)
+	assert.False(t, sess2.IsNew)
+	assert.Equal(t, "bar", sess2.Values["foo"])
+}
+
+func TestRotateKeys(t *testing.T) {
+	store := newTestStore(t)
+	oldCodecs := store.Codecs
+	var ids []string
+	for i := 0; i < rotateBatchSize+5; i++ {
+		ids = append(ids, insertTestSession(t, store, map[interface{}]interface{}{"n": i}).ID)
+	}
+	insertTestSession(t, store, map[interface{}]interface{}{"expires_on": time.Now().Add(-time.Hour)})
+
+	newKey := securecookie.GenerateRandomKey(32)
+	n, err := store.RotateKeys(context.Background(), newKey)
+	require.NoError(t, err)
+	assert.Equal(t, rotateBatchSize+5, n)
+
+	// only the new key can read the rows now
+	var values map[interface{}]interface{}
+	data := rawSessionData(t, store, ids[0])
+	assert.Error(t, securecookie.DecodeMulti("test", data, &values, oldCodecs...))
+	for i, id := range ids {
+		loaded := sessions.NewSession(store, "test")
+		loaded.ID = id
+		loaded.Options = &sessions.Options{MaxAge: 3600}
+		require.NoError(t, store.load(context.Background(), loaded))
+		assert.Equal(t, i, loaded.Values["n"])
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	n, err = store.RotateKeys(ctx, securecookie.GenerateRandomKey(32))
+	assert.Equal(t, context.Canceled, err)
+	assert.Equal(t, 0, n)
+}
+
+func TestRotateKeysExpiringDuringRotation(t *testing.T) {
+	store := newTestStore(t)
+	start := time.Now()
+	var ids []string
+	for i := 0; i < rotateBatchSize+50; i++ {
+		// the first 50 sessions expire while the rotation runs
+		expires := start.Add(2 * time.Hour)
+		if i < 50 {
+			expires = start.Add(30 * time.Minute)
+		}
+		ids = append(ids, insertTestSession(t, store, map[interface{}]interface{}{"n": i, "expires_on": expires}).ID)
+	}
+	oldCodecs := store.Codecs
+	calls := 0
+	store.Clock = func() time.Time {
+		calls++
+		if calls > 1 {
+			return start.Add(time.Hour)
+		}
+		return start
+	}
+
+	n, err := store.RotateKeys(context.Background(), securecookie.GenerateRandomKey(32))
+	require.NoError(t, err)
+	assert.Equal(t, rotateBatchSize+50, n)
+	var values map[interface{}]interface{}
+	for _, id := range ids[rotateBatchSize:] {
+		assert.Error(t, securecookie.DecodeMulti("test", rawSessionData(t, store, id), &values, oldCodecs...))
+	}
+}
+
+func TestRotateKeysPurgesCache(t *testing.T) {
+	store := newTestStore(t, WithLRUCache(10))
+	sess := insertTestSession(t, store, map[interface{}]interface{}{"n": 1})
+	loadTestSession(t, store, sess.ID)
+
+	_, err := store.RotateKeys(context.Background(), securecookie.GenerateRandomKey(32))
+	require.NoError(t, err)
+	assert.Equal(t, 1, loadTestSession(t, store, sess.ID).Values["n"])
+}
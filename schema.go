@@ -0,0 +1,546 @@
+package sqlitestore
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+
+	"github.com/gorilla/sessions"
+)
+
+// ColumnInfo describes a column of the sessions table as reported by
+// PRAGMA table_info.
+type ColumnInfo struct {
+	Name, Type   string
+	NotNull      bool
+	DefaultValue sql.NullString
+	PrimaryKey   bool
+}
+
+// IndexInfo describes one column of an index on the sessions table. An index
+// over several columns is reported once per column.
+type IndexInfo struct {
+	Name, Column string
+	Unique       bool
+}
+
+// DescribeSchema returns the columns of the sessions table.
+func (m *Store) DescribeSchema(ctx context.Context) ([]ColumnInfo, error) {
+	rows, err := m.db.QueryContext(ctx, "PRAGMA table_info("+m.table+")")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var cols []ColumnInfo
+	for rows.Next() {
+		var cid, pk int
+		var col ColumnInfo
+		if err := rows.Scan(&cid, &col.Name, &col.Type, &col.NotNull, &col.DefaultValue, &pk); err != nil {
+			return nil, err
+		}
+		col.PrimaryKey = pk > 0
+		cols = append(cols, col)
+	}
+	return cols, rows.Err()
+}
+
+// ListIndexes returns the indexes on the sessions table.
+func (m *Store) ListIndexes(ctx context.Context) ([]IndexInfo, error) {
+	rows, err := m.db.QueryContext(ctx, "PRAGMA index_list("+m.table+")")
+	if err != nil {
+		return nil, err
+	}
+	var indexes []IndexInfo
+	for rows.Next() {
+		var seq, partial int
+		var origin string
+		var idx IndexInfo
+		if err := rows.Scan(&seq, &idx.Name, &idx.Unique, &origin, &partial); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		indexes = append(indexes, idx)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	var infos []IndexInfo
+	for _, idx := range indexes {
+		cols, err := m.indexColumns(ctx, idx.Name)
+		if err != nil {
+			return nil, err
+		}
+		for _, col := range cols {
+			infos = append(infos, IndexInfo{Name: idx.Name, Column: col, Unique: idx.Unique})
+		}
+	}
+	return infos, nil
+}
+
+func (m *Store) indexColumns(ctx context.Context, index string) ([]string, error) {
+	rows, err := m.db.QueryContext(ctx, "PRAGMA index_info("+quoteIdent(index)+")")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var cols []string
+	for rows.Next() {
+		var seqno, cid int
+		var name sql.NullString
+		if err := rows.Scan(&seqno, &cid, &name); err != nil {
+			return nil, err
+		}
+		cols = append(cols, name.String)
+	}
+	return cols, rows.Err()
+}
+
+// quoteIdent quotes an identifier read back from SQLite so it can be used in
+// a pragma argument.
+func quoteIdent(name string) string {
+	return `"` + strings.ReplaceAll(name, `"`, `""`) + `"`
+}
+
+// GetSchemaVersion returns the schema version recorded in PRAGMA user_version.
+func (m *Store) GetSchemaVersion(ctx context.Context) (int, error) {
+	var version int
+	if err := m.db.QueryRowContext(ctx, "PRAGMA user_version").Scan(&version); err != nil {
+		return 0, err
+	}
+	return version, nil
+}
+
+// SetSchemaVersion records version in PRAGMA user_version.
+func (m *Store) SetSchemaVersion(ctx context.Context, version int) error {
+	_, err := m.db.ExecContext(ctx, fmt.Sprintf("PRAGMA user_version = %d", version))
+	return err
+}
+
+// ErrIncompatibleSchemaVersion is returned by New when the database schema
+// version is outside the range accepted with WithDatabaseVersionCheck.
+type ErrIncompatibleSchemaVersion struct {
+	Got, Min, Max int
+}
+
+func (e ErrIncompatibleSchemaVersion) Error() string {
+	return fmt.Sprintf("sqlitestore: schema version %d is outside the supported range [%d, %d]", e.Got, e.Min, e.Max)
+}
+
+// checkSchemaVersion verifies that the recorded schema version lies within
+// [min, max].
+func (m *Store) checkSchemaVersion(ctx context.Context, min, max int) error {
+	version, err := m.GetSchemaVersion(ctx)
+	if err != nil {
+		return err
+	}
+	if version < min || version > max {
+		return ErrIncompatibleSchemaVersion{Got: version, Min: min, Max: max}
+	}
+	return nil
+}
+
+// columnDef is a column that MigrateSchema adds to a sessions table created
+// before the column existed.
+type columnDef struct {
+	name, typ, def string
+}
+
+// baseColumns are the columns every sessions table needs. ALTER TABLE only
+// accepts constant defaults, so modified_on falls back to 0 when added later.
+var baseColumns = []columnDef{
+	{"session_data", "LONGBLOB", "NULL"},
+	{"created_on", "TIMESTAMP", "0"},
+	{"modified_on", "TIMESTAMP", "0"},
+	{"expires_on", "TIMESTAMP", "0"},
+	{"name", "TEXT", "''"},
+	{"frozen", "INTEGER", "0"},
+}
+
+var userIDColumn = columnDef{"user_id", "TEXT", "NULL"}
+
+// expectedColumns returns the columns the store needs for its configured
+// options.
+func (m *Store) expectedColumns() []columnDef {
+	cols := append(append([]columnDef(nil), baseColumns...), m.optionalColumns()...)
+	return append(cols, m.tableOpts.extraColumns()...)
+}
+
+// optionalColumns returns the columns only some options need.
+func (m *Store) optionalColumns() []columnDef {
+	var cols []columnDef
+	if m.userIDCol {
+		cols = append(cols, userIDColumn)
+	}
+	if m.tagging {
+		cols = append(cols, tagsColumn)
+	}
+	return cols
+}
+
+// writeColumns returns the columns besides session_data and the timestamps
+// that insert and save write, in the order columnValues returns them.
+func (m *Store) writeColumns() []string {
+	cols := []string{"name"}
+	if m.userIDCol {
+		cols = append(cols, userIDColumn.name)
+	}
+	return cols
+}
+
+func (m *Store) columnValues(session *sessions.Session) []interface{} {
+	vals := []interface{}{session.Name()}
+	if m.userIDCol {
+		vals = append(vals, sessionUserID(session))
+	}
+	return vals
+}
+
+// SchemaMigration is a numbered change to the sessions table applied by
+// MigrateSchema. {table} in SQL is replaced with the table name of the store.
+type SchemaMigration struct {
+	Version int
+	SQL     string
+
+	// Column, if set, names the column the migration adds. When the table
+	// already has it the SQL is skipped and the migration only recorded.
+	Column string
+}
+
+// Built-in migrations for columns older tables lack. Stores created with
+// WithUserIDColumn add user_id themselves and don't need MigrationAddUserID,
+// though running it on them is harmless.
+var (
+	MigrationAddUserID = SchemaMigration{Version: 1, SQL: "ALTER TABLE {table} ADD COLUMN user_id TEXT",
+		Column: "user_id"}
+	MigrationAddUserAgent = SchemaMigration{Version: 2, SQL: "ALTER TABLE {table} ADD COLUMN user_agent TEXT",
+		Column: "user_agent"}
+)
+
+// MigrateSchema adds any column the store expects but the sessions table
+// lacks, bumping the schema version once per column added. It then applies
+// migrations with a version above the highest one recorded in the
+// schema_migrations table, in order of version, each in its own transaction
+// with its record. It is safe to call on every startup; NewStore calls it
+// without migrations after creating the table.
+func (m *Store) MigrateSchema(ctx context.Context, migrations ...SchemaMigration) error {
+	if err := m.migrateColumns(ctx); err != nil {
+		return err
+	}
+	if len(migrations) == 0 {
+		return nil
+	}
+	if m.readOnly {
+		return ErrReadOnly
+	}
+	migrations = append([]SchemaMigration(nil), migrations...)
+	sort.Slice(migrations, func(i, j int) bool { return migrations[i].Version < migrations[j].Version })
+	for i, mig := range migrations {
+		if mig.Version <= 0 {
+			return fmt.Errorf("sqlitestore: migration version %d is not positive", mig.Version)
+		}
+		if i > 0 && migrations[i-1].Version == mig.Version {
+			return fmt.Errorf("sqlitestore: duplicate migration version %d", mig.Version)
+		}
+	}
+
+	if _, err := m.db.ExecContext(ctx, "CREATE TABLE IF NOT EXISTS schema_migrations "+
+		"(version INTEGER PRIMARY KEY, applied_on TIMESTAMP NOT NULL)"); err != nil {
+		return err
+	}
+	var current int
+	if err := m.db.QueryRowContext(ctx, "SELECT COALESCE(MAX(version), 0) FROM schema_migrations").Scan(&current); err != nil {
+		return err
+	}
+	for _, mig := range migrations {
+		if mig.Version <= current {
+			continue
+		}
+		if err := m.applyMigration(ctx, mig); err != nil {
+			return fmt.Errorf("sqlitestore: migration %d: %v", mig.Version, err)
+		}
+	}
+	return nil
+}
+
+func (m *Store) applyMigration(ctx context.Context, mig SchemaMigration) (err error) {
+	tx, err := m.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err != nil {
+			tx.Rollback()
+		}
+	}()
+	var exists int
+	if mig.Column != "" {
+		if err = tx.QueryRowContext(ctx, "SELECT COUNT(*) FROM pragma_table_info(?) WHERE name = ?",
+			m.table, mig.Column).Scan(&exists); err != nil {
+			return err
+		}
+	}
+	if exists == 0 {
+		if _, err = tx.ExecContext(ctx, strings.Replace(mig.SQL, "{table}", m.table, -1)); err != nil {
+			return err
+		}
+	}
+	if _, err = tx.ExecContext(ctx, "INSERT INTO schema_migrations (version, applied_on) VALUES (?, ?)",
+		mig.Version, m.clock().UTC()); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// migrateColumns adds any column the store expects but the sessions table
+// lacks, bumping the schema version once per column added.
+func (m *Store) migrateColumns(ctx context.Context) error {
+	cols, err := m.DescribeSchema(ctx)
+	if err != nil {
+		return err
+	}
+	present := make(map[string]bool, len(cols))
+	for _, col := range cols {
+		present[strings.ToLower(col.Name)] = true
+	}
+
+	for _, col := range m.expectedColumns() {
+		if present[col.name] {
+			continue
+		}
+		q := fmt.Sprintf("ALTER TABLE %s ADD COLUMN %s %s DEFAULT %s", m.table, col.name, col.typ, col.def)
+		if _, err := m.db.ExecContext(ctx, q); err != nil {
+			return err
+		}
+		version, err := m.GetSchemaVersion(ctx)
+		if err != nil {
+			return err
+		}
+		if err := m.SetSchemaVersion(ctx, version+1); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ErrIndexConflict is returned by EnsureIndex when an index on the column
+// exists but disagrees with the requested uniqueness.
+type ErrIndexConflict struct {
+	Column string
+}
+
+func (e ErrIndexConflict) Error() string {
+	return fmt.Sprintf("sqlitestore: existing index on %s has different uniqueness", e.Column)
+}
+
+// EnsureIndex creates an index on column unless a single column index on it
+// already exists. It is safe to call on every startup.
+func (m *Store) EnsureIndex(ctx context.Context, column string, unique bool) error {
+	cols, err := m.DescribeSchema(ctx)
+	if err != nil {
+		return err
+	}
+	var known bool
+	for _, col := range cols {
+		known = known || col.Name == column
+	}
+	if !known {
+		return fmt.Errorf("sqlitestore: no column %q in %s", column, m.table)
+	}
+
+	indexes, err := m.ListIndexes(ctx)
+	if err != nil {
+		return err
+	}
+	width := make(map[string]int)
+	for _, idx := range indexes {
+		width[idx.Name]++
+	}
+	for _, idx := range indexes {
+		if idx.Column != column || width[idx.Name] != 1 {
+			continue
+		}
+		if idx.Unique != unique {
+			return ErrIndexConflict{Column: column}
+		}
+		return nil
+	}
+
+	q := "CREATE INDEX "
+	if unique {
+		q = "CREATE UNIQUE INDEX "
+	}
+	q += "idx_" + m.table + "_" + column + " ON " + m.table + " (" + column + ")"
+	_, err = m.db.ExecContext(ctx, q)
+	return err
+}
+
+// schemaDump is the structure DumpSchema writes in the json format.
+type schemaDump struct {
+	Table    string        `json:"table"`
+	Columns  []dumpColumn  `json:"columns"`
+	Indexes  []dumpIndex   `json:"indexes"`
+	Triggers []dumpTrigger `json:"triggers"`
+}
+
+type dumpColumn struct {
+	Name       string  `json:"name"`
+	Type       string  `json:"type"`
+	NotNull    bool    `json:"not_null"`
+	Default    *string `json:"default"`
+	PrimaryKey bool    `json:"primary_key"`
+}
+
+type dumpIndex struct {
+	Name    string   `json:"name"`
+	Columns []string `json:"columns"`
+	Unique  bool     `json:"unique"`
+}
+
+type dumpTrigger struct {
+	Name string `json:"name"`
+	SQL  string `json:"sql"`
+}
+
+func (m *Store) schemaDump(ctx context.Context) (*schemaDump, error) {
+	cols, err := m.DescribeSchema(ctx)
+	if err != nil {
+		return nil, err
+	}
+	indexes, err := m.ListIndexes(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	dump := &schemaDump{
+		Table:    m.table,
+		Columns:  []dumpColumn{},
+		Indexes:  []dumpIndex{},
+		Triggers: []dumpTrigger{},
+	}
+	for _, col := range cols {
+		dc := dumpColumn{Name: col.Name, Type: col.Type, NotNull: col.NotNull, PrimaryKey: col.PrimaryKey}
+		if col.DefaultValue.Valid {
+			def := col.DefaultValue.String
+			dc.Default = &def
+		}
+		dump.Columns = append(dump.Columns, dc)
+	}
+	for _, idx := range indexes {
+		if n := len(dump.Indexes); n > 0 && dump.Indexes[n-1].Name == idx.Name {
+			dump.Indexes[n-1].Columns = append(dump.Indexes[n-1].Columns, idx.Column)
+			continue
+		}
+		dump.Indexes = append(dump.Indexes, dumpIndex{Name: idx.Name, Columns: []string{idx.Column}, Unique: idx.Unique})
+	}
+
+	rows, err := m.db.QueryContext(ctx,
+		"SELECT name, sql FROM sqlite_master WHERE type = 'trigger' AND tbl_name = ? ORDER BY name", m.table)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var tr dumpTrigger
+		if err := rows.Scan(&tr.Name, &tr.SQL); err != nil {
+			return nil, err
+		}
+		dump.Triggers = append(dump.Triggers, tr)
+	}
+	return dump, rows.Err()
+}
+
+// DumpSchema writes the columns, indexes and triggers of the sessions table to
+// w. format is "text", "markdown" or "json"; the json output is stable for a
+// given schema so it can be diffed to detect schema changes.
+func (m *Store) DumpSchema(ctx context.Context, format string, w io.Writer) error {
+	switch format {
+	case "text", "markdown", "json":
+	default:
+		return fmt.Errorf("sqlitestore: unknown schema dump format %q", format)
+	}
+	dump, err := m.schemaDump(ctx)
+	if err != nil {
+		return err
+	}
+
+	switch format {
+	case "json":
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		return enc.Encode(dump)
+	case "markdown":
+		return writeSchemaMarkdown(w, dump)
+	default:
+		return writeSchemaText(w, dump)
+	}
+}
+
+func (c dumpColumn) defaultString() string {
+	if c.Default == nil {
+		return ""
+	}
+	return *c.Default
+}
+
+func writeSchemaText(w io.Writer, dump *schemaDump) error {
+	var b strings.Builder
+	fmt.Fprintf(&b, "table %s\n\ncolumns:\n", dump.Table)
+	for _, col := range dump.Columns {
+		fmt.Fprintf(&b, "  %s %s", col.Name, col.Type)
+		if col.PrimaryKey {
+			b.WriteString(" PRIMARY KEY")
+		}
+		if col.NotNull {
+			b.WriteString(" NOT NULL")
+		}
+		if col.Default != nil {
+			fmt.Fprintf(&b, " DEFAULT %s", *col.Default)
+		}
+		b.WriteString("\n")
+	}
+	b.WriteString("\nindexes:\n")
+	for _, idx := range dump.Indexes {
+		fmt.Fprintf(&b, "  %s (%s)", idx.Name, strings.Join(idx.Columns, ", "))
+		if idx.Unique {
+			b.WriteString(" UNIQUE")
+		}
+		b.WriteString("\n")
+	}
+	b.WriteString("\ntriggers:\n")
+	for _, tr := range dump.Triggers {
+		fmt.Fprintf(&b, "  %s: %s\n", tr.Name, tr.SQL)
+	}
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+func writeSchemaMarkdown(w io.Writer, dump *schemaDump) error {
+	var b strings.Builder
+	fmt.Fprintf(&b, "# Table `%s`\n\n## Columns\n\n", dump.Table)
+	b.WriteString("| Name | Type | Not null | Default | Primary key |\n")
+	b.WriteString("|------|------|----------|---------|-------------|\n")
+	for _, col := range dump.Columns {
+		fmt.Fprintf(&b, "| %s | %s | %t | %s | %t |\n",
+			col.Name, col.Type, col.NotNull, col.defaultString(), col.PrimaryKey)
+	}
+	b.WriteString("\n## Indexes\n\n")
+	b.WriteString("| Name | Columns | Unique |\n")
+	b.WriteString("|------|---------|--------|\n")
+	for _, idx := range dump.Indexes {
+		fmt.Fprintf(&b, "| %s | %s | %t |\n", idx.Name, strings.Join(idx.Columns, ", "), idx.Unique)
+	}
+	b.WriteString("\n## Triggers\n\n")
+	for _, tr := range dump.Triggers {
+		fmt.Fprintf(&b, "### %s\n\n```sql\n%s\n```\n\n", tr.Name, tr.SQL)
+	}
+	_, err := io.WriteString(w, b.String())
+	return err
+}
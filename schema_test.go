@@ -0,0 +1,240 @@
+package sqlitestore
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"testing"
+
+	"github.com/gorilla/securecookie"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDescribeSchema(t *testing.T) {
+	store := newTestStore(t)
+
+	cols, err := store.DescribeSchema(context.Background())
+	require.NoError(t, err)
+	var names []string
+	for _, col := range cols {
+		names = append(names, col.Name)
+	}
+	assert.Equal(t, []string{"id", "session_data", "created_on", "modified_on", "expires_on", "name", "frozen"}, names)
+	assert.True(t, cols[0].PrimaryKey)
+	assert.Equal(t, "INTEGER", cols[0].Type)
+	assert.Equal(t, "CURRENT_TIMESTAMP", cols[3].DefaultValue.String)
+}
+
+func TestListIndexes(t *testing.T) {
+	store := newTestStore(t)
+	ctx := context.Background()
+
+	_, err := store.db.ExecContext(ctx, "CREATE UNIQUE INDEX idx_test ON sessions (created_on, expires_on)")
+	require.NoError(t, err)
+
+	indexes, err := store.ListIndexes(ctx)
+	require.NoError(t, err)
+	assert.ElementsMatch(t, []IndexInfo{
+		{Name: "idx_test", Column: "created_on", Unique: true},
+		{Name: "idx_test", Column: "expires_on", Unique: true},
+		{Name: "idx_sessions_expires_on", Column: "expires_on"},
+		{Name: "idx_sessions_modified_on", Column: "modified_on"},
+	}, indexes)
+}
+
+func TestSchemaVersion(t *testing.T) {
+	path := tempDBPath(t)
+	ctx := context.Background()
+
+	db, err := sql.Open("sqlite3", path)
+	require.NoError(t, err)
+	store, err := NewStore(db, [][]byte{securecookie.GenerateRandomKey(32)})
+	require.NoError(t, err)
+	version, err := store.GetSchemaVersion(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, 0, version)
+	require.NoError(t, store.SetSchemaVersion(ctx, 3))
+	store.Close()
+
+	db, err = sql.Open("sqlite3", path)
+	require.NoError(t, err)
+	store, err = NewStore(db, [][]byte{securecookie.GenerateRandomKey(32)})
+	require.NoError(t, err)
+	defer store.Close()
+	version, err = store.GetSchemaVersion(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, 3, version)
+}
+
+func TestMigrateSchema(t *testing.T) {
+	db := newTestDB(t)
+	_, err := db.Exec("CREATE TABLE sessions (id INTEGER PRIMARY KEY, session_data LONGBLOB, created_on TIMESTAMP DEFAULT 0)")
+	require.NoError(t, err)
+
+	store := newTestStoreDB(t, db)
+	ctx := context.Background()
+	cols, err := store.DescribeSchema(ctx)
+	require.NoError(t, err)
+	assert.Len(t, cols, 7)
+	version, err := store.GetSchemaVersion(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, 4, version)
+
+	// running again is a no-op
+	require.NoError(t, store.MigrateSchema(ctx))
+	version, err = store.GetSchemaVersion(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, 4, version)
+
+	insertTestSession(t, store, nil)
+}
+
+func TestMigrateSchemaNewTable(t *testing.T) {
+	store := newTestStore(t, WithUserIDColumn(), WithSessionTagging())
+	ctx := context.Background()
+
+	cols, err := store.DescribeSchema(ctx)
+	require.NoError(t, err)
+	var names []string
+	for _, col := range cols {
+		names = append(names, col.Name)
+	}
+	assert.Subset(t, names, []string{"frozen", "user_id", "tags"})
+	version, err := store.GetSchemaVersion(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, 0, version)
+}
+
+func TestMigrateSchemaMigrations(t *testing.T) {
+	store := newTestStore(t)
+	ctx := context.Background()
+	columns := func() map[string]bool {
+		cols, err := store.DescribeSchema(ctx)
+		require.NoError(t, err)
+		names := make(map[string]bool)
+		for _, col := range cols {
+			names[col.Name] = true
+		}
+		return names
+	}
+	applied := func() []int {
+		rows, err := store.db.QueryContext(ctx, "SELECT version FROM schema_migrations ORDER BY version")
+		require.NoError(t, err)
+		defer rows.Close()
+		var versions []int
+		for rows.Next() {
+			var v int
+			require.NoError(t, rows.Scan(&v))
+			versions = append(versions, v)
+		}
+		return versions
+	}
+	assert.False(t, columns()["user_id"])
+
+	require.NoError(t, store.MigrateSchema(ctx, MigrationAddUserAgent, MigrationAddUserID))
+	assert.True(t, columns()["user_id"])
+	assert.True(t, columns()["user_agent"])
+	assert.Equal(t, []int{1, 2}, applied())
+
+	// applied migrations are skipped
+	require.NoError(t, store.MigrateSchema(ctx, MigrationAddUserID, MigrationAddUserAgent))
+	assert.Equal(t, []int{1, 2}, applied())
+
+	// a failing migration is not recorded
+	err := store.MigrateSchema(ctx, SchemaMigration{Version: 3, SQL: "ALTER TABLE {table} ADD COLUMN user_id TEXT"})
+	assert.Error(t, err)
+	assert.Equal(t, []int{1, 2}, applied())
+
+	assert.Error(t, store.MigrateSchema(ctx, SchemaMigration{Version: 4}, SchemaMigration{Version: 4}))
+	assert.Error(t, store.MigrateSchema(ctx, SchemaMigration{Version: 0}))
+	insertTestSession(t, store, nil)
+}
+
+func TestMigrateSchemaExistingColumn(t *testing.T) {
+	store := newTestStore(t, WithUserIDColumn())
+	ctx := context.Background()
+
+	require.NoError(t, store.MigrateSchema(ctx, MigrationAddUserID))
+	var n int
+	require.NoError(t, store.db.QueryRowContext(ctx, "SELECT COUNT(*) FROM schema_migrations WHERE version = 1").Scan(&n))
+	assert.Equal(t, 1, n)
+	insertTestSession(t, store, nil)
+}
+
+func TestEnsureIndex(t *testing.T) {
+	store := newTestStore(t)
+	ctx := context.Background()
+
+	// expires_on is indexed by New
+	require.NoError(t, store.EnsureIndex(ctx, "expires_on", false))
+	require.NoError(t, store.EnsureIndex(ctx, "created_on", false))
+	require.NoError(t, store.EnsureIndex(ctx, "created_on", false))
+	assert.Equal(t, ErrIndexConflict{Column: "created_on"}, store.EnsureIndex(ctx, "created_on", true))
+	assert.Error(t, store.EnsureIndex(ctx, "nope; DROP TABLE sessions", false))
+
+	indexes, err := store.ListIndexes(ctx)
+	require.NoError(t, err)
+	assert.ElementsMatch(t, []IndexInfo{
+		{Name: "idx_sessions_created_on", Column: "created_on"},
+		{Name: "idx_sessions_expires_on", Column: "expires_on"},
+		{Name: "idx_sessions_modified_on", Column: "modified_on"},
+	}, indexes)
+}
+
+func TestDatabaseVersionCheck(t *testing.T) {
+	db := newTestDB(t)
+	defer db.Close()
+	_, err := db.Exec("PRAGMA user_version = 999")
+	require.NoError(t, err)
+
+	_, err = New(db, [][]byte{securecookie.GenerateRandomKey(32)}, WithDatabaseVersionCheck(0, 10))
+	assert.Equal(t, ErrIncompatibleSchemaVersion{Got: 999, Min: 0, Max: 10}, err)
+
+	store, err := New(db, [][]byte{securecookie.GenerateRandomKey(32)}, WithDatabaseVersionCheck(0, 999))
+	require.NoError(t, err)
+	store.Close()
+}
+
+func TestDumpSchema(t *testing.T) {
+	store := newTestStore(t)
+	ctx := context.Background()
+
+	_, err := store.db.ExecContext(ctx, "CREATE INDEX idx_created ON sessions (created_on)")
+	require.NoError(t, err)
+	_, err = store.db.ExecContext(ctx,
+		"CREATE TRIGGER trg_touch AFTER UPDATE ON sessions BEGIN SELECT 1; END")
+	require.NoError(t, err)
+
+	for _, format := range []string{"text", "markdown"} {
+		var buf bytes.Buffer
+		require.NoError(t, store.DumpSchema(ctx, format, &buf), format)
+		out := buf.String()
+		assert.Contains(t, out, "session_data", format)
+		assert.Contains(t, out, "expires_on", format)
+		assert.Contains(t, out, "idx_created", format)
+		assert.Contains(t, out, "trg_touch", format)
+	}
+
+	var buf bytes.Buffer
+	require.NoError(t, store.DumpSchema(ctx, "json", &buf))
+	var dump schemaDump
+	require.NoError(t, json.Unmarshal(buf.Bytes(), &dump))
+	assert.Equal(t, "sessions", dump.Table)
+	var names []string
+	for _, col := range dump.Columns {
+		names = append(names, col.Name)
+	}
+	assert.Contains(t, names, "id")
+	assert.Contains(t, names, "session_data")
+	assert.ElementsMatch(t, []dumpIndex{
+		{Name: "idx_created", Columns: []string{"created_on"}},
+		{Name: "idx_sessions_expires_on", Columns: []string{"expires_on"}},
+		{Name: "idx_sessions_modified_on", Columns: []string{"modified_on"}},
+	}, dump.Indexes)
+	require.Len(t, dump.Triggers, 1)
+	assert.Equal(t, "trg_touch", dump.Triggers[0].Name)
+
+	assert.Error(t, store.DumpSchema(ctx, "yaml", &buf))
+}
@@ -0,0 +1,51 @@
+package sqlitestore
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+// Serializer converts session values to and from the string stored in
+// session_data. Set Store.Serializer to replace the default securecookie
+// encoding.
+type Serializer interface {
+	Serialize(name string, values map[interface{}]interface{}) (string, error)
+	Deserialize(name string, data string, dst *map[interface{}]interface{}) error
+}
+
+// JSONSerializer stores session values as a JSON object so they can be read
+// from outside Go. Keys are converted to strings with fmt.Sprint and values
+// come back as the types encoding/json decodes into.
+//
+// Unlike the default encoding the data is not signed with the store's key
+// pairs, so anyone with write access to the database can forge session
+// values. Only use it when access to the database is restricted.
+type JSONSerializer struct{}
+
+// Serialize encodes values as a JSON object.
+func (JSONSerializer) Serialize(name string, values map[interface{}]interface{}) (string, error) {
+	m := make(map[string]interface{}, len(values))
+	for k, v := range values {
+		m[fmt.Sprint(k)] = v
+	}
+	b, err := json.Marshal(m)
+	if err != nil {
+		return "", fmt.Errorf("sqlitestore: json serialize %s: %v", name, err)
+	}
+	return string(b), nil
+}
+
+// Deserialize decodes a JSON object produced by Serialize into dst.
+func (JSONSerializer) Deserialize(name string, data string, dst *map[interface{}]interface{}) error {
+	var m map[string]interface{}
+	if err := json.Unmarshal([]byte(data), &m); err != nil {
+		return fmt.Errorf("sqlitestore: json deserialize %s: %v", name, err)
+	}
+	if *dst == nil {
+		*dst = make(map[interface{}]interface{}, len(m))
+	}
+	for k, v := range m {
+		(*dst)[k] = v
+	}
+	return nil
+}
@@ -0,0 +1,37 @@
+package sqlitestore
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/gorilla/sessions"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestJSONSerializer(t *testing.T) {
+	store := newTestStore(t)
+	store.Serializer = JSONSerializer{}
+	sess := insertTestSession(t, store, map[interface{}]interface{}{"email": "a@example.com", 1: true})
+
+	var raw map[string]interface{}
+	require.NoError(t, json.Unmarshal([]byte(rawSessionData(t, store, sess.ID)), &raw))
+	assert.Equal(t, map[string]interface{}{"email": "a@example.com", "1": true}, raw)
+
+	loaded := sessions.NewSession(store, "test")
+	loaded.ID = sess.ID
+	loaded.Options = sess.Options
+	require.NoError(t, store.load(context.Background(), loaded))
+	assert.Equal(t, "a@example.com", loaded.Values["email"])
+	assert.Equal(t, true, loaded.Values["1"])
+
+	// rows written with the default encoding can't be read back as JSON
+	store.Serializer = nil
+	legacy := insertTestSession(t, store, map[interface{}]interface{}{"email": "b@example.com"})
+	store.Serializer = JSONSerializer{}
+	loaded = sessions.NewSession(store, "test")
+	loaded.ID = legacy.ID
+	loaded.Options = legacy.Options
+	assert.Error(t, store.load(context.Background(), loaded))
+}
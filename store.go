@@ -7,11 +7,15 @@ This software is licensed under a MIT style license available in the LICENSE fil
 package sqlitestore
 
 import (
+	"context"
+	"crypto/cipher"
 	"database/sql"
 	"encoding/gob"
 	"errors"
 	"fmt"
+	"math/rand"
 	"net/http"
+	"strings"
 	"sync"
 	"time"
 
@@ -20,30 +24,155 @@ import (
 	_ "github.com/mattn/go-sqlite3"
 )
 
+// defaultTableName is the table sessions are stored in unless WithTableName
+// is used.
+const defaultTableName = "sessions"
+
 var SessionExpired error = errors.New("session expired")
 
+// ErrConnectionLost is returned by store operations while the connection
+// watcher considers the database unreachable.
+var ErrConnectionLost = errors.New("sqlitestore: database connection lost")
+
+// ErrSessionNotFound is returned when an operation targets a session ID that
+// has no row in the store.
+var ErrSessionNotFound = errors.New("sqlitestore: session not found")
+
+// ErrLockTimeout is returned when a session lock cannot be taken within the
+// timeout set with WithGlobalLockTimeout.
+var ErrLockTimeout = errors.New("sqlitestore: timed out waiting for session lock")
+
+// ErrReadOnly is returned by write operations on a store created with
+// WithReadOnly.
+var ErrReadOnly = errors.New("sqlitestore: store is read-only")
+
+// ErrSessionTooLarge is returned when encoded session data is longer than
+// Store.MaxLength, whether it is being written or read back.
+var ErrSessionTooLarge = errors.New("sqlitestore: session data exceeds the maximum length")
+
+// ErrSessionNotSaved is returned by Rotate for a session that has no ID
+// because it was never saved.
+var ErrSessionNotSaved = errors.New("sqlitestore: session has not been saved")
+
 type Store struct {
 	db     DB
 	create *sql.Stmt
 	delete *sql.Stmt
 	update *sql.Stmt
 	get    *sql.Stmt
-	mu     sync.RWMutex
+	exists *sql.Stmt
+	locks  locker
+	table  string
+
+	lockTimeout time.Duration
+
+	// mu guards Codecs once background goroutines may replace them,
+	// namespaces and indexedKeys
+	mu          sync.RWMutex
+	namespaces  map[string]*sessions.Options
+	indexedKeys []interface{}
+	onError     func(error)
+	done        chan struct{}
+	wg          sync.WaitGroup
+	closed      sync.Once
+
+	// cleanupMu guards the channels of the cleanup goroutine
+	cleanupMu   sync.Mutex
+	cleanupStop chan struct{}
+	cleanupDone chan struct{}
+
+	readOnly    bool
+	transformer ColumnTransformer
+	valueHook   ValueHook
+	timeouts    opTimeouts
+	tableOpts   TableOptions
+	schema      SchemaProvider
+	triggers    []string
+
+	logger        Logger
+	slowThreshold time.Duration
+	slowMu        sync.Mutex
+	slowQueries   []time.Time
+	vacuumPages   int
+	analyzeEvery  int
+	cleanups      int
+	gcProbability float64
+	connLost      int32
+	lastCleanup   int64
+	sliding       time.Duration
+	maxLifetime   time.Duration
+	absolute      time.Duration
+	preSave       func(session *sessions.Session) error
+	onClose       func(*sql.DB) error
+	onRecover     func(hookName string, recovered interface{})
+	metrics       Metrics
+
+	atRest     cipher.AEAD
+	valuesAEAD cipher.AEAD
+	prefix     string
+	userIDCol  bool
+	tagging    bool
+	piiKeys    []interface{}
+
+	encryptedKeys  []interface{}
+	coalesceWindow time.Duration
+	chunked        bool
+	pending        sync.Map
+	checksums      sync.Map
+	cache          *sessionCache
 
 	Codecs  []securecookie.Codec
 	Options *sessions.Options
+	// Serializer, when set, encodes session values in place of
+	// securecookie. See JSONSerializer.
+	Serializer Serializer
+	// SlidingExpiry makes every save push expires_on out to MaxAge from
+	// now. When false a session expires MaxAge after it was created, however
+	// often it is saved. New sets it to true.
+	SlidingExpiry bool
+	// MaxLength is the longest encoded session data, in bytes, that is
+	// written or decoded. 0 means no limit. See WithMaxLength.
+	MaxLength int
+	// TrackDirty makes Save skip writing sessions whose values have not
+	// changed since they were loaded. See WithDirtyTracking.
+	TrackDirty bool
+	// Hooks are called on session lifecycle events.
+	Hooks Hooks
+	// Clock returns the current time for expiry checks and the timestamps
+	// written with sessions. nil means time.Now. See WithClock.
+	Clock func() time.Time
 }
 
+// sessionRow is a row of the sessions table. Its accessors let callers of
+// the methods that return rows read it.
 type sessionRow struct {
-	id         int
+	id         string
+	name       string
 	data       string
 	createdOn  time.Time
 	modifiedOn time.Time
 	expiresOn  time.Time
 }
 
+// ID returns the row ID.
+func (r *sessionRow) ID() string { return r.id }
+
+// CreatedOn returns the time the session was created.
+func (r *sessionRow) CreatedOn() time.Time { return r.createdOn }
+
+// ModifiedOn returns the time the session was last modified.
+func (r *sessionRow) ModifiedOn() time.Time { return r.modifiedOn }
+
+// ExpiresOn returns the time the session expires.
+func (r *sessionRow) ExpiresOn() time.Time { return r.expiresOn }
+
 type DB interface {
 	Exec(query string, args ...interface{}) (sql.Result, error)
+	ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error)
+	QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error)
+	QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row
+	PingContext(ctx context.Context) error
+	BeginTx(ctx context.Context, opts *sql.TxOptions) (*sql.Tx, error)
 	Prepare(query string) (*sql.Stmt, error)
 	Close() error
 }
@@ -52,80 +181,285 @@ func init() {
 	gob.Register(time.Time{})
 }
 
-func NewStore(db DB, keyPairs ...[]byte) (*Store, error) {
-	cTableQ := "CREATE TABLE IF NOT EXISTS sessions " +
-		"(id INTEGER PRIMARY KEY, " +
-		"session_data LONGBLOB, " +
-		"created_on TIMESTAMP DEFAULT 0, " +
-		"modified_on TIMESTAMP DEFAULT CURRENT_TIMESTAMP, " +
-		"expires_on TIMESTAMP DEFAULT 0);"
-	if _, err := db.Exec(cTableQ); err != nil {
-		return nil, err
+// New is NewStore.
+func New(db DB, keyPairs [][]byte, opts ...StoreOption) (*Store, error) {
+	return NewStore(db, keyPairs, opts...)
+}
+
+// NewStore creates a store backed by db, applying the given options before
+// the sessions table and statements are prepared.
+func NewStore(db DB, keyPairs [][]byte, opts ...StoreOption) (*Store, error) {
+	cfg := defaultConfig()
+	for _, opt := range opts {
+		if err := opt(cfg); err != nil {
+			return nil, err
+		}
 	}
 
-	insQ := "INSERT INTO sessions (id, session_data, created_on, modified_on, expires_on) VALUES (NULL, ?, ?, ?, ?)"
-	create, err := db.Prepare(insQ)
-	if err != nil {
-		return nil, err
+	m := &Store{
+		db:      db,
+		locks:   cfg.locker(),
+		table:   cfg.table,
+		onError: cfg.onError,
+		done:    make(chan struct{}),
+
+		lockTimeout: cfg.lockTimeout,
+		readOnly:    cfg.readOnly,
+		transformer: cfg.transformer,
+		valueHook:   cfg.valueHook,
+		timeouts:    cfg.timeouts,
+		tableOpts:   cfg.tableOpts,
+		schema:      cfg.schema,
+		triggers:    cfg.triggers,
+
+		logger:        cfg.logger,
+		slowThreshold: cfg.slowThreshold,
+		vacuumPages:   cfg.vacuumPages,
+		analyzeEvery:  cfg.analyzeEvery,
+		gcProbability: cfg.gcProbability,
+		sliding:       cfg.sliding,
+		maxLifetime:   cfg.maxLifetime,
+		absolute:      cfg.absolute,
+		preSave:       cfg.preSave,
+		onClose:       cfg.onClose,
+		onRecover:     cfg.onRecover,
+		metrics:       cfg.metrics,
+
+		atRest:    cfg.atRest,
+		prefix:    cfg.prefix,
+		userIDCol: cfg.userIDCol,
+		tagging:   cfg.tagging,
+		piiKeys:   cfg.piiKeys,
+
+		encryptedKeys:  cfg.encryptedKeys,
+		coalesceWindow: cfg.coalesceWindow,
+		chunked:        cfg.chunked,
+
+		Codecs:        codecsFromPairs(cfg.maxLength, keyPairs...),
+		Options:       &cfg.cookie,
+		SlidingExpiry: true,
+		MaxLength:     cfg.maxLength,
+		TrackDirty:    cfg.trackDirty,
+		Clock:         cfg.clock,
+		Hooks:         cfg.hooks,
 	}
 
-	delQ := "DELETE FROM sessions WHERE id = ?"
-	del, err := db.Prepare(delQ)
-	if err != nil {
+	if m.chunked && m.coalesceWindow > 0 {
+		return nil, errChunkedCoalescing
+	}
+	if m.schema == nil {
+		m.schema = DefaultSchemaProvider{Options: m.tableOpts, optional: m.optionalColumns()}
+	}
+	if cfg.noLocks {
+		m.warn(disabledLockWarning)
+	}
+	if cfg.cacheSize > 0 {
+		m.cache = newSessionCache(cfg.cacheSize)
+	}
+
+	// page_size only takes effect before the first table is created
+	if cfg.pageSize > 0 && !m.readOnly {
+		if _, err := db.Exec(fmt.Sprintf("PRAGMA page_size = %d", cfg.pageSize)); err != nil {
+			return nil, err
+		}
+	}
+	if cfg.mmapSet {
+		if _, err := db.Exec(fmt.Sprintf("PRAGMA mmap_size = %d", cfg.mmapSize)); err != nil {
+			return nil, err
+		}
+	}
+	// a database that can't use WAL, such as one on a network filesystem,
+	// still works in its current journal mode
+	if cfg.walMode && !m.readOnly {
+		if err := m.ConvertToWAL(context.Background()); err != nil {
+			m.warn("sqlitestore: WAL mode not enabled", "error", err)
+		}
+	}
+	if cfg.busyTimeout > 0 {
+		if _, err := db.Exec(fmt.Sprintf("PRAGMA busy_timeout = %d", cfg.busyTimeout.Milliseconds())); err != nil {
+			return nil, err
+		}
+	}
+	if len(m.encryptedKeys) > 0 {
+		if len(keyPairs) == 0 {
+			return nil, errors.New("sqlitestore: encrypted values require a hash key")
+		}
+		var err error
+		if m.valuesAEAD, err = newValuesCipher(keyPairs[0]); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := m.VerifyCodecs(); err != nil {
 		return nil, err
 	}
+	if cfg.versionCheck {
+		if err := m.checkSchemaVersion(context.Background(), cfg.minVersion, cfg.maxVersion); err != nil {
+			return nil, err
+		}
+	}
+	if m.tableOpts.Strict {
+		if err := m.checkStrictSupport(context.Background()); err != nil {
+			return nil, err
+		}
+	}
+	if !m.readOnly {
+		if err := m.prepareWrites(); err != nil {
+			return nil, err
+		}
+	}
 
-	updQ := "UPDATE sessions SET session_data = ?, created_on = ?, expires_on = ? " +
-		"WHERE id = ?"
-	update, err := db.Prepare(updQ)
-	if err != nil {
+	var err error
+	selQ := "SELECT id, session_data, created_on, modified_on, expires_on from " + m.table + " WHERE id = ?"
+	if m.get, err = db.Prepare(selQ); err != nil {
 		return nil, err
 	}
+	if m.exists, err = db.Prepare("SELECT 1 FROM " + m.table + " WHERE id = ? AND expires_on > ?"); err != nil {
+		return nil, err
+	}
+
+	if cfg.reloadFetch != nil {
+		m.startHotReload(cfg.reloadFetch, cfg.reloadInterval, keyPairs)
+	}
+	if cfg.cleanupInterval > 0 && !m.readOnly {
+		if err := m.startCleanup(cfg.cleanupInterval); err != nil {
+			return nil, err
+		}
+	}
+	if cfg.watchInterval > 0 {
+		m.startConnectionWatcher(cfg.watchInterval, cfg.onLost, cfg.onRestored)
+	}
+	if cfg.indexRefresh > 0 {
+		m.startValueIndexRefresh(cfg.indexRefresh)
+	}
+	return m, nil
+}
+
+// prepareWrites creates and migrates the sessions table and prepares the
+// statements that modify it. Read-only stores skip it.
+func (m *Store) prepareWrites() error {
+	if err := m.createSchema(context.Background()); err != nil {
+		return err
+	}
+	if err := m.MigrateSchema(context.Background()); err != nil {
+		return err
+	}
+	if err := m.createIndexes(); err != nil {
+		return err
+	}
+	if m.tagging {
+		if err := m.createTagIndex(context.Background()); err != nil {
+			return err
+		}
+	}
+	if err := m.createInsertTriggers(context.Background()); err != nil {
+		return err
+	}
+	if m.chunked {
+		if err := m.createValuesTable(context.Background()); err != nil {
+			return err
+		}
+	}
 
-	selQ := "SELECT id, session_data, created_on, modified_on, expires_on from sessions WHERE id = ?"
-	get, stmtErr := db.Prepare(selQ)
-	if stmtErr != nil {
-		return nil, stmtErr
+	var err error
+	cols := m.writeColumns()
+	idParam := "NULL"
+	if m.tableOpts.WithoutRowid {
+		idParam = "?"
+	}
+	insQ := "INSERT INTO " + m.table + " (id, session_data, created_on, modified_on, expires_on, " +
+		strings.Join(cols, ", ") + ") VALUES (" + idParam + ", ?, ?, ?, ?" + strings.Repeat(", ?", len(cols)) + ")"
+	if m.create, err = m.db.Prepare(insQ); err != nil {
+		return err
 	}
 
-	return &Store{
-		db:     db,
-		create: create,
-		delete: del,
-		update: update,
-		get:    get,
-		Codecs: securecookie.CodecsFromPairs(keyPairs...),
-		Options: &sessions.Options{
-			Path:   "/",
-			MaxAge: 60 * 60 * 24 * 14,
-		},
-	}, nil
+	delQ := "DELETE FROM " + m.table + " WHERE id = ?"
+	if m.delete, err = m.db.Prepare(delQ); err != nil {
+		return err
+	}
+
+	updQ := "UPDATE " + m.table + " SET session_data = ?, created_on = ?, expires_on = ?, " +
+		strings.Join(cols, " = ?, ") + " = ? WHERE id = ?"
+	if m.update, err = m.db.Prepare(updQ); err != nil {
+		return err
+	}
+	return nil
 }
 
+// Close stops any background goroutines and releases the prepared statements
+// and the underlying database. Calling Close more than once is a no-op.
 func (m *Store) Close() {
-	m.get.Close()
-	m.update.Close()
-	m.delete.Close()
-	m.create.Close()
-	m.db.Close()
+	m.closed.Do(func() {
+		close(m.done)
+		m.wg.Wait()
+		if err := m.flushWrites(context.Background()); err != nil {
+			m.handleError(err)
+		}
+		for _, stmt := range []*sql.Stmt{m.get, m.exists, m.update, m.delete, m.create} {
+			if stmt != nil {
+				stmt.Close()
+			}
+		}
+		if db, ok := m.db.(*sql.DB); ok && m.onClose != nil {
+			m.runHook("OnClose", func() {
+				if err := m.onClose(db); err != nil {
+					m.handleError(err)
+				}
+			})
+		}
+		m.db.Close()
+	})
+}
+
+// GetUnderlyingDB returns the database the store was created with, for
+// custom queries such as joining sessions with application tables in the same
+// file.
+//
+// Do not change the sessions table schema through it; use MigrateSchema and
+// the schema options instead, or the store's prepared statements and
+// migrations will no longer match the table.
+func (m *Store) GetUnderlyingDB() DB { // nolint:gocritic // exposes the internal DB on purpose
+	return m.db
 }
 
 func (m *Store) Get(r *http.Request, name string) (*sessions.Session, error) {
 	return sessions.GetRegistry(r).Get(m, name)
 }
 
+// GetContext is Get with ctx passed to the database calls of the first
+// lookup of name in the request. As with Get, later calls return the session
+// registered by the first.
+func (m *Store) GetContext(ctx context.Context, r *http.Request, name string) (*sessions.Session, error) {
+	return sessions.GetRegistry(r).Get(contextStore{m, ctx}, name)
+}
+
+// contextStore makes the registry create sessions with NewContext.
+type contextStore struct {
+	*Store
+	ctx context.Context
+}
+
+func (s contextStore) New(r *http.Request, name string) (*sessions.Session, error) {
+	return s.Store.NewContext(s.ctx, r, name)
+}
+
 func (m *Store) New(r *http.Request, name string) (*sessions.Session, error) {
+	return m.NewContext(context.Background(), r, name)
+}
+
+// NewContext is New with ctx passed to the database calls.
+func (m *Store) NewContext(ctx context.Context, r *http.Request, name string) (*sessions.Session, error) {
 	session := sessions.NewSession(m, name)
-	session.Options = &sessions.Options{
-		Path:   m.Options.Path,
-		MaxAge: m.Options.MaxAge,
-	}
+	session.Options = m.newOptions(name)
 	session.IsNew = true
+	if err := m.checkConn(); err != nil {
+		return session, err
+	}
 	var err error
 	if cook, errCookie := r.Cookie(name); errCookie == nil {
-		err = securecookie.DecodeMulti(name, cook.Value, &session.ID, m.Codecs...)
+		err = securecookie.DecodeMulti(name, cook.Value, &session.ID, m.codecs()...)
 		if err == nil {
-			err = m.load(session)
+			err = m.load(ctx, session)
 			if err == nil {
 				session.IsNew = false
 			} else {
@@ -136,22 +470,92 @@ func (m *Store) New(r *http.Request, name string) (*sessions.Session, error) {
 	return session, err
 }
 
+// NewSession creates and stores a session outside of an HTTP request, for
+// example to mail a login link from a background job. It returns the session
+// and the cookie value that identifies it. opts defaults to the options New
+// would use for name.
+func (m *Store) NewSession(ctx context.Context, name string, values map[interface{}]interface{}, opts *sessions.Options) (*sessions.Session, string, error) {
+	if m.readOnly {
+		return nil, "", ErrReadOnly
+	}
+	if err := m.checkConn(); err != nil {
+		return nil, "", err
+	}
+	session := sessions.NewSession(m, name)
+	if opts == nil {
+		opts = m.newOptions(name)
+	}
+	session.Options = opts
+	if values != nil {
+		session.Values = values
+	}
+	session.IsNew = true
+	if err := m.insert(ctx, session); err != nil {
+		return nil, "", err
+	}
+	encoded, err := securecookie.EncodeMulti(name, session.ID, m.codecs()...)
+	if err != nil {
+		return nil, "", err
+	}
+	return session, encoded, nil
+}
+
+// codecsFromPairs returns the codecs for keyPairs. With a maxLength set the
+// store enforces the limit itself, so the securecookie limit is lifted.
+func codecsFromPairs(maxLength int, keyPairs ...[]byte) []securecookie.Codec {
+	codecs := securecookie.CodecsFromPairs(keyPairs...)
+	if maxLength > 0 {
+		for _, codec := range codecs {
+			if sc, ok := codec.(*securecookie.SecureCookie); ok {
+				sc.MaxLength(0)
+			}
+		}
+	}
+	return codecs
+}
+
+func (m *Store) codecs() []securecookie.Codec {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return m.Codecs
+}
+
+func (m *Store) handleError(err error) {
+	if m.onError != nil {
+		m.onError(err)
+	}
+}
+
 func (m *Store) Save(r *http.Request, w http.ResponseWriter, session *sessions.Session) error {
+	return m.SaveContext(context.Background(), r, w, session)
+}
+
+// SaveContext is Save with ctx passed to the database calls.
+func (m *Store) SaveContext(ctx context.Context, r *http.Request, w http.ResponseWriter, session *sessions.Session) error {
+	if session.Options == nil {
+		session.Options = m.newOptions(session.Name())
+	}
 	// in accordance with the sessions spec, a MaxAge <=0 triggers deleting the cookie from storage
 	// and should also cause the browser to delete the cookie
 	if session.Options.MaxAge <= 0 {
-		return m.Delete(r, w, session)
+		return m.DeleteContext(ctx, r, w, session)
+	}
+	if m.readOnly {
+		return ErrReadOnly
+	}
+	if err := m.checkConn(); err != nil {
+		return err
 	}
 
 	var err error
 	if session.ID == "" {
-		if err = m.insert(session); err != nil {
+		if err = m.insert(ctx, session); err != nil {
 			return err
 		}
-	} else if err = m.save(session); err != nil {
+	} else if err = m.save(ctx, session); err != nil {
 		return err
 	}
-	encoded, err := securecookie.EncodeMulti(session.Name(), session.ID, m.Codecs...)
+	encoded, err := securecookie.EncodeMulti(session.Name(), session.ID, m.codecs()...)
 	if err != nil {
 		return err
 	}
@@ -159,49 +563,127 @@ func (m *Store) Save(r *http.Request, w http.ResponseWriter, session *sessions.S
 	return nil
 }
 
-func (m *Store) insert(session *sessions.Session) error {
-	m.mu.Lock()
-	defer m.mu.Unlock()
+func (m *Store) insert(ctx context.Context, session *sessions.Session) (err error) {
+	defer m.observeOp("insert", time.Now(), &err)
+	if m.chunked {
+		err = m.saveChunked(ctx, session, true)
+	} else {
+		err = m.insertStmt(ctx, m.create, session)
+	}
+	if err != nil {
+		return err
+	}
+	m.runOnCreate(session)
+	if m.gcProbability > 0 && rand.Float64() < m.gcProbability {
+		m.wg.Add(1)
+		go func() {
+			defer m.wg.Done()
+			if _, err := m.GarbageCollect(context.Background()); err != nil {
+				m.handleError(err)
+			}
+		}()
+	}
+	return nil
+}
 
+// insertStmt writes a new session row using stmt, which is either the store's
+// insert statement or that statement bound to a transaction.
+func (m *Store) insertStmt(ctx context.Context, stmt *sql.Stmt, session *sessions.Session) error {
+	if err := m.runPreSave(session); err != nil {
+		return err
+	}
+	ctx, cancel := m.opContext(ctx, opInsert)
+	defer cancel()
+	// the row id is not known until after the insert, so all inserts share a lock
+	if err := m.lock(ctx, ""); err != nil {
+		return err
+	}
+	defer m.locks.Unlock("")
+
+	args, err := m.insertArgs(session)
+	if err != nil {
+		return err
+	}
+	args = m.transformArgs(m.insertColumns(), args)
+	if m.tableOpts.WithoutRowid {
+		id, err := newTextID()
+		if err != nil {
+			return err
+		}
+		start := time.Now()
+		_, insErr := stmt.ExecContext(ctx, append([]interface{}{id}, args...)...)
+		m.observeQuery("insert", "", start, insErr)
+		if insErr != nil {
+			return insErr
+		}
+		session.ID = m.prefix + id
+		return nil
+	}
+	start := time.Now()
+	res, insErr := stmt.ExecContext(ctx, args...)
+	m.observeQuery("insert", "", start, insErr)
+	if insErr != nil {
+		return insErr
+	}
+	lastInserted, lInsErr := res.LastInsertId()
+	if lInsErr != nil {
+		return lInsErr
+	}
+	session.ID = m.prefix + fmt.Sprintf("%d", lastInserted)
+	return nil
+}
+
+// insertArgs encodes session and returns the arguments for the insert
+// statement.
+func (m *Store) insertArgs(session *sessions.Session) ([]interface{}, error) {
 	var createdOn time.Time
 	var modifiedOn time.Time
 	var expiresOn time.Time
+	now := m.clock()
 	crOn := session.Values["created_on"]
 	if crOn == nil {
-		createdOn = time.Now()
+		createdOn = now
 	} else {
 		createdOn = crOn.(time.Time)
 	}
 	modifiedOn = createdOn
 	exOn := session.Values["expires_on"]
 	if exOn == nil {
-		expiresOn = time.Now().Add(time.Second * time.Duration(session.Options.MaxAge))
+		expiresOn = now.Add(time.Second * time.Duration(session.Options.MaxAge))
 	} else {
 		expiresOn = exOn.(time.Time)
 	}
+	expiresOn = m.capExpiry(createdOn, expiresOn)
 	delete(session.Values, "created_on")
 	delete(session.Values, "expires_on")
 	delete(session.Values, "modified_on")
 
-	encoded, encErr := securecookie.EncodeMulti(session.Name(), session.Values, m.Codecs...)
+	encoded, encErr := m.encodeValues(session)
 	if encErr != nil {
-		return encErr
-	}
-	res, insErr := m.create.Exec(encoded, createdOn, modifiedOn, expiresOn)
-	if insErr != nil {
-		return insErr
-	}
-	lastInserted, lInsErr := res.LastInsertId()
-	if lInsErr != nil {
-		return lInsErr
+		return nil, encErr
 	}
-	session.ID = fmt.Sprintf("%d", lastInserted)
-	return nil
+	return append([]interface{}{encoded, createdOn, modifiedOn, expiresOn}, m.columnValues(session)...), nil
 }
 
 func (m *Store) Delete(r *http.Request, w http.ResponseWriter, session *sessions.Session) error {
-	m.mu.Lock()
-	defer m.mu.Unlock()
+	return m.DeleteContext(context.Background(), r, w, session)
+}
+
+// DeleteContext is Delete with ctx passed to the database calls.
+func (m *Store) DeleteContext(ctx context.Context, r *http.Request, w http.ResponseWriter, session *sessions.Session) (err error) {
+	defer m.observeOp("delete", time.Now(), &err)
+	if m.readOnly {
+		return ErrReadOnly
+	}
+	if err := m.checkConn(); err != nil {
+		return err
+	}
+	if err := m.lock(ctx, session.ID); err != nil {
+		return err
+	}
+	defer m.locks.Unlock(session.ID)
+	// a pending coalesced write would only resurrect the row
+	m.cancelWrite(session.ID)
 
 	// Set cookie to expire.
 	options := *session.Options
@@ -212,73 +694,214 @@ func (m *Store) Delete(r *http.Request, w http.ResponseWriter, session *sessions
 		delete(session.Values, k)
 	}
 
-	_, delErr := m.delete.Exec(session.ID)
+	ctx, cancel := m.opContext(ctx, opDelete)
+	defer cancel()
+	start := time.Now()
+	_, delErr := m.delete.ExecContext(ctx, m.rowID(session.ID))
+	m.observeQuery("delete", session.ID, start, delErr)
+	m.cache.remove(session.ID)
+	m.checksums.Delete(session.ID)
 	if delErr != nil {
 		return delErr
 	}
+	if m.hasValueIndex() {
+		if err := m.InvalidateValueIndex(session.ID); err != nil {
+			m.handleError(err)
+		}
+	}
+	m.runOnDelete(session.ID)
 	return nil
 }
 
-func (m *Store) save(session *sessions.Session) error {
-	m.mu.Lock()
-	defer m.mu.Unlock()
+func (m *Store) save(ctx context.Context, session *sessions.Session) (err error) {
+	defer m.observeOp("save", time.Now(), &err)
+	if !m.TrackDirty || session.IsNew {
+		return m.write(ctx, session)
+	}
+	if m.unchanged(session) {
+		// with WithSlidingExpiration load has already moved the expiry
+		if !m.SlidingExpiry || m.sliding > 0 {
+			return nil
+		}
+		// only the expiry needs to move; a missing row is written in full
+		if err := m.Touch(session); err != ErrSessionNotFound {
+			return err
+		}
+	}
+	expiresOn, _ := session.Values["expires_on"].(time.Time)
+	if err := m.write(ctx, session); err != nil {
+		return err
+	}
+	m.trackValues(session, expiresOn)
+	return nil
+}
 
+// write saves session with the statement its state calls for.
+func (m *Store) write(ctx context.Context, session *sessions.Session) error {
+	if m.chunked {
+		return m.saveChunked(ctx, session, session.IsNew)
+	}
 	if session.IsNew {
-		return m.insert(session)
+		return m.insert(ctx, session)
+	}
+	if m.coalesceWindow > 0 {
+		return m.saveCoalesced(ctx, session)
+	}
+	return m.saveStmt(ctx, m.update, session)
+}
+
+// saveStmt updates an existing session row using stmt, which is either the
+// store's update statement or that statement bound to a transaction.
+func (m *Store) saveStmt(ctx context.Context, stmt *sql.Stmt, session *sessions.Session) error {
+	if err := m.runPreSave(session); err != nil {
+		return err
+	}
+	ctx, cancel := m.opContext(ctx, opInsert)
+	defer cancel()
+	if err := m.lock(ctx, session.ID); err != nil {
+		return err
 	}
+	defer m.locks.Unlock(session.ID)
+
+	args, err := m.updateArgs(session)
+	if err != nil {
+		return err
+	}
+	start := time.Now()
+	_, updErr := stmt.ExecContext(ctx, m.transformArgs(m.updateColumns(), args)...)
+	m.observeQuery("update", session.ID, start, updErr)
+	m.cache.remove(session.ID)
+	if updErr != nil {
+		return updErr
+	}
+	return nil
+}
+
+// updateArgs encodes session and returns the arguments for the update
+// statement.
+func (m *Store) updateArgs(session *sessions.Session) ([]interface{}, error) {
 	var createdOn time.Time
 	var expiresOn time.Time
+	now := m.clock()
 	crOn := session.Values["created_on"]
 	if crOn == nil {
-		createdOn = time.Now()
+		createdOn = now
 	} else {
 		createdOn = crOn.(time.Time)
 	}
 
 	exOn := session.Values["expires_on"]
-	if exOn == nil {
-		expiresOn = time.Now().Add(time.Second * time.Duration(session.Options.MaxAge))
-	} else {
+	switch {
+	case exOn == nil && !m.SlidingExpiry:
+		expiresOn = createdOn.Add(time.Second * time.Duration(session.Options.MaxAge))
+	case exOn == nil:
+		expiresOn = now.Add(time.Second * time.Duration(session.Options.MaxAge))
+	default:
 		expiresOn = exOn.(time.Time)
-		if expiresOn.Sub(time.Now().Add(time.Second*time.Duration(session.Options.MaxAge))) < 0 {
-			expiresOn = time.Now().Add(time.Second * time.Duration(session.Options.MaxAge))
+		// with sliding expiration, load has already moved expires_on as far as allowed
+		if m.sliding == 0 && m.SlidingExpiry && expiresOn.Sub(now.Add(time.Second*time.Duration(session.Options.MaxAge))) < 0 {
+			expiresOn = now.Add(time.Second * time.Duration(session.Options.MaxAge))
 		}
 	}
+	expiresOn = m.capExpiry(createdOn, expiresOn)
 
 	delete(session.Values, "created_on")
 	delete(session.Values, "expires_on")
 	delete(session.Values, "modified_on")
-	encoded, encErr := securecookie.EncodeMulti(session.Name(), session.Values, m.Codecs...)
+	encoded, encErr := m.encodeValues(session)
 	if encErr != nil {
-		return encErr
+		return nil, encErr
 	}
-	_, updErr := m.update.Exec(encoded, createdOn, expiresOn, session.ID)
-	if updErr != nil {
-		return updErr
-	}
-	return nil
+	args := append([]interface{}{encoded, createdOn, expiresOn}, m.columnValues(session)...)
+	return append(args, m.rowID(session.ID)), nil
 }
 
-func (m *Store) load(session *sessions.Session) error {
-	m.mu.RLock()
-	defer m.mu.RUnlock()
+func (m *Store) load(ctx context.Context, session *sessions.Session) (err error) {
+	defer m.observeOp("load", time.Now(), &err)
+	if err := m.rlock(ctx, session.ID); err != nil {
+		return err
+	}
+	defer m.locks.RUnlock(session.ID)
 
-	row := m.get.QueryRow(session.ID)
-	sess := sessionRow{}
-	scanErr := row.Scan(&sess.id, &sess.data, &sess.createdOn, &sess.modifiedOn, &sess.expiresOn)
-	if scanErr != nil {
-		return scanErr
+	sess, cached := m.cache.get(session.ID)
+	if !cached {
+		if sess, err = m.getRow(ctx, session.ID); err != nil {
+			return err
+		}
+		m.cache.add(session.ID, sess)
+	}
+	if pw := m.pendingWrite(session.ID); pw != nil {
+		sess.data, sess.expiresOn = pw.data, pw.expiresOn
 	}
-	if time.Until(sess.expiresOn) < 0 {
+	if m.capExpiry(sess.createdOn, sess.expiresOn).Before(m.clock()) {
+		m.runOnExpire(session.ID)
+		if m.metrics != nil {
+			m.metrics.IncOp("expire")
+		}
 		return SessionExpired
 	}
-	err := securecookie.DecodeMulti(session.Name(), sess.data, &session.Values, m.Codecs...)
-	if err != nil {
+	if m.sliding > 0 && !m.readOnly {
+		expiresOn := sess.expiresOn
+		if err := m.slideExpiry(ctx, &sess); err != nil {
+			return err
+		}
+		if !sess.expiresOn.Equal(expiresOn) {
+			m.cache.remove(session.ID)
+		}
+	}
+	if err := m.decodeValues(session, sess.data); err != nil {
 		return err
 	}
+	if m.chunked {
+		if err := m.readChunks(ctx, m.db, session); err != nil {
+			return err
+		}
+	}
 	session.Values["created_on"] = sess.createdOn
 	session.Values["modified_on"] = sess.modifiedOn
 	session.Values["expires_on"] = sess.expiresOn
+	if m.TrackDirty {
+		m.trackValues(session, sess.expiresOn)
+	}
+	m.runOnLoad(ctx, session, &sess)
 	return nil
 
 }
+
+// Exists reports whether the session id has an unexpired row, without
+// reading or decoding its data.
+func (m *Store) Exists(id string) (bool, error) {
+	if err := m.checkConn(); err != nil {
+		return false, err
+	}
+	ctx, cancel := m.opContext(context.Background(), opSelect)
+	defer cancel()
+	if err := m.rlock(ctx, id); err != nil {
+		return false, err
+	}
+	defer m.locks.RUnlock(id)
+
+	now := m.clock()
+	if pw := m.pendingWrite(id); pw != nil {
+		return pw.expiresOn.After(now), nil
+	}
+	start := time.Now()
+	var one int
+	err := m.exists.QueryRowContext(ctx, m.rowID(id), m.transform("expires_on", now)).Scan(&one)
+	m.observeQuery("exists", id, start, err)
+	if err == sql.ErrNoRows {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+// getRow reads the row of the session id with the select statement.
+func (m *Store) getRow(ctx context.Context, id string) (sessionRow, error) {
+	ctx, cancel := m.opContext(ctx, opSelect)
+	defer cancel()
+	start := time.Now()
+	var sess sessionRow
+	err := m.scanSession(m.get.QueryRowContext(ctx, m.rowID(id)), &sess)
+	m.observeQuery("select", id, start, err)
+	return sess, err
+}
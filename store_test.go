@@ -1,8 +1,10 @@
 package sqlitestore
 
 import (
+	"context"
 	"database/sql"
 	"io/ioutil"
+	"net/http"
 	"net/http/httptest"
 	"os"
 	"path/filepath"
@@ -25,7 +27,7 @@ func TestSession(t *testing.T) {
 	require.NoError(t, err)
 	defer os.RemoveAll(tmpdir)
 
-	store, err := NewStore(db, securecookie.GenerateRandomKey(32))
+	store, err := NewStore(db, [][]byte{securecookie.GenerateRandomKey(32)})
 	require.NoError(t, err)
 
 	r := httptest.NewRequest("GET", "/", nil)
@@ -44,7 +46,7 @@ func TestSessionRenewal(t *testing.T) {
 	require.NoError(t, err)
 	defer os.RemoveAll(tmpdir)
 
-	store, err := NewStore(db, securecookie.GenerateRandomKey(32))
+	store, err := NewStore(db, [][]byte{securecookie.GenerateRandomKey(32)})
 	require.NoError(t, err)
 
 	r := httptest.NewRequest("GET", "/", nil)
@@ -70,7 +72,8 @@ func TestSessionExpires(t *testing.T) {
 	require.NoError(t, err)
 	defer os.RemoveAll(tmpdir)
 
-	store, err := NewStore(db, securecookie.GenerateRandomKey(32))
+	clock := &fakeClock{t: time.Unix(0, 0)}
+	store, err := NewStore(db, [][]byte{securecookie.GenerateRandomKey(32)}, WithClock(clock.Now))
 	require.NoError(t, err)
 	store.Options = &sessions.Options{
 		MaxAge: 1,
@@ -83,7 +86,7 @@ func TestSessionExpires(t *testing.T) {
 	w := httptest.NewRecorder()
 	assert.NoError(t, sess.Save(r, w))
 
-	time.Sleep(2 * time.Second)
+	clock.Advance(2 * time.Second)
 
 	r2 := httptest.NewRequest("GET", "/", nil)
 	r2.Header.Add("Cookie", w.Header().Get("Set-Cookie"))
@@ -102,7 +105,7 @@ func TestSessionDelete(t *testing.T) {
 	require.NoError(t, err)
 	defer os.RemoveAll(tmpdir)
 
-	store, err := NewStore(db, securecookie.GenerateRandomKey(32))
+	store, err := NewStore(db, [][]byte{securecookie.GenerateRandomKey(32)})
 	require.NoError(t, err)
 
 	r := httptest.NewRequest("GET", "/", nil)
@@ -131,3 +134,156 @@ func TestSessionDelete(t *testing.T) {
 	assert.NoError(t, err)
 	assert.True(t, sess3.IsNew)
 }
+
+func tempDBPath(t testing.TB) string {
+	tmpdir, err := ioutil.TempDir("", "store-test")
+	require.NoError(t, err)
+	t.Cleanup(func() { os.RemoveAll(tmpdir) })
+	return filepath.Join(tmpdir, "test.db")
+}
+
+// newFileTestDB opens a database in a temporary file, for tests of features
+// in-memory databases lack, such as WAL and mmap.
+func newFileTestDB(t testing.TB) *sql.DB {
+	db, err := sql.Open("sqlite3", tempDBPath(t))
+	require.NoError(t, err)
+	return db
+}
+
+func newTestStoreDB(t testing.TB, db DB, opts ...StoreOption) *Store {
+	store, err := New(db, [][]byte{securecookie.GenerateRandomKey(32)}, opts...)
+	require.NoError(t, err)
+	t.Cleanup(store.Close)
+	return store
+}
+
+func newTestStore(t testing.TB, opts ...StoreOption) *Store {
+	return newTestStoreDB(t, newTestDB(t), opts...)
+}
+
+// insertTestSession saves a new session with the given values directly
+// through insert and returns it.
+func insertTestSession(t testing.TB, store *Store, values map[interface{}]interface{}) *sessions.Session {
+	sess := sessions.NewSession(store, "test")
+	sess.Options = &sessions.Options{MaxAge: 3600}
+	for k, v := range values {
+		sess.Values[k] = v
+	}
+	require.NoError(t, store.insert(context.Background(), sess))
+	return sess
+}
+
+func TestGetUnderlyingDB(t *testing.T) {
+	store := newTestStore(t)
+	defer store.Close()
+	insertTestSession(t, store, nil)
+
+	db := store.GetUnderlyingDB()
+	_, err := db.Exec("CREATE TABLE users (id INTEGER PRIMARY KEY, session_id INTEGER)")
+	require.NoError(t, err)
+	_, err = db.Exec("INSERT INTO users (session_id) SELECT id FROM sessions")
+	require.NoError(t, err)
+
+	var n int
+	require.NoError(t, db.QueryRowContext(context.Background(),
+		"SELECT COUNT(*) FROM users JOIN sessions ON sessions.id = users.session_id").Scan(&n))
+	assert.Equal(t, 1, n)
+}
+
+func TestNewSessionWithoutRequest(t *testing.T) {
+	store := newTestStore(t)
+	sess, cookie, err := store.NewSession(context.Background(), "remember",
+		map[interface{}]interface{}{"user": "alice"}, nil)
+	require.NoError(t, err)
+	assert.NotEmpty(t, sess.ID)
+
+	r := httptest.NewRequest("GET", "/", nil)
+	r.AddCookie(&http.Cookie{Name: "remember", Value: cookie})
+	loaded, err := store.New(r, "remember")
+	require.NoError(t, err)
+	assert.False(t, loaded.IsNew)
+	assert.Equal(t, sess.ID, loaded.ID)
+	assert.Equal(t, "alice", loaded.Values["user"])
+}
+
+func TestContextMethods(t *testing.T) {
+	store := newTestStore(t)
+	ctx := context.Background()
+	canceled, cancel := context.WithCancel(ctx)
+	cancel()
+
+	r := httptest.NewRequest("GET", "/", nil)
+	sess, err := store.GetContext(ctx, r, "test")
+	require.NoError(t, err)
+	again, err := store.GetContext(ctx, r, "test")
+	require.NoError(t, err)
+	assert.Same(t, sess, again)
+
+	sess.Values["name"] = "alice"
+	assert.Error(t, store.SaveContext(canceled, r, httptest.NewRecorder(), sess))
+	assert.Equal(t, 0, countRows(t, store))
+	w := httptest.NewRecorder()
+	require.NoError(t, store.SaveContext(ctx, r, w, sess))
+
+	r2 := httptest.NewRequest("GET", "/", nil)
+	r2.Header.Add("Cookie", w.Header().Get("Set-Cookie"))
+	loaded, err := store.NewContext(ctx, r2, "test")
+	require.NoError(t, err)
+	assert.False(t, loaded.IsNew)
+	assert.Equal(t, "alice", loaded.Values["name"])
+
+	assert.Error(t, store.DeleteContext(canceled, r2, httptest.NewRecorder(), loaded))
+	assert.Equal(t, 1, countRows(t, store))
+	require.NoError(t, store.DeleteContext(ctx, r2, httptest.NewRecorder(), loaded))
+	assert.Equal(t, 0, countRows(t, store))
+}
+
+func TestExists(t *testing.T) {
+	store := newTestStore(t)
+	live := insertTestSession(t, store, map[interface{}]interface{}{"name": "alice"})
+	expired := insertTestSession(t, store, map[interface{}]interface{}{"expires_on": time.Now().Add(-time.Hour)})
+
+	for id, want := range map[string]bool{live.ID: true, expired.ID: false, "12345": false} {
+		ok, err := store.Exists(id)
+		require.NoError(t, err)
+		assert.Equal(t, want, ok, id)
+	}
+}
+
+// benchmarkSessionCookie saves a session with a value large enough that
+// decoding it is noticeable and returns a request carrying its cookie.
+func benchmarkSessionCookie(b *testing.B, store *Store) (*http.Request, *sessions.Session) {
+	r := httptest.NewRequest("GET", "/", nil)
+	sess, err := store.New(r, "bench")
+	require.NoError(b, err)
+	for i := 0; i < 50; i++ {
+		sess.Values[i] = "value"
+	}
+	w := httptest.NewRecorder()
+	require.NoError(b, store.Save(r, w, sess))
+	r = httptest.NewRequest("GET", "/", nil)
+	r.Header.Add("Cookie", w.Header().Get("Set-Cookie"))
+	return r, sess
+}
+
+func BenchmarkNew(b *testing.B) {
+	store := newTestStore(b)
+	r, _ := benchmarkSessionCookie(b, store)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if sess, err := store.New(r, "bench"); err != nil || sess.IsNew {
+			b.Fatalf("new: %v", err)
+		}
+	}
+}
+
+func BenchmarkExists(b *testing.B) {
+	store := newTestStore(b)
+	_, sess := benchmarkSessionCookie(b, store)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if ok, err := store.Exists(sess.ID); err != nil || !ok {
+			b.Fatalf("exists: %v", err)
+		}
+	}
+}
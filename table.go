@@ -0,0 +1,267 @@
+package sqlitestore
+
+import (
+	"context"
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/mattn/go-sqlite3"
+)
+
+var errTextIDs = errors.New("sqlitestore: operation requires integer session IDs")
+
+// afterID returns the condition and arguments that page past the row lastID
+// in id order, or a condition that is always true for the first page, when
+// lastID is nil. It works for integer and text IDs alike, as SQLite compares
+// the text argument to an integer id as a number.
+func afterID(lastID *string) (string, []interface{}) {
+	if lastID == nil {
+		return "1", nil
+	}
+	return "id > ?", []interface{}{*lastID}
+}
+
+// ColumnDef is an extra column added to the sessions table. Default must be
+// a constant expression, as ALTER TABLE requires when the column is added
+// to an existing table; an empty Default means NULL.
+type ColumnDef struct {
+	Name    string
+	Type    string
+	Default string
+}
+
+// TableOptions selects the variant of the sessions table New creates.
+//
+// Strict creates a STRICT table, which needs SQLite 3.37 or later; the
+// session data and timestamps are then stored as TEXT. WithoutRowid creates
+// a WITHOUT ROWID table keyed by random UUID session IDs stored as TEXT;
+// LoadBatch and CopySessionsTo only support integer IDs. Both only apply when
+// the table is created, not to an existing table.
+type TableOptions struct {
+	Strict       bool
+	WithoutRowid bool
+	ExtraColumns []ColumnDef
+}
+
+// extraColumns returns the extra columns in the form MigrateSchema uses.
+func (o TableOptions) extraColumns() []columnDef {
+	cols := make([]columnDef, 0, len(o.ExtraColumns))
+	for _, c := range o.ExtraColumns {
+		def := c.Default
+		if def == "" {
+			def = "NULL"
+		}
+		cols = append(cols, columnDef{strings.ToLower(c.Name), c.Type, def})
+	}
+	return cols
+}
+
+// SchemaProvider generates the DDL New runs to create the sessions table,
+// so that it can use SQLite features the built-in schema does not. The table
+// must have the columns of the built-in schema; MigrateSchema adds any that
+// are missing.
+type SchemaProvider interface {
+	// CreateTableDDL returns the statement creating tableName. It should
+	// not fail if the table already exists.
+	CreateTableDDL(tableName string) string
+	// IndexDDLs returns statements run after the table is created, such as
+	// CREATE INDEX IF NOT EXISTS.
+	IndexDDLs(tableName string) []string
+	// SchemaVersion returns the schema version recorded in user_version
+	// once the schema is created. Versions lower than the recorded one are
+	// ignored.
+	SchemaVersion() int
+}
+
+// DefaultSchemaProvider is the SchemaProvider New uses when none is set with
+// WithSchemaProvider. It creates the table variant selected by Options.
+type DefaultSchemaProvider struct {
+	Options TableOptions
+
+	// optional are the columns store options add, such as user_id, so a
+	// new table has them from the start
+	optional []columnDef
+}
+
+// CreateTableDDL returns the DDL for the sessions table.
+func (p DefaultSchemaProvider) CreateTableDDL(tableName string) string {
+	idType := "INTEGER"
+	if p.Options.WithoutRowid {
+		idType = "TEXT"
+	}
+	defs := []string{"id " + idType + " PRIMARY KEY"}
+	cols := append(append([]columnDef(nil), baseColumns...), p.optional...)
+	for _, col := range append(cols, p.Options.extraColumns()...) {
+		def := col.def
+		if col.name == "modified_on" {
+			def = "CURRENT_TIMESTAMP"
+		}
+		d := col.name + " " + p.columnType(col.typ)
+		if def != "NULL" {
+			d += " DEFAULT " + def
+		}
+		defs = append(defs, d)
+	}
+
+	q := "CREATE TABLE IF NOT EXISTS " + tableName + " (" + strings.Join(defs, ", ") + ")"
+	var opts []string
+	if p.Options.WithoutRowid {
+		opts = append(opts, "WITHOUT ROWID")
+	}
+	if p.Options.Strict {
+		opts = append(opts, "STRICT")
+	}
+	if len(opts) > 0 {
+		q += " " + strings.Join(opts, ", ")
+	}
+	return q + ";"
+}
+
+// IndexDDLs returns the indexes on expires_on, which expiry cleanup and the
+// active session queries filter on, and on modified_on. They are named like
+// the indexes EnsureIndex creates.
+func (p DefaultSchemaProvider) IndexDDLs(tableName string) []string {
+	var ddls []string
+	for _, col := range []string{"expires_on", "modified_on"} {
+		ddls = append(ddls, "CREATE INDEX IF NOT EXISTS idx_"+tableName+"_"+col+" ON "+tableName+" ("+col+")")
+	}
+	return ddls
+}
+
+// SchemaVersion returns 0, leaving the version to MigrateSchema.
+func (p DefaultSchemaProvider) SchemaVersion() int {
+	return 0
+}
+
+// columnType maps a column type to one a STRICT table accepts.
+func (p DefaultSchemaProvider) columnType(typ string) string {
+	if !p.Options.Strict {
+		return typ
+	}
+	switch strings.ToUpper(typ) {
+	case "LONGBLOB", "TIMESTAMP":
+		return "TEXT"
+	}
+	return typ
+}
+
+// createSchema creates the sessions table with the store's schema provider
+// and records its schema version.
+func (m *Store) createSchema(ctx context.Context) error {
+	if _, err := m.db.Exec(m.schema.CreateTableDDL(m.table)); err != nil {
+		return err
+	}
+	want := m.schema.SchemaVersion()
+	if want == 0 {
+		return nil
+	}
+	version, err := m.GetSchemaVersion(ctx)
+	if err != nil {
+		return err
+	}
+	if version < want {
+		return m.SetSchemaVersion(ctx, want)
+	}
+	return nil
+}
+
+// createIndexes creates the indexes of the store's schema provider. It runs
+// after MigrateSchema, so the columns they cover exist in older tables too.
+func (m *Store) createIndexes() error {
+	for _, q := range m.schema.IndexDDLs(m.table) {
+		if _, err := m.db.Exec(q); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// insertTriggerDDL returns the DDL for an AFTER INSERT trigger named name
+// that runs body.
+func (m *Store) insertTriggerDDL(name, body string) string {
+	body = strings.TrimSpace(body)
+	if !strings.HasSuffix(body, ";") {
+		body += ";"
+	}
+	return "CREATE TRIGGER IF NOT EXISTS " + name + " AFTER INSERT ON " + m.table + " BEGIN " + body + " END"
+}
+
+// createInsertTriggers creates the triggers configured with
+// WithInsertTrigger, replacing any created by an earlier configuration.
+func (m *Store) createInsertTriggers(ctx context.Context) error {
+	for i, body := range m.triggers {
+		// preparing compiles the trigger without creating it
+		stmt, err := m.db.Prepare(m.insertTriggerDDL(m.table+"_insert_trigger_check", body))
+		if err != nil {
+			return fmt.Errorf("sqlitestore: invalid insert trigger: %v", err)
+		}
+		stmt.Close()
+
+		name := fmt.Sprintf("%s_insert_trigger_%d", m.table, i)
+		if _, err := m.db.ExecContext(ctx, "DROP TRIGGER IF EXISTS "+name); err != nil {
+			return err
+		}
+		if _, err := m.db.ExecContext(ctx, m.insertTriggerDDL(name, body)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// checkStrictSupport returns an error if the linked SQLite predates STRICT
+// tables.
+func (m *Store) checkStrictSupport(ctx context.Context) error {
+	var version string
+	if err := m.db.QueryRowContext(ctx, "SELECT sqlite_version()").Scan(&version); err != nil {
+		return err
+	}
+	parts := strings.SplitN(version, ".", 3)
+	if len(parts) < 2 {
+		return fmt.Errorf("sqlitestore: cannot parse SQLite version %q", version)
+	}
+	major, _ := strconv.Atoi(parts[0])
+	minor, _ := strconv.Atoi(parts[1])
+	if major < 3 || major == 3 && minor < 37 {
+		return fmt.Errorf("sqlitestore: STRICT tables require SQLite 3.37 or later, have %s", version)
+	}
+	return nil
+}
+
+// newTextID returns a random version 4 UUID for tables without row IDs.
+func newTextID() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	b[6] = b[6]&0x0f | 0x40
+	b[8] = b[8]&0x3f | 0x80
+	return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:]), nil
+}
+
+// parseTime converts a timestamp read from a column without a TIMESTAMP
+// declared type, as in STRICT tables, to a time.Time.
+func parseTime(col string, v interface{}) (time.Time, error) {
+	switch t := v.(type) {
+	case time.Time:
+		return t, nil
+	case int64:
+		return time.Unix(t, 0), nil
+	case []byte:
+		return parseTime(col, string(t))
+	case string:
+		s := strings.TrimSuffix(t, "Z")
+		for _, format := range sqlite3.SQLiteTimestampFormats {
+			if ts, err := time.ParseInLocation(format, s, time.UTC); err == nil {
+				return ts, nil
+			}
+		}
+		if s == "0" {
+			return time.Time{}, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("sqlitestore: cannot read %s value %v as a time", col, v)
+}
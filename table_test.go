@@ -0,0 +1,175 @@
+package sqlitestore
+
+import (
+	"bytes"
+	"context"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/gorilla/securecookie"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStrictTable(t *testing.T) {
+	db := newTestDB(t)
+	defer db.Close()
+	var version string
+	require.NoError(t, db.QueryRow("SELECT sqlite_version()").Scan(&version))
+
+	opts := WithTableOptions(TableOptions{
+		Strict:       true,
+		ExtraColumns: []ColumnDef{{Name: "visits", Type: "INTEGER", Default: "0"}},
+	})
+	store, err := New(db, [][]byte{securecookie.GenerateRandomKey(32)}, opts)
+	if err != nil {
+		// the bundled SQLite may predate STRICT tables
+		assert.Contains(t, err.Error(), "STRICT tables require SQLite 3.37")
+		t.Skipf("SQLite %s does not support STRICT tables", version)
+	}
+	defer store.Close()
+
+	sess := insertTestSession(t, store, map[interface{}]interface{}{"foo": "bar"})
+	assert.Equal(t, "bar", loadTestSession(t, store, sess.ID).Values["foo"])
+
+	_, err = store.db.ExecContext(context.Background(), "UPDATE sessions SET visits = 'many' WHERE id = ?", sess.ID)
+	assert.Error(t, err)
+}
+
+func TestWithoutRowidTable(t *testing.T) {
+	store := newTestStore(t, WithTableOptions(TableOptions{WithoutRowid: true}))
+
+	sess := insertTestSession(t, store, map[interface{}]interface{}{"foo": "bar"})
+	assert.Regexp(t, regexp.MustCompile(`^[0-9a-f]{8}-[0-9a-f]{4}-4[0-9a-f]{3}-[89ab][0-9a-f]{3}-[0-9a-f]{12}$`), sess.ID)
+	loaded := loadTestSession(t, store, sess.ID)
+	assert.Equal(t, "bar", loaded.Values["foo"])
+
+	loaded.Values["foo"] = "baz"
+	require.NoError(t, store.save(context.Background(), loaded))
+	assert.Equal(t, "baz", loadTestSession(t, store, sess.ID).Values["foo"])
+
+	var sql string
+	require.NoError(t, store.db.QueryRowContext(context.Background(),
+		"SELECT sql FROM sqlite_master WHERE name = 'sessions'").Scan(&sql))
+	assert.Contains(t, sql, "WITHOUT ROWID")
+}
+
+func TestWithoutRowidExportAndCleanup(t *testing.T) {
+	store := newTestStore(t, WithTableOptions(TableOptions{WithoutRowid: true}))
+	ctx := context.Background()
+	for i := 0; i < 5; i++ {
+		insertTestSession(t, store, map[interface{}]interface{}{"i": i})
+	}
+	for i := 0; i < 3; i++ {
+		insertTestSession(t, store, map[interface{}]interface{}{"expires_on": time.Now().Add(-time.Hour)})
+	}
+
+	var buf bytes.Buffer
+	n, err := store.StreamExportJSON(ctx, &buf, 3, nil)
+	require.NoError(t, err)
+	assert.Equal(t, int64(8), n)
+
+	dst := newTestStore(t, WithTableOptions(TableOptions{WithoutRowid: true}))
+	result, err := dst.Import(&buf)
+	require.NoError(t, err)
+	assert.Equal(t, int64(5), result.Imported)
+
+	deleted, err := store.DeleteExpiredInBatches(ctx, 2, time.Millisecond)
+	require.NoError(t, err)
+	assert.Equal(t, int64(3), deleted)
+	assert.Equal(t, 5, countRows(t, store))
+}
+
+func TestTableExtraColumns(t *testing.T) {
+	store := newTestStore(t, WithTableOptions(TableOptions{
+		ExtraColumns: []ColumnDef{{Name: "region", Type: "TEXT", Default: "'eu'"}},
+	}))
+	insertTestSession(t, store, nil)
+
+	var region string
+	require.NoError(t, store.db.QueryRowContext(context.Background(), "SELECT region FROM sessions").Scan(&region))
+	assert.Equal(t, "eu", region)
+}
+
+func TestInsertTrigger(t *testing.T) {
+	db := newTestDB(t)
+	_, err := db.Exec("CREATE TABLE session_counts (name TEXT PRIMARY KEY, n INTEGER NOT NULL)")
+	require.NoError(t, err)
+	store := newTestStoreDB(t, db, WithInsertTrigger(
+		"INSERT INTO session_counts (name, n) VALUES (NEW.name, 1) ON CONFLICT (name) DO UPDATE SET n = n + 1"))
+
+	insertTestSession(t, store, nil)
+	var n int
+	require.NoError(t, db.QueryRow("SELECT n FROM session_counts WHERE name = 'test'").Scan(&n))
+	assert.Equal(t, 1, n)
+
+	_, err = New(newTestDB(t), nil, WithInsertTrigger("INSERT INTO"))
+	assert.Error(t, err)
+}
+
+type appDataSchema struct{}
+
+func (appDataSchema) CreateTableDDL(table string) string {
+	return "CREATE TABLE IF NOT EXISTS " + table + " (id INTEGER PRIMARY KEY, session_data LONGBLOB, " +
+		"created_on TIMESTAMP DEFAULT 0, modified_on TIMESTAMP DEFAULT CURRENT_TIMESTAMP, " +
+		"expires_on TIMESTAMP DEFAULT 0, name TEXT DEFAULT '', frozen INTEGER DEFAULT 0, app_data TEXT)"
+}
+
+func (appDataSchema) IndexDDLs(table string) []string {
+	return []string{"CREATE INDEX IF NOT EXISTS idx_app_data ON " + table + " (app_data)"}
+}
+
+func (appDataSchema) SchemaVersion() int { return 5 }
+
+func TestSchemaProvider(t *testing.T) {
+	store := newTestStore(t, WithSchemaProvider(appDataSchema{}))
+	ctx := context.Background()
+
+	cols, err := store.DescribeSchema(ctx)
+	require.NoError(t, err)
+	var names []string
+	for _, col := range cols {
+		names = append(names, col.Name)
+	}
+	assert.Contains(t, names, "app_data")
+
+	indexes, err := store.ListIndexes(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, []IndexInfo{{Name: "idx_app_data", Column: "app_data"}}, indexes)
+	version, err := store.GetSchemaVersion(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, 5, version)
+
+	id := insertTestSession(t, store, map[interface{}]interface{}{"n": 1}).ID
+	assert.Equal(t, 1, loadTestSession(t, store, id).Values["n"])
+}
+
+func TestTableName(t *testing.T) {
+	db := newTestDB(t)
+	store := newTestStoreDB(t, db, WithTableName("app_sessions"))
+	ctx := context.Background()
+
+	id := insertTestSession(t, store, map[interface{}]interface{}{"n": 1}).ID
+	insertTestSession(t, store, map[interface{}]interface{}{"expires_on": time.Now().Add(-time.Hour)})
+	assert.Equal(t, 1, loadTestSession(t, store, id).Values["n"])
+	n, err := store.GarbageCollect(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), n)
+
+	var tables []string
+	rows, err := db.QueryContext(ctx, "SELECT name FROM sqlite_master WHERE type = 'table'")
+	require.NoError(t, err)
+	for rows.Next() {
+		var name string
+		require.NoError(t, rows.Scan(&name))
+		tables = append(tables, name)
+	}
+	require.NoError(t, rows.Err())
+	assert.Equal(t, []string{"app_sessions"}, tables)
+
+	for _, name := range []string{"", "sessions; DROP TABLE users", "my-sessions"} {
+		_, err := New(newTestDB(t), nil, WithTableName(name))
+		assert.Error(t, err, name)
+	}
+}
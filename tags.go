@@ -0,0 +1,170 @@
+package sqlitestore
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"strings"
+)
+
+var tagsColumn = columnDef{"tags", "TEXT", "''"}
+
+// ErrTaggingDisabled is returned by the tag methods on a store created
+// without WithSessionTagging.
+var ErrTaggingDisabled = errors.New("sqlitestore: session tagging is not enabled")
+
+func validateTags(tags []string) error {
+	for _, tag := range tags {
+		if tag == "" || strings.Contains(tag, ",") {
+			return errors.New("sqlitestore: tags must be non-empty and must not contain commas")
+		}
+	}
+	return nil
+}
+
+func splitTags(s string) []string {
+	if s == "" {
+		return nil
+	}
+	return strings.Split(s, ",")
+}
+
+// tagPattern returns a LIKE pattern matching tag as a whole entry of the
+// comma wrapped tags column.
+func tagPattern(tag string) string {
+	r := strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`)
+	return "%," + r.Replace(tag) + ",%"
+}
+
+// tagMatch is the WHERE clause matching rows carrying the tag bound to it.
+const tagMatch = "',' || tags || ',' LIKE ? ESCAPE '\\'"
+
+func (m *Store) createTagIndex(ctx context.Context) error {
+	_, err := m.db.ExecContext(ctx, "CREATE INDEX IF NOT EXISTS idx_"+m.table+"_tags ON "+m.table+" (tags)")
+	return err
+}
+
+// TagSession adds tags to a session. Tags already present are kept once.
+func (m *Store) TagSession(ctx context.Context, sessionID string, tags ...string) error {
+	if err := validateTags(tags); err != nil {
+		return err
+	}
+	return m.updateTags(ctx, sessionID, func(current []string) []string {
+		for _, tag := range tags {
+			if !containsString(current, tag) {
+				current = append(current, tag)
+			}
+		}
+		return current
+	})
+}
+
+// UntagSession removes tags from a session.
+func (m *Store) UntagSession(ctx context.Context, sessionID string, tags ...string) error {
+	return m.updateTags(ctx, sessionID, func(current []string) []string {
+		kept := current[:0]
+		for _, tag := range current {
+			if !containsString(tags, tag) {
+				kept = append(kept, tag)
+			}
+		}
+		return kept
+	})
+}
+
+func (m *Store) updateTags(ctx context.Context, sessionID string, update func([]string) []string) (err error) {
+	if !m.tagging {
+		return ErrTaggingDisabled
+	}
+	if m.readOnly {
+		return ErrReadOnly
+	}
+	if err := m.lock(ctx, sessionID); err != nil {
+		return err
+	}
+	defer m.locks.Unlock(sessionID)
+	// write any pending coalesced update first, so it cannot land after the
+	// tags change
+	if err := m.flushWrite(ctx, sessionID); err != nil {
+		return err
+	}
+
+	tx, err := m.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err != nil {
+			tx.Rollback()
+		}
+	}()
+
+	var current string
+	err = tx.QueryRowContext(ctx, "SELECT tags FROM "+m.table+" WHERE id = ?", m.rowID(sessionID)).Scan(&current)
+	if err == sql.ErrNoRows {
+		return ErrSessionNotFound
+	}
+	if err != nil {
+		return err
+	}
+	tags := strings.Join(update(splitTags(current)), ",")
+	if _, err = tx.ExecContext(ctx, "UPDATE "+m.table+" SET tags = ? WHERE id = ?", tags, m.rowID(sessionID)); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// FindByTag returns the IDs of up to limit sessions carrying tag, skipping
+// the first offset, in row ID order. CountByTag gives the total for paging.
+func (m *Store) FindByTag(ctx context.Context, tag string, offset, limit int) ([]string, error) {
+	if !m.tagging {
+		return nil, ErrTaggingDisabled
+	}
+	rows, err := m.db.QueryContext(ctx, "SELECT id FROM "+m.table+" WHERE "+tagMatch+" ORDER BY id LIMIT ? OFFSET ?",
+		tagPattern(tag), limit, offset)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var ids []string
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		ids = append(ids, m.prefix+id)
+	}
+	return ids, rows.Err()
+}
+
+// CountByTag returns the number of sessions carrying tag.
+func (m *Store) CountByTag(ctx context.Context, tag string) (int64, error) {
+	if !m.tagging {
+		return 0, ErrTaggingDisabled
+	}
+	var n int64
+	if err := m.db.QueryRowContext(ctx, "SELECT COUNT(*) FROM "+m.table+" WHERE "+tagMatch, tagPattern(tag)).Scan(&n); err != nil {
+		return 0, err
+	}
+	return n, nil
+}
+
+// DeleteByTag deletes every session carrying tag and returns the number of
+// sessions deleted. The OnDelete hook is called with the ID of each deleted
+// session.
+func (m *Store) DeleteByTag(ctx context.Context, tag string) (int64, error) {
+	if !m.tagging {
+		return 0, ErrTaggingDisabled
+	}
+	return m.deleteWhere(ctx, tagMatch, tagPattern(tag))
+}
+
+func containsString(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,129 @@
+package sqlitestore
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSessionTagging(t *testing.T) {
+	store := newTestStore(t, WithSessionTagging())
+	ctx := context.Background()
+
+	a := insertTestSession(t, store, nil)
+	b := insertTestSession(t, store, nil)
+	c := insertTestSession(t, store, nil)
+	require.NoError(t, store.TagSession(ctx, a.ID, "beta", "admin"))
+	require.NoError(t, store.TagSession(ctx, b.ID, "beta", "beta"))
+	require.NoError(t, store.TagSession(ctx, c.ID, "betamax", "a_b"))
+	assert.Equal(t, ErrSessionNotFound, store.TagSession(ctx, "999", "beta"))
+	assert.Error(t, store.TagSession(ctx, a.ID, "bad,tag"))
+
+	ids, err := store.FindByTag(ctx, "beta", 0, 10)
+	require.NoError(t, err)
+	assert.Equal(t, []string{a.ID, b.ID}, ids)
+
+	// LIKE wildcards in tags match literally
+	ids, err = store.FindByTag(ctx, "a%b", 0, 10)
+	require.NoError(t, err)
+	assert.Empty(t, ids)
+	ids, err = store.FindByTag(ctx, "a_b", 0, 10)
+	require.NoError(t, err)
+	assert.Equal(t, []string{c.ID}, ids)
+
+	require.NoError(t, store.UntagSession(ctx, a.ID, "beta"))
+	ids, err = store.FindByTag(ctx, "beta", 0, 10)
+	require.NoError(t, err)
+	assert.Equal(t, []string{b.ID}, ids)
+
+	n, err := store.DeleteByTag(ctx, "admin")
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), n)
+	assert.Equal(t, 2, countRows(t, store))
+
+	indexes, err := store.ListIndexes(ctx)
+	require.NoError(t, err)
+	assert.Contains(t, indexes, IndexInfo{Name: "idx_sessions_tags", Column: "tags"})
+}
+
+func TestSessionTaggingDisabled(t *testing.T) {
+	store := newTestStore(t)
+	_, err := store.FindByTag(context.Background(), "beta", 0, 10)
+	assert.Equal(t, ErrTaggingDisabled, err)
+}
+
+func TestDeleteByTag(t *testing.T) {
+	var deleted []string
+	store := newTestStore(t, WithSessionTagging(), WithOnDelete(func(id string) {
+		deleted = append(deleted, id)
+	}))
+	ctx := context.Background()
+
+	var beta []string
+	for i := 0; i < 10; i++ {
+		sess := insertTestSession(t, store, nil)
+		require.NoError(t, store.TagSession(ctx, sess.ID, "beta"))
+		beta = append(beta, sess.ID)
+	}
+	for i := 0; i < 5; i++ {
+		sess := insertTestSession(t, store, nil)
+		require.NoError(t, store.TagSession(ctx, sess.ID, "control", "betamax"))
+	}
+
+	n, err := store.DeleteByTag(ctx, "beta")
+	require.NoError(t, err)
+	assert.Equal(t, int64(10), n)
+	assert.Equal(t, 5, countRows(t, store))
+	assert.ElementsMatch(t, beta, deleted)
+}
+
+func TestFindByTagPagination(t *testing.T) {
+	store := newTestStore(t, WithSessionTagging())
+	ctx := context.Background()
+
+	var beta []string
+	for i := 0; i < 5; i++ {
+		sess := insertTestSession(t, store, nil)
+		require.NoError(t, store.TagSession(ctx, sess.ID, "beta", "mobile"))
+		beta = append(beta, sess.ID)
+		other := insertTestSession(t, store, nil)
+		require.NoError(t, store.TagSession(ctx, other.ID, "betamax", "alpha-beta", "mobile"))
+	}
+
+	n, err := store.CountByTag(ctx, "beta")
+	require.NoError(t, err)
+	assert.Equal(t, int64(5), n)
+	n, err = store.CountByTag(ctx, "mobile")
+	require.NoError(t, err)
+	assert.Equal(t, int64(10), n)
+
+	page1, err := store.FindByTag(ctx, "beta", 0, 3)
+	require.NoError(t, err)
+	page2, err := store.FindByTag(ctx, "beta", 3, 3)
+	require.NoError(t, err)
+	assert.Equal(t, beta, append(page1, page2...))
+
+	_, err = newTestStore(t).CountByTag(ctx, "beta")
+	assert.Equal(t, ErrTaggingDisabled, err)
+}
+
+func TestSessionTaggingPendingWrite(t *testing.T) {
+	store := newTestStore(t, WithWriteCoalescing(time.Hour), WithSessionTagging())
+	ctx := context.Background()
+
+	sess := insertTestSession(t, store, map[interface{}]interface{}{"v": "a"})
+	sess.IsNew = false
+	sess.Values["v"] = "b"
+	require.NoError(t, store.save(ctx, sess))
+
+	require.NoError(t, store.TagSession(ctx, sess.ID, "beta"))
+	_, pending := store.pending.Load(sess.ID)
+	assert.False(t, pending)
+	assert.Equal(t, "b", loadTestSession(t, store, sess.ID).Values["v"])
+	ids, err := store.FindByTag(ctx, "beta", 0, 10)
+	require.NoError(t, err)
+	assert.Equal(t, []string{sess.ID}, ids)
+}
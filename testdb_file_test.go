@@ -0,0 +1,15 @@
+//go:build !memory
+// +build !memory
+
+package sqlitestore
+
+import (
+	"database/sql"
+	"testing"
+)
+
+// newTestDB opens a database in a temporary file. Build with the memory tag
+// to run the tests against in-memory databases instead.
+func newTestDB(t testing.TB) *sql.DB {
+	return newFileTestDB(t)
+}
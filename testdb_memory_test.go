@@ -0,0 +1,20 @@
+//go:build memory
+// +build memory
+
+package sqlitestore
+
+import (
+	"database/sql"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// newTestDB opens an in-memory database on a single connection, as
+// NewMemoryStore does.
+func newTestDB(t testing.TB) *sql.DB {
+	db, err := sql.Open("sqlite3", ":memory:")
+	require.NoError(t, err)
+	db.SetMaxOpenConns(1)
+	return db
+}
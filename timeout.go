@@ -0,0 +1,50 @@
+package sqlitestore
+
+import (
+	"context"
+	"time"
+)
+
+// opKind selects which per-operation timeout applies to a statement.
+type opKind int
+
+const (
+	opInsert opKind = iota
+	opSelect
+	opDelete
+)
+
+// opTimeouts holds the statement timeouts set by WithStatementTimeout and
+// the per-operation overrides.
+type opTimeouts struct {
+	all    time.Duration
+	insert time.Duration
+	sel    time.Duration
+	delete time.Duration
+}
+
+func (t opTimeouts) forOp(op opKind) time.Duration {
+	var d time.Duration
+	switch op {
+	case opInsert:
+		d = t.insert
+	case opSelect:
+		d = t.sel
+	case opDelete:
+		d = t.delete
+	}
+	if d > 0 {
+		return d
+	}
+	return t.all
+}
+
+// opContext returns ctx bounded by the timeout configured for op. An earlier
+// deadline already set on ctx is kept.
+func (m *Store) opContext(ctx context.Context, op opKind) (context.Context, context.CancelFunc) {
+	d := m.timeouts.forOp(op)
+	if d <= 0 {
+		return ctx, func() {}
+	}
+	return context.WithTimeout(ctx, d)
+}
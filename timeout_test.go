@@ -0,0 +1,43 @@
+package sqlitestore
+
+import (
+	"context"
+	"database/sql"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// slowDB is a DB whose ExecContext blocks until its context ends.
+type slowDB struct {
+	*sql.DB
+}
+
+func (db slowDB) ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error) {
+	<-ctx.Done()
+	return nil, ctx.Err()
+}
+
+func TestStatementTimeout(t *testing.T) {
+	for name, opt := range map[string]StoreOption{
+		"all":    WithStatementTimeout(50 * time.Millisecond),
+		"delete": WithDeleteTimeout(50 * time.Millisecond),
+	} {
+		t.Run(name, func(t *testing.T) {
+			store := newTestStoreDB(t, slowDB{newTestDB(t)}, opt)
+			start := time.Now()
+			_, err := store.GarbageCollect(context.Background())
+			assert.Equal(t, context.DeadlineExceeded, err)
+			assert.True(t, time.Since(start) < time.Second)
+		})
+	}
+}
+
+func TestStatementTimeoutKeepsEarlierDeadline(t *testing.T) {
+	store := newTestStoreDB(t, slowDB{newTestDB(t)}, WithStatementTimeout(time.Hour))
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	_, err := store.GarbageCollect(ctx)
+	assert.Equal(t, context.DeadlineExceeded, err)
+}
@@ -0,0 +1,124 @@
+package sqlitestore
+
+import (
+	"fmt"
+	"time"
+)
+
+// ColumnTransformer converts column values between their Go representation
+// and the representation stored in the database. Transform is called for each
+// column value before it is written and Untransform for each value read back
+// by load. Values a transformer does not handle should be returned unchanged.
+type ColumnTransformer interface {
+	Transform(colName string, value interface{}) interface{}
+	Untransform(colName string, raw interface{}) interface{}
+}
+
+// UnixTimestampTransformer stores time.Time column values as int64 Unix
+// timestamps with second precision.
+type UnixTimestampTransformer struct{}
+
+// Transform converts a time.Time to its Unix timestamp.
+func (UnixTimestampTransformer) Transform(colName string, value interface{}) interface{} {
+	if t, ok := value.(time.Time); ok {
+		return t.Unix()
+	}
+	return value
+}
+
+// Untransform converts an int64 Unix timestamp back to a time.Time.
+func (UnixTimestampTransformer) Untransform(colName string, raw interface{}) interface{} {
+	switch v := raw.(type) {
+	case int64:
+		return time.Unix(v, 0)
+	case time.Time:
+		// the driver already parses integers in TIMESTAMP columns
+		return v
+	}
+	return raw
+}
+
+// insertColumns returns the columns written by the insert statement, in
+// argument order.
+func (m *Store) insertColumns() []string {
+	return append([]string{"session_data", "created_on", "modified_on", "expires_on"}, m.writeColumns()...)
+}
+
+// updateColumns returns the columns written by the update statement, in
+// argument order. The trailing id argument is not included.
+func (m *Store) updateColumns() []string {
+	return append([]string{"session_data", "created_on", "expires_on"}, m.writeColumns()...)
+}
+
+// transform returns value as it should be written to col.
+func (m *Store) transform(col string, value interface{}) interface{} {
+	if m.transformer == nil {
+		return value
+	}
+	return m.transformer.Transform(col, value)
+}
+
+// transformArgs returns a copy of args with each value matched to cols
+// transformed. Arguments beyond cols, such as the row id, are left as is.
+func (m *Store) transformArgs(cols []string, args []interface{}) []interface{} {
+	if m.transformer == nil {
+		return args
+	}
+	out := make([]interface{}, len(args))
+	copy(out, args)
+	for i, col := range cols {
+		if i < len(out) {
+			out[i] = m.transformer.Transform(col, out[i])
+		}
+	}
+	return out
+}
+
+// untransform returns raw, read from col, as the transformer converts it.
+func (m *Store) untransform(col string, raw interface{}) interface{} {
+	if m.transformer == nil {
+		return raw
+	}
+	return m.transformer.Untransform(col, raw)
+}
+
+// rowScanner is implemented by *sql.Row and *sql.Rows.
+type rowScanner interface {
+	Scan(dest ...interface{}) error
+}
+
+// scanSession scans a row returned by the select statement into sess,
+// untransforming each column when a transformer is set.
+func (m *Store) scanSession(row rowScanner, sess *sessionRow) error {
+	if m.transformer == nil && !m.tableOpts.Strict {
+		return row.Scan(&sess.id, &sess.data, &sess.createdOn, &sess.modifiedOn, &sess.expiresOn)
+	}
+	var data, createdOn, modifiedOn, expiresOn interface{}
+	if err := row.Scan(&sess.id, &data, &createdOn, &modifiedOn, &expiresOn); err != nil {
+		return err
+	}
+	switch v := m.untransform("session_data", data).(type) {
+	case string:
+		sess.data = v
+	case []byte:
+		sess.data = string(v)
+	default:
+		return fmt.Errorf("sqlitestore: session_data untransformed to %T, want string", v)
+	}
+	for _, c := range []struct {
+		name string
+		raw  interface{}
+		dst  *time.Time
+	}{
+		{"created_on", createdOn, &sess.createdOn},
+		{"modified_on", modifiedOn, &sess.modifiedOn},
+		{"expires_on", expiresOn, &sess.expiresOn},
+	} {
+		t, err := parseTime(c.name, m.untransform(c.name, c.raw))
+		if err != nil {
+			return err
+		}
+		*c.dst = t
+	}
+	return nil
+}
@@ -0,0 +1,38 @@
+package sqlitestore
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestUnixTimestampTransformer(t *testing.T) {
+	store := newTestStore(t, WithColumnTransformer(UnixTimestampTransformer{}))
+	defer store.Close()
+
+	before := time.Now().Add(-time.Second)
+	sess := insertTestSession(t, store, map[interface{}]interface{}{"foo": "bar"})
+
+	var typ string
+	var expiresOn int64
+	require.NoError(t, store.db.QueryRowContext(context.Background(),
+		"SELECT typeof(expires_on), expires_on + 0 FROM sessions WHERE id = ?", store.rowID(sess.ID)).Scan(&typ, &expiresOn))
+	assert.Equal(t, "integer", typ)
+	assert.InDelta(t, time.Now().Add(time.Hour).Unix(), expiresOn, 2)
+
+	loaded := loadTestSession(t, store, sess.ID)
+	assert.Equal(t, "bar", loaded.Values["foo"])
+	assert.True(t, loaded.Values["created_on"].(time.Time).After(before))
+	assert.Equal(t, expiresOn, loaded.Values["expires_on"].(time.Time).Unix())
+
+	loaded.Values["foo"] = "baz"
+	require.NoError(t, store.save(context.Background(), loaded))
+	assert.Equal(t, "baz", loadTestSession(t, store, sess.ID).Values["foo"])
+
+	n, err := store.GarbageCollect(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, int64(0), n)
+}
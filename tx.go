@@ -0,0 +1,192 @@
+package sqlitestore
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/gorilla/securecookie"
+	"github.com/gorilla/sessions"
+)
+
+// TxSave saves all sessions in batch in a single transaction. If any
+// session fails to encode or write, nothing is persisted and no cookies are
+// set; otherwise the cookies for every session are written after commit.
+func (m *Store) TxSave(ctx context.Context, r *http.Request, w http.ResponseWriter, batch ...*sessions.Session) (err error) {
+	if m.readOnly {
+		return ErrReadOnly
+	}
+	if err := m.checkConn(); err != nil {
+		return err
+	}
+	for _, session := range batch {
+		if session.Options == nil {
+			session.Options = m.newOptions(session.Name())
+		}
+	}
+	tx, err := m.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+
+	// inserts assign IDs that must not survive a rollback
+	ids := make([]string, len(batch))
+	for i, session := range batch {
+		ids[i] = session.ID
+	}
+	defer func() {
+		if err != nil {
+			tx.Rollback()
+			for i, session := range batch {
+				session.ID = ids[i]
+			}
+		}
+	}()
+
+	create := tx.StmtContext(ctx, m.create)
+	update := tx.StmtContext(ctx, m.update)
+	del := tx.StmtContext(ctx, m.delete)
+	for _, session := range batch {
+		switch {
+		case session.Options.MaxAge <= 0:
+			_, err = del.ExecContext(ctx, m.rowID(session.ID))
+		case session.ID == "" || session.IsNew:
+			err = m.insertStmt(ctx, create, session)
+		default:
+			err = m.saveStmt(ctx, update, session)
+		}
+		if err != nil {
+			return err
+		}
+		if m.chunked && session.Options.MaxAge > 0 {
+			if err = m.writeChunks(ctx, tx, session); err != nil {
+				return err
+			}
+		}
+	}
+
+	cookies := make([]*http.Cookie, len(batch))
+	for i, session := range batch {
+		if session.Options.MaxAge <= 0 {
+			options := *session.Options
+			options.MaxAge = -1
+			cookies[i] = sessions.NewCookie(session.Name(), "", &options)
+			continue
+		}
+		var encoded string
+		if encoded, err = securecookie.EncodeMulti(session.Name(), session.ID, m.codecs()...); err != nil {
+			return err
+		}
+		cookies[i] = sessions.NewCookie(session.Name(), encoded, session.Options)
+	}
+	// a pending coalesced write would overwrite the batch, or resurrect a
+	// deleted row, once flushed
+	for _, session := range batch {
+		m.cancelWrite(session.ID)
+	}
+	if err = tx.Commit(); err != nil {
+		return err
+	}
+	for i, session := range batch {
+		switch {
+		case session.Options.MaxAge <= 0:
+			m.cache.remove(session.ID)
+			m.checksums.Delete(session.ID)
+			if m.hasValueIndex() {
+				if err := m.InvalidateValueIndex(session.ID); err != nil {
+					m.handleError(err)
+				}
+			}
+			m.runOnDelete(session.ID)
+		case ids[i] == "" || session.IsNew:
+			m.runOnCreate(session)
+		}
+	}
+	for _, cookie := range cookies {
+		http.SetCookie(w, cookie)
+	}
+	return nil
+}
+
+// SaveTx is TxSave using the context of r.
+func (m *Store) SaveTx(r *http.Request, w http.ResponseWriter, batch ...*sessions.Session) error {
+	return m.TxSave(r.Context(), r, w, batch...)
+}
+
+// Rotate moves session to a new ID, keeping its values and expiry, and sets
+// the cookie for the new ID. Call it after a user logs in or gains privileges
+// so a session ID planted before then can't be used afterwards. The new row is
+// inserted and the old one deleted in a single transaction.
+func (m *Store) Rotate(r *http.Request, w http.ResponseWriter, session *sessions.Session) (err error) {
+	if session.ID == "" {
+		return ErrSessionNotSaved
+	}
+	if m.readOnly {
+		return ErrReadOnly
+	}
+	if err := m.checkConn(); err != nil {
+		return err
+	}
+	if session.Options == nil {
+		session.Options = m.newOptions(session.Name())
+	}
+	ctx := context.Background()
+	oldID := session.ID
+	// the session values supersede any pending write for the old row
+	m.cancelWrite(oldID)
+
+	tx, err := m.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err != nil {
+			tx.Rollback()
+			session.ID = oldID
+		}
+	}()
+
+	if err = m.insertStmt(ctx, tx.StmtContext(ctx, m.create), session); err != nil {
+		return err
+	}
+	if session.ID == oldID {
+		// rowids are only reused once the old row is gone
+		err = ErrSessionNotFound
+		return err
+	}
+	if m.chunked {
+		// the new row has no values yet, write all of them
+		delete(session.Values, chunkSnapshot{})
+		if err = m.writeChunks(ctx, tx, session); err != nil {
+			return err
+		}
+	}
+	res, err := tx.StmtContext(ctx, m.delete).ExecContext(ctx, m.rowID(oldID))
+	if err != nil {
+		return err
+	}
+	var n int64
+	if n, err = res.RowsAffected(); err != nil {
+		return err
+	}
+	if n == 0 {
+		err = ErrSessionNotFound
+		return err
+	}
+	var encoded string
+	if encoded, err = securecookie.EncodeMulti(session.Name(), session.ID, m.codecs()...); err != nil {
+		return err
+	}
+	if err = tx.Commit(); err != nil {
+		return err
+	}
+
+	m.cache.remove(oldID)
+	if m.hasValueIndex() {
+		if err := m.InvalidateValueIndex(oldID); err != nil {
+			m.handleError(err)
+		}
+	}
+	m.runOnDelete(oldID)
+	http.SetCookie(w, sessions.NewCookie(session.Name(), encoded, session.Options))
+	return nil
+}
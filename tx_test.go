@@ -0,0 +1,164 @@
+package sqlitestore
+
+import (
+	"context"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gorilla/sessions"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTxSave(t *testing.T) {
+	store := newTestStore(t)
+	ctx := context.Background()
+	r := httptest.NewRequest("GET", "/", nil)
+
+	user, err := store.New(r, "user")
+	require.NoError(t, err)
+	user.Values["name"] = "alice"
+	cart, err := store.New(r, "cart")
+	require.NoError(t, err)
+	cart.Values["items"] = 3
+
+	w := httptest.NewRecorder()
+	require.NoError(t, store.TxSave(ctx, r, w, user, cart))
+	assert.Len(t, w.Result().Cookies(), 2)
+	assert.Equal(t, 2, countRows(t, store))
+}
+
+func TestTxSaveNilOptions(t *testing.T) {
+	store := newTestStore(t)
+	r := httptest.NewRequest("GET", "/", nil)
+
+	sess := sessions.NewSession(store, "test")
+	sess.Options = nil
+	sess.Values["name"] = "alice"
+	w := httptest.NewRecorder()
+	require.NoError(t, store.TxSave(context.Background(), r, w, sess))
+	assert.Len(t, w.Result().Cookies(), 1)
+	assert.Equal(t, "alice", loadTestSession(t, store, sess.ID).Values["name"])
+}
+
+func TestSaveTx(t *testing.T) {
+	store := newTestStore(t)
+	r := httptest.NewRequest("GET", "/", nil)
+
+	user, err := store.New(r, "user")
+	require.NoError(t, err)
+	cart, err := store.New(r, "cart")
+	require.NoError(t, err)
+	cart.Values["ch"] = make(chan int)
+
+	w := httptest.NewRecorder()
+	assert.Error(t, store.SaveTx(r, w, user, cart))
+	assert.Empty(t, w.Result().Cookies())
+	assert.Equal(t, 0, countRows(t, store))
+
+	delete(cart.Values, "ch")
+	w = httptest.NewRecorder()
+	require.NoError(t, store.SaveTx(r, w, user, cart))
+	assert.Len(t, w.Result().Cookies(), 2)
+	assert.Equal(t, 2, countRows(t, store))
+}
+
+func TestTxSaveRollback(t *testing.T) {
+	store := newTestStore(t)
+	ctx := context.Background()
+	r := httptest.NewRequest("GET", "/", nil)
+
+	existing := insertTestSession(t, store, map[interface{}]interface{}{"name": "alice"})
+	existing.IsNew = false
+	existing.Values["name"] = "bob"
+	fresh, err := store.New(r, "fresh")
+	require.NoError(t, err)
+	broken, err := store.New(r, "broken")
+	require.NoError(t, err)
+	// channels cannot be gob encoded
+	broken.Values["ch"] = make(chan int)
+
+	w := httptest.NewRecorder()
+	assert.Error(t, store.TxSave(ctx, r, w, existing, fresh, broken))
+	assert.Empty(t, w.Result().Cookies())
+	assert.Empty(t, fresh.ID)
+	assert.Equal(t, 1, countRows(t, store))
+
+	loaded := sessions.NewSession(store, "test")
+	loaded.ID = existing.ID
+	require.NoError(t, store.load(ctx, loaded))
+	assert.Equal(t, "alice", loaded.Values["name"])
+}
+
+func TestTxSaveDelete(t *testing.T) {
+	var deleted []string
+	store := newTestStore(t, WithWriteCoalescing(time.Hour), WithLRUCache(10),
+		WithOnDelete(func(id string) { deleted = append(deleted, id) }))
+	ctx := context.Background()
+	r := httptest.NewRequest("GET", "/", nil)
+
+	sess := insertTestSession(t, store, map[interface{}]interface{}{"name": "alice"})
+	sess.IsNew = false
+	sess.Values["name"] = "bob"
+	require.NoError(t, store.save(ctx, sess))
+	_, pending := store.pending.Load(sess.ID)
+	require.True(t, pending)
+
+	sess.Options.MaxAge = -1
+	require.NoError(t, store.TxSave(ctx, r, httptest.NewRecorder(), sess))
+	_, pending = store.pending.Load(sess.ID)
+	assert.False(t, pending)
+	assert.Equal(t, []string{sess.ID}, deleted)
+	assert.Zero(t, store.CacheStats().Size)
+
+	require.NoError(t, store.flushWrites(ctx))
+	assert.Equal(t, 0, countRows(t, store))
+}
+
+func TestRotate(t *testing.T) {
+	store := newTestStore(t)
+	r := httptest.NewRequest("GET", "/", nil)
+
+	unsaved, err := store.New(r, "test")
+	require.NoError(t, err)
+	assert.Equal(t, ErrSessionNotSaved, store.Rotate(r, httptest.NewRecorder(), unsaved))
+
+	sess := insertTestSession(t, store, map[interface{}]interface{}{"name": "alice"})
+	oldID := sess.ID
+	w := httptest.NewRecorder()
+	require.NoError(t, store.Rotate(r, w, sess))
+	assert.NotEqual(t, oldID, sess.ID)
+	require.Len(t, w.Result().Cookies(), 1)
+	assert.Equal(t, 1, countRows(t, store))
+
+	loaded := sessions.NewSession(store, "test")
+	loaded.ID = oldID
+	assert.Error(t, store.load(context.Background(), loaded))
+	loaded = loadTestSession(t, store, sess.ID)
+	assert.Equal(t, "alice", loaded.Values["name"])
+
+	// rotating a session deleted in the meantime leaves nothing behind
+	gone := insertTestSession(t, store, nil)
+	goneID := gone.ID
+	require.NoError(t, store.Delete(r, httptest.NewRecorder(), &sessions.Session{ID: goneID, Options: gone.Options, Values: map[interface{}]interface{}{}}))
+	assert.Equal(t, ErrSessionNotFound, store.Rotate(r, httptest.NewRecorder(), gone))
+	assert.Equal(t, goneID, gone.ID)
+	assert.Equal(t, 1, countRows(t, store))
+}
+
+func TestTxSaveCancelsPendingWrite(t *testing.T) {
+	store := newTestStore(t, WithWriteCoalescing(time.Hour))
+	ctx := context.Background()
+	r := httptest.NewRequest("GET", "/", nil)
+
+	sess := insertTestSession(t, store, map[interface{}]interface{}{"v": "a"})
+	sess.IsNew = false
+	sess.Values["v"] = "b"
+	require.NoError(t, store.save(ctx, sess))
+
+	sess.Values["v"] = "c"
+	require.NoError(t, store.TxSave(ctx, r, httptest.NewRecorder(), sess))
+	require.NoError(t, store.flushWrites(ctx))
+	assert.Equal(t, "c", loadTestSession(t, store, sess.ID).Values["v"])
+}
@@ -0,0 +1,152 @@
+package sqlitestore
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+
+	"github.com/gorilla/sessions"
+)
+
+// UserIDKey is the session value key SetUserID stores the user ID under.
+// Stores created with WithUserIDColumn copy it into the user_id column.
+const UserIDKey = "user_id"
+
+// anonymizedUserID replaces the user ID of anonymized sessions.
+const anonymizedUserID = "[deleted]"
+
+const anonymizeBatchSize = 50
+
+// ErrNoUserIDColumn is returned by per-user operations on a store created
+// without WithUserIDColumn.
+var ErrNoUserIDColumn = errors.New("sqlitestore: store has no user_id column")
+
+// SetUserID associates session with userID. The association is written
+// when the session is next saved.
+func SetUserID(session *sessions.Session, userID string) {
+	session.Values[UserIDKey] = userID
+}
+
+func sessionUserID(session *sessions.Session) sql.NullString {
+	id, ok := session.Values[UserIDKey].(string)
+	return sql.NullString{String: id, Valid: ok}
+}
+
+// DeleteByUserID deletes every session belonging to userID, logging the user
+// out everywhere, and returns the number of sessions deleted.
+func (m *Store) DeleteByUserID(userID string) (int64, error) {
+	if !m.userIDCol {
+		return 0, ErrNoUserIDColumn
+	}
+	return m.deleteWhere(context.Background(), "user_id = ?", userID)
+}
+
+// AnonymizeSessions strips the keys registered with WithPIIKeys from every
+// session belonging to userID and detaches the sessions from the user, while
+// keeping them valid. It returns the number of sessions anonymized.
+func (m *Store) AnonymizeSessions(ctx context.Context, userID string) (int64, error) {
+	if !m.userIDCol {
+		return 0, ErrNoUserIDColumn
+	}
+	if userID == anonymizedUserID {
+		return 0, errors.New("sqlitestore: cannot anonymize already anonymized sessions")
+	}
+	if m.readOnly {
+		return 0, ErrReadOnly
+	}
+	// write pending coalesced updates first so anonymizing starts from them
+	if err := m.flushWrites(ctx); err != nil {
+		return 0, err
+	}
+
+	var total int64
+	for {
+		if err := ctx.Err(); err != nil {
+			return total, err
+		}
+		n, err := m.anonymizeBatch(ctx, userID)
+		total += n
+		if err != nil || n == 0 {
+			return total, err
+		}
+	}
+}
+
+func (m *Store) anonymizeBatch(ctx context.Context, userID string) (n int64, err error) {
+	tx, err := m.db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, err
+	}
+	defer func() {
+		if err != nil {
+			tx.Rollback()
+			n = 0
+		}
+	}()
+
+	rows, err := tx.QueryContext(ctx, "SELECT id, name, session_data FROM "+m.table+" WHERE user_id = ? LIMIT ?", userID, anonymizeBatchSize)
+	if err != nil {
+		return 0, err
+	}
+	var batch []sessionRow
+	for rows.Next() {
+		var row sessionRow
+		if err = rows.Scan(&row.id, &row.name, &row.data); err != nil {
+			rows.Close()
+			return 0, err
+		}
+		batch = append(batch, row)
+	}
+	rows.Close()
+	if err = rows.Err(); err != nil {
+		return 0, err
+	}
+
+	// with chunked storage the values that matter live in the values table
+	var chunkKeys [][]byte
+	if m.chunked {
+		for _, key := range append(append([]interface{}(nil), m.piiKeys...), UserIDKey) {
+			var b []byte
+			if b, err = gobBytes(key); err != nil {
+				return 0, err
+			}
+			chunkKeys = append(chunkKeys, b)
+		}
+	}
+
+	updQ := "UPDATE " + m.table + " SET session_data = ?, user_id = ? WHERE id = ?"
+	delQ := "DELETE FROM " + m.valuesTable() + " WHERE session_id = ? AND key = ?"
+	for _, row := range batch {
+		session := sessions.NewSession(m, row.name)
+		if err = m.decodeValues(session, row.data); err != nil {
+			return 0, err
+		}
+		for _, key := range m.piiKeys {
+			delete(session.Values, key)
+		}
+		delete(session.Values, UserIDKey)
+		var encoded string
+		if encoded, err = m.encodeValues(session); err != nil {
+			return 0, err
+		}
+		if _, err = tx.ExecContext(ctx, updQ, encoded, anonymizedUserID, row.id); err != nil {
+			return 0, err
+		}
+		for _, key := range chunkKeys {
+			if _, err = tx.ExecContext(ctx, delQ, row.id, key); err != nil {
+				return 0, err
+			}
+		}
+	}
+	// an update queued since the flush would bring the erased values back
+	for _, row := range batch {
+		m.cancelWrite(m.prefix + row.id)
+	}
+	if err = tx.Commit(); err != nil {
+		return 0, err
+	}
+	for _, row := range batch {
+		m.cache.remove(m.prefix + row.id)
+	}
+	return int64(len(batch)), nil
+}
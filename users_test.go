@@ -0,0 +1,139 @@
+package sqlitestore
+
+import (
+	"context"
+	"database/sql"
+	"testing"
+	"time"
+
+	"github.com/gorilla/sessions"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAnonymizeSessions(t *testing.T) {
+	store := newTestStore(t, WithUserIDColumn(), WithPIIKeys("email", "phone"))
+	ctx := context.Background()
+
+	var ids []string
+	for i := 0; i < anonymizeBatchSize+10; i++ {
+		sess := insertTestSession(t, store, map[interface{}]interface{}{
+			UserIDKey: "u1",
+			"email":   "u1@example.com",
+			"phone":   "555-0100",
+			"cart":    i,
+		})
+		ids = append(ids, sess.ID)
+	}
+	other := insertTestSession(t, store, map[interface{}]interface{}{UserIDKey: "u2", "email": "u2@example.com"})
+
+	n, err := store.AnonymizeSessions(ctx, "u1")
+	require.NoError(t, err)
+	assert.Equal(t, int64(len(ids)), n)
+
+	for i, id := range ids {
+		loaded := sessions.NewSession(store, "test")
+		loaded.ID = id
+		require.NoError(t, store.load(ctx, loaded))
+		assert.NotContains(t, loaded.Values, "email")
+		assert.NotContains(t, loaded.Values, "phone")
+		assert.NotContains(t, loaded.Values, UserIDKey)
+		assert.Equal(t, i, loaded.Values["cart"])
+	}
+
+	var userID string
+	require.NoError(t, store.db.QueryRowContext(ctx, "SELECT user_id FROM sessions WHERE id = ?", ids[0]).Scan(&userID))
+	assert.Equal(t, anonymizedUserID, userID)
+
+	loaded := sessions.NewSession(store, "test")
+	loaded.ID = other.ID
+	require.NoError(t, store.load(ctx, loaded))
+	assert.Equal(t, "u2@example.com", loaded.Values["email"])
+}
+
+func TestAnonymizeSessionsChunked(t *testing.T) {
+	store := newTestStore(t, WithUserIDColumn(), WithPIIKeys("email"), WithChunkedValueStorage())
+	ctx := context.Background()
+
+	sess := sessions.NewSession(store, "test")
+	sess.Options = &sessions.Options{MaxAge: 3600}
+	sess.Values["email"] = "a@b.c"
+	sess.Values["cart"] = 1
+	SetUserID(sess, "u1")
+	require.NoError(t, store.insert(ctx, sess))
+
+	n, err := store.AnonymizeSessions(ctx, "u1")
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), n)
+
+	loaded := sessions.NewSession(store, "test")
+	loaded.ID = sess.ID
+	require.NoError(t, store.load(ctx, loaded))
+	assert.NotContains(t, loaded.Values, "email")
+	assert.NotContains(t, loaded.Values, UserIDKey)
+	assert.Equal(t, 1, loaded.Values["cart"])
+
+	// saving again must not link the session to the user
+	loaded.Values["cart"] = 2
+	require.NoError(t, store.save(ctx, loaded))
+	var userID sql.NullString
+	require.NoError(t, store.db.QueryRowContext(ctx, "SELECT user_id FROM sessions WHERE id = ?", sess.ID).Scan(&userID))
+	assert.NotEqual(t, "u1", userID.String)
+}
+
+func TestAnonymizeSessionsCached(t *testing.T) {
+	store := newTestStore(t, WithUserIDColumn(), WithPIIKeys("email"), WithLRUCache(10))
+	ctx := context.Background()
+	sess := insertTestSession(t, store, map[interface{}]interface{}{UserIDKey: "u1", "email": "a@b.c"})
+	assert.Equal(t, "a@b.c", loadTestSession(t, store, sess.ID).Values["email"])
+
+	_, err := store.AnonymizeSessions(ctx, "u1")
+	require.NoError(t, err)
+	assert.NotContains(t, loadTestSession(t, store, sess.ID).Values, "email")
+}
+
+func TestAnonymizeSessionsRequiresColumn(t *testing.T) {
+	store := newTestStore(t)
+	_, err := store.AnonymizeSessions(context.Background(), "u1")
+	assert.Equal(t, ErrNoUserIDColumn, err)
+}
+
+func TestDeleteByUserID(t *testing.T) {
+	var deleted []string
+	store := newTestStore(t, WithUserIDColumn(), WithOnDelete(func(id string) { deleted = append(deleted, id) }))
+
+	a := insertTestSession(t, store, nil)
+	SetUserID(a, "u1")
+	require.NoError(t, store.save(context.Background(), a))
+	insertTestSession(t, store, map[interface{}]interface{}{UserIDKey: "u1"})
+	insertTestSession(t, store, map[interface{}]interface{}{UserIDKey: "u2"})
+
+	n, err := store.DeleteByUserID("u1")
+	require.NoError(t, err)
+	assert.Equal(t, int64(2), n)
+	assert.Len(t, deleted, 2)
+	assert.Equal(t, 1, countRows(t, store))
+
+	_, err = newTestStore(t).DeleteByUserID("u1")
+	assert.Equal(t, ErrNoUserIDColumn, err)
+}
+
+func TestAnonymizeSessionsPendingWrite(t *testing.T) {
+	store := newTestStore(t, WithWriteCoalescing(time.Hour), WithUserIDColumn(), WithPIIKeys("email"))
+	ctx := context.Background()
+
+	sess := insertTestSession(t, store, map[interface{}]interface{}{UserIDKey: "u1", "email": "x@y"})
+	sess.IsNew = false
+	sess.Values["cart"] = 1
+	require.NoError(t, store.save(ctx, sess))
+
+	n, err := store.AnonymizeSessions(ctx, "u1")
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), n)
+	require.NoError(t, store.flushWrites(ctx))
+
+	loaded := loadTestSession(t, store, sess.ID)
+	assert.NotContains(t, loaded.Values, "email")
+	assert.NotContains(t, loaded.Values, UserIDKey)
+	assert.Equal(t, 1, loaded.Values["cart"])
+}
@@ -0,0 +1,192 @@
+package sqlitestore
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"reflect"
+	"strings"
+
+	"github.com/gorilla/sessions"
+)
+
+// SampleAndValidate decodes up to sampleSize randomly chosen unexpired
+// sessions with the store's current codecs and counts how many succeed. It
+// gives quick feedback after a key rotation. err is only set for database
+// errors; sessions that fail to decode are counted as invalid.
+func (m *Store) SampleAndValidate(ctx context.Context, sampleSize int) (valid, invalid int64, err error) {
+	if sampleSize <= 0 {
+		return 0, 0, errors.New("sqlitestore: sample size must be positive")
+	}
+	rows, err := m.db.QueryContext(ctx, "SELECT id, name, session_data FROM "+m.table+
+		" WHERE expires_on > ? ORDER BY RANDOM() LIMIT ?", m.transform("expires_on", m.clock()), sampleSize)
+	if err != nil {
+		return 0, 0, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var sess sessionRow
+		if err := rows.Scan(&sess.id, &sess.name, &sess.data); err != nil {
+			return valid, invalid, err
+		}
+		if m.decodeValues(sessions.NewSession(m, sess.name), sess.data) == nil {
+			valid++
+		} else {
+			invalid++
+		}
+	}
+	return valid, invalid, rows.Err()
+}
+
+// DetectDuplicateSessions returns the session_data values stored in more
+// than one row, which can point at a bug in the write path such as the same
+// session being inserted twice. securecookie timestamps with one second
+// resolution, so sessions with identical values written in the same second
+// also show up; treat the results as leads to investigate.
+func (m *Store) DetectDuplicateSessions(ctx context.Context) ([]string, error) {
+	rows, err := m.db.QueryContext(ctx, "SELECT session_data FROM "+m.table+
+		" GROUP BY session_data HAVING COUNT(*) > 1")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var dups []string
+	for rows.Next() {
+		var data string
+		if err := rows.Scan(&data); err != nil {
+			return nil, err
+		}
+		dups = append(dups, data)
+	}
+	return dups, rows.Err()
+}
+
+// CompareSessions decodes the sessions idA and idB and reports whether their
+// values are equal, to help track down duplicated sessions. It returns
+// ErrSessionNotFound if either session is missing or expired.
+func (m *Store) CompareSessions(ctx context.Context, idA, idB string) (equal bool, err error) {
+	a, err := m.decodedValues(ctx, idA)
+	if err != nil {
+		return false, err
+	}
+	b, err := m.decodedValues(ctx, idB)
+	if err != nil {
+		return false, err
+	}
+	return reflect.DeepEqual(a, b), nil
+}
+
+// decodedValues returns the decoded values of the unexpired session id.
+func (m *Store) decodedValues(ctx context.Context, id string) (map[interface{}]interface{}, error) {
+	var sess sessionRow
+	err := m.db.QueryRowContext(ctx, "SELECT name, session_data FROM "+m.table+" WHERE id = ? AND expires_on > ?",
+		m.rowID(id), m.transform("expires_on", m.clock())).Scan(&sess.name, &sess.data)
+	if err == sql.ErrNoRows {
+		return nil, ErrSessionNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	if pw := m.pendingWrite(id); pw != nil {
+		sess.data = pw.data
+	}
+	session := sessions.NewSession(m, sess.name)
+	session.ID = id
+	if err := m.decodeValues(session, sess.data); err != nil {
+		return nil, err
+	}
+	if m.chunked {
+		if err := m.readChunks(ctx, m.db, session); err != nil {
+			return nil, err
+		}
+		delete(session.Values, chunkSnapshot{})
+	}
+	return session.Values, nil
+}
+
+// PurgeOrphanedRows deletes every session that can no longer be decoded with
+// the store's codecs, typically because it was encoded with a key that has
+// since been rotated out. Rows are checked batchSize at a time, expired or
+// not, and OnDelete fires for each row removed. It returns the number of rows
+// deleted.
+func (m *Store) PurgeOrphanedRows(ctx context.Context, batchSize int) (int64, error) {
+	if batchSize <= 0 {
+		return 0, errors.New("sqlitestore: batch size must be positive")
+	}
+	if m.readOnly {
+		return 0, ErrReadOnly
+	}
+	var purged int64
+	var lastID string
+	for first := true; ; first = false {
+		orphans, n, last, err := m.findOrphans(ctx, first, lastID, batchSize)
+		if err != nil {
+			return purged, err
+		}
+		if len(orphans) > 0 {
+			deleted, err := m.deleteOrphans(ctx, orphans)
+			purged += deleted
+			if err != nil {
+				return purged, err
+			}
+		}
+		if n < batchSize {
+			return purged, nil
+		}
+		lastID = last
+	}
+}
+
+// findOrphans reads the batch of rows after lastID, or the first batch, and
+// returns the IDs of those that fail to decode along with the number of rows
+// read and the ID of the last one.
+func (m *Store) findOrphans(ctx context.Context, first bool, lastID string, limit int) (orphans []string, n int, last string, err error) {
+	q := "SELECT id, name, session_data FROM " + m.table
+	args := []interface{}{}
+	if !first {
+		q += " WHERE id > ?"
+		args = append(args, lastID)
+	}
+	rows, err := m.db.QueryContext(ctx, q+" ORDER BY id LIMIT ?", append(args, limit)...)
+	if err != nil {
+		return nil, 0, "", err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var sess sessionRow
+		if err := rows.Scan(&sess.id, &sess.name, &sess.data); err != nil {
+			return nil, n, last, err
+		}
+		n++
+		last = sess.id
+		if m.decodeValues(sessions.NewSession(m, sess.name), sess.data) != nil {
+			orphans = append(orphans, sess.id)
+		}
+	}
+	return orphans, n, last, rows.Err()
+}
+
+func (m *Store) deleteOrphans(ctx context.Context, ids []string) (int64, error) {
+	args := make([]interface{}, len(ids))
+	for i, id := range ids {
+		m.cancelWrite(m.prefix + id)
+		args[i] = id
+	}
+	res, err := m.db.ExecContext(ctx, "DELETE FROM "+m.table+
+		" WHERE id IN (?"+strings.Repeat(", ?", len(ids)-1)+")", args...)
+	if err != nil {
+		return 0, err
+	}
+	for _, id := range ids {
+		if m.hasValueIndex() {
+			if err := m.InvalidateValueIndex(m.prefix + id); err != nil {
+				m.handleError(err)
+			}
+		}
+		m.runOnDelete(m.prefix + id)
+	}
+	return res.RowsAffected()
+}
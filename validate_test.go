@@ -0,0 +1,97 @@
+package sqlitestore
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/gorilla/securecookie"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSampleAndValidate(t *testing.T) {
+	store := newTestStore(t)
+	for i := 0; i < 3; i++ {
+		insertTestSession(t, store, map[interface{}]interface{}{"n": i})
+	}
+	for _, data := range []string{"garbage", ""} {
+		_, err := store.db.ExecContext(context.Background(),
+			"INSERT INTO sessions (session_data, expires_on, name) VALUES (?, ?, 'test')", data, time.Now().Add(time.Hour))
+		require.NoError(t, err)
+	}
+
+	valid, invalid, err := store.SampleAndValidate(context.Background(), 10)
+	require.NoError(t, err)
+	assert.Equal(t, int64(3), valid)
+	assert.Equal(t, int64(2), invalid)
+
+	valid, invalid, err = store.SampleAndValidate(context.Background(), 2)
+	require.NoError(t, err)
+	assert.Equal(t, int64(2), valid+invalid)
+}
+
+func TestDetectDuplicateSessions(t *testing.T) {
+	store := newTestStore(t)
+	ctx := context.Background()
+	a := insertTestSession(t, store, map[interface{}]interface{}{"n": 1})
+	insertTestSession(t, store, map[interface{}]interface{}{"n": 2})
+
+	dups, err := store.DetectDuplicateSessions(ctx)
+	require.NoError(t, err)
+	assert.Empty(t, dups)
+
+	data := rawSessionData(t, store, a.ID)
+	_, err = store.db.ExecContext(ctx, "INSERT INTO sessions (session_data, name) VALUES (?, 'test')", data)
+	require.NoError(t, err)
+	dups, err = store.DetectDuplicateSessions(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, []string{data}, dups)
+}
+
+func TestPurgeOrphanedRows(t *testing.T) {
+	db := newTestDB(t)
+	oldKeys := newTestStoreDB(t, db)
+	var orphaned []string
+	for i := 0; i < 5; i++ {
+		orphaned = append(orphaned, insertTestSession(t, oldKeys, map[interface{}]interface{}{"n": i}).ID)
+	}
+
+	var deleted []string
+	store, err := New(db, [][]byte{securecookie.GenerateRandomKey(32)},
+		WithOnDelete(func(id string) { deleted = append(deleted, id) }))
+	require.NoError(t, err)
+	t.Cleanup(store.Close)
+	kept := insertTestSession(t, store, map[interface{}]interface{}{"n": "new"})
+
+	n, err := store.PurgeOrphanedRows(context.Background(), 2)
+	require.NoError(t, err)
+	assert.Equal(t, int64(5), n)
+	assert.ElementsMatch(t, orphaned, deleted)
+	assert.Equal(t, 1, countRows(t, store))
+	assert.Equal(t, "new", loadTestSession(t, store, kept.ID).Values["n"])
+}
+
+func TestCompareSessions(t *testing.T) {
+	store := newTestStore(t)
+	ctx := context.Background()
+	values := map[interface{}]interface{}{"user": "alice", "roles": []string{"admin"}}
+	a := insertTestSession(t, store, values)
+	b := insertTestSession(t, store, values)
+
+	equal, err := store.CompareSessions(ctx, a.ID, b.ID)
+	require.NoError(t, err)
+	assert.True(t, equal)
+
+	b.Values["user"] = "bob"
+	require.NoError(t, store.save(ctx, b))
+	equal, err = store.CompareSessions(ctx, a.ID, b.ID)
+	require.NoError(t, err)
+	assert.False(t, equal)
+
+	expired := insertTestSession(t, store, map[interface{}]interface{}{"expires_on": time.Now().Add(-time.Hour)})
+	_, err = store.CompareSessions(ctx, a.ID, expired.ID)
+	assert.Equal(t, ErrSessionNotFound, err)
+	_, err = store.CompareSessions(ctx, a.ID, "999")
+	assert.Equal(t, ErrSessionNotFound, err)
+}
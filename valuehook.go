@@ -0,0 +1,87 @@
+package sqlitestore
+
+import (
+	"crypto/sha256"
+	"encoding/gob"
+	"encoding/hex"
+	"fmt"
+)
+
+// ValueHook intercepts session values as they are stored and loaded. OnWrite
+// is called for every value before the session is encoded and its result is
+// stored in place of the value; the session itself is not modified. OnRead is
+// called for every value after the session is decoded.
+type ValueHook interface {
+	OnWrite(key, val interface{}) interface{}
+	OnRead(key, val interface{}) interface{}
+}
+
+// HashedValue is a session value replaced by its SHA-256 digest by
+// HashingValueHook.
+type HashedValue string
+
+func init() {
+	gob.Register(HashedValue(""))
+}
+
+// HashingValueHook is a ValueHook that stores the hex encoded SHA-256 digest
+// of the values under its keys instead of the values themselves, so they can
+// be compared but not recovered.
+type HashingValueHook struct {
+	keys []interface{}
+}
+
+// NewHashingValueHook returns a HashingValueHook that hashes the values stored
+// under keys.
+func NewHashingValueHook(keys ...interface{}) *HashingValueHook {
+	return &HashingValueHook{keys: keys}
+}
+
+// OnWrite hashes val if key is one of the hook's keys. Values that are already
+// hashed are stored as is.
+func (h *HashingValueHook) OnWrite(key, val interface{}) interface{} {
+	if _, ok := val.(HashedValue); ok || !containsKey(h.keys, key) {
+		return val
+	}
+	sum := sha256.Sum256([]byte(fmt.Sprint(val)))
+	return HashedValue(hex.EncodeToString(sum[:]))
+}
+
+// OnRead returns val unchanged.
+func (h *HashingValueHook) OnRead(key, val interface{}) interface{} {
+	return val
+}
+
+func containsKey(keys []interface{}, key interface{}) bool {
+	for _, k := range keys {
+		if k == key {
+			return true
+		}
+	}
+	return false
+}
+
+// writeValues returns a copy of values with the value hook applied.
+func (m *Store) writeValues(values map[interface{}]interface{}) map[interface{}]interface{} {
+	if m.valueHook == nil {
+		return values
+	}
+	out := make(map[interface{}]interface{}, len(values))
+	for k, v := range values {
+		out[k] = v
+		key, val := k, v
+		m.runHook("OnWrite", func() { out[key] = m.valueHook.OnWrite(key, val) })
+	}
+	return out
+}
+
+// readValues applies the value hook to decoded values in place.
+func (m *Store) readValues(values map[interface{}]interface{}) {
+	if m.valueHook == nil {
+		return
+	}
+	for k, v := range values {
+		key, val := k, v
+		m.runHook("OnRead", func() { values[key] = m.valueHook.OnRead(key, val) })
+	}
+}
@@ -0,0 +1,46 @@
+package sqlitestore
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type exclaimHook struct{}
+
+func (exclaimHook) OnWrite(key, val interface{}) interface{} { return val }
+func (exclaimHook) OnRead(key, val interface{}) interface{} {
+	if key == "greeting" {
+		return val.(string) + "!"
+	}
+	return val
+}
+
+func TestHashingValueHook(t *testing.T) {
+	store := newTestStore(t, WithSessionValueHook(NewHashingValueHook("ssn")))
+	sess := insertTestSession(t, store, map[interface{}]interface{}{
+		"ssn":  "123-45-6789",
+		"name": "alice",
+	})
+	assert.Equal(t, "123-45-6789", sess.Values["ssn"], "the session itself is not modified")
+
+	sum := sha256.Sum256([]byte("123-45-6789"))
+	want := HashedValue(hex.EncodeToString(sum[:]))
+	loaded := loadTestSession(t, store, sess.ID)
+	assert.Equal(t, want, loaded.Values["ssn"])
+	assert.Equal(t, "alice", loaded.Values["name"])
+
+	// saving a loaded session must not hash the digest again
+	require.NoError(t, store.save(context.Background(), loaded))
+	assert.Equal(t, want, loadTestSession(t, store, sess.ID).Values["ssn"])
+}
+
+func TestValueHookOnRead(t *testing.T) {
+	store := newTestStore(t, WithSessionValueHook(exclaimHook{}))
+	sess := insertTestSession(t, store, map[interface{}]interface{}{"greeting": "hello"})
+	assert.Equal(t, "hello!", loadTestSession(t, store, sess.ID).Values["greeting"])
+}
@@ -0,0 +1,169 @@
+package sqlitestore
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/gorilla/sessions"
+)
+
+// valueIndexTable is the table CreateValueIndex keeps indexed values in,
+// session_value_index for the default table and <table>_value_index
+// otherwise, so stores sharing a database don't share entries.
+func (m *Store) valueIndexTable() string {
+	if m.table == defaultTableName {
+		return "session_value_index"
+	}
+	return m.table + "_value_index"
+}
+
+// CreateValueIndex indexes the value every unexpired session holds under key,
+// so FindSessionsByValue can find, for example, all sessions whose role is
+// "admin". Keys and values are indexed by their fmt.Sprint form. Calling it
+// again for the same key rebuilds that key's entries; the background job set
+// up with WithValueIndexRefresh rebuilds all indexed keys periodically.
+func (m *Store) CreateValueIndex(ctx context.Context, key interface{}) error {
+	if _, err := m.db.ExecContext(ctx, "CREATE TABLE IF NOT EXISTS "+m.valueIndexTable()+
+		" (session_id TEXT, key TEXT, value TEXT)"); err != nil {
+		return err
+	}
+	if _, err := m.db.ExecContext(ctx, "CREATE INDEX IF NOT EXISTS idx_"+m.valueIndexTable()+
+		" ON "+m.valueIndexTable()+" (key, value)"); err != nil {
+		return err
+	}
+	if err := m.buildValueIndex(ctx, key); err != nil {
+		return err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, k := range m.indexedKeys {
+		if k == key {
+			return nil
+		}
+	}
+	m.indexedKeys = append(m.indexedKeys, key)
+	return nil
+}
+
+// buildValueIndex replaces the index entries for key in one transaction.
+func (m *Store) buildValueIndex(ctx context.Context, key interface{}) (err error) {
+	tx, err := m.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err != nil {
+			tx.Rollback()
+		}
+	}()
+
+	k := fmt.Sprint(key)
+	if _, err = tx.ExecContext(ctx, "DELETE FROM "+m.valueIndexTable()+" WHERE key = ?", k); err != nil {
+		return err
+	}
+	rows, err := tx.QueryContext(ctx, "SELECT id, name, session_data FROM "+m.table+" WHERE expires_on >= ?",
+		m.transform("expires_on", m.clock()))
+	if err != nil {
+		return err
+	}
+	var batch []sessionRow
+	for rows.Next() {
+		var sess sessionRow
+		if err = rows.Scan(&sess.id, &sess.name, &sess.data); err != nil {
+			rows.Close()
+			return err
+		}
+		batch = append(batch, sess)
+	}
+	rows.Close()
+	if err = rows.Err(); err != nil {
+		return err
+	}
+
+	type entry struct{ id, value string }
+	var entries []entry
+	for _, sess := range batch {
+		session := sessions.NewSession(m, sess.name)
+		session.ID = m.prefix + sess.id
+		if m.decodeValues(session, sess.data) != nil {
+			continue
+		}
+		// with chunked storage session_data only holds an empty map
+		if m.chunked && m.readChunks(ctx, tx, session) != nil {
+			continue
+		}
+		if v, ok := session.Values[key]; ok {
+			entries = append(entries, entry{session.ID, fmt.Sprint(v)})
+		}
+	}
+
+	insQ := "INSERT INTO " + m.valueIndexTable() + " (session_id, key, value) VALUES (?, ?, ?)"
+	for _, e := range entries {
+		if _, err = tx.ExecContext(ctx, insQ, e.id, k, e.value); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+// FindSessionsByValue returns the IDs of up to limit sessions whose value
+// under key was value when the index was last built.
+func (m *Store) FindSessionsByValue(ctx context.Context, key interface{}, value interface{}, limit int) ([]string, error) {
+	rows, err := m.db.QueryContext(ctx, "SELECT session_id FROM "+m.valueIndexTable()+
+		" WHERE key = ? AND value = ? ORDER BY rowid LIMIT ?", fmt.Sprint(key), fmt.Sprint(value), limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var ids []string
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+	return ids, rows.Err()
+}
+
+// InvalidateValueIndex removes the index entries of a session. Delete calls
+// it for every session it removes once a value index exists.
+func (m *Store) InvalidateValueIndex(sessionID string) error {
+	_, err := m.db.ExecContext(context.Background(), "DELETE FROM "+m.valueIndexTable()+" WHERE session_id = ?", sessionID)
+	return err
+}
+
+// hasValueIndex reports whether CreateValueIndex has been called.
+func (m *Store) hasValueIndex() bool {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return len(m.indexedKeys) > 0
+}
+
+func (m *Store) startValueIndexRefresh(interval time.Duration) {
+	m.wg.Add(1)
+	go func() {
+		defer m.wg.Done()
+
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-m.done:
+				return
+			case <-ticker.C:
+			}
+			m.mu.RLock()
+			keys := append([]interface{}(nil), m.indexedKeys...)
+			m.mu.RUnlock()
+			for _, key := range keys {
+				if err := m.buildValueIndex(context.Background(), key); err != nil {
+					m.handleError(err)
+				}
+			}
+		}
+	}()
+}
@@ -0,0 +1,70 @@
+package sqlitestore
+
+import (
+	"context"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestValueIndex(t *testing.T) {
+	store := newTestStore(t, WithValueIndexRefresh(20*time.Millisecond))
+	ctx := context.Background()
+
+	a := insertTestSession(t, store, map[interface{}]interface{}{"role": "admin"})
+	insertTestSession(t, store, map[interface{}]interface{}{"role": "user"})
+	c := insertTestSession(t, store, map[interface{}]interface{}{"role": "admin"})
+	insertTestSession(t, store, nil)
+
+	require.NoError(t, store.CreateValueIndex(ctx, "role"))
+	ids, err := store.FindSessionsByValue(ctx, "role", "admin", 10)
+	require.NoError(t, err)
+	assert.Equal(t, []string{a.ID, c.ID}, ids)
+
+	require.NoError(t, store.Delete(httptest.NewRequest("GET", "/", nil), httptest.NewRecorder(), a))
+	ids, err = store.FindSessionsByValue(ctx, "role", "admin", 10)
+	require.NoError(t, err)
+	assert.Equal(t, []string{c.ID}, ids)
+
+	d := insertTestSession(t, store, map[interface{}]interface{}{"role": "admin"})
+	assert.Eventually(t, func() bool {
+		ids, err := store.FindSessionsByValue(ctx, "role", "admin", 10)
+		return err == nil && len(ids) == 2 && ids[1] == d.ID
+	}, time.Second, 10*time.Millisecond)
+}
+
+func TestValueIndexChunked(t *testing.T) {
+	store := newTestStore(t, WithChunkedValueStorage())
+	ctx := context.Background()
+
+	a := insertTestSession(t, store, map[interface{}]interface{}{"role": "admin"})
+	insertTestSession(t, store, map[interface{}]interface{}{"role": "user"})
+
+	require.NoError(t, store.CreateValueIndex(ctx, "role"))
+	ids, err := store.FindSessionsByValue(ctx, "role", "admin", 10)
+	require.NoError(t, err)
+	assert.Equal(t, []string{a.ID}, ids)
+}
+
+func TestValueIndexPerTable(t *testing.T) {
+	db := newTestDB(t)
+	a := newTestStoreDB(t, db)
+	b := newTestStoreDB(t, db, WithTableName("other_sessions"))
+	ctx := context.Background()
+
+	sa := insertTestSession(t, a, map[interface{}]interface{}{"role": "admin"})
+	insertTestSession(t, b, nil)
+	sb := insertTestSession(t, b, map[interface{}]interface{}{"role": "admin"})
+	require.NoError(t, a.CreateValueIndex(ctx, "role"))
+	require.NoError(t, b.CreateValueIndex(ctx, "role"))
+
+	ids, err := a.FindSessionsByValue(ctx, "role", "admin", 10)
+	require.NoError(t, err)
+	assert.Equal(t, []string{sa.ID}, ids)
+	ids, err = b.FindSessionsByValue(ctx, "role", "admin", 10)
+	require.NoError(t, err)
+	assert.Equal(t, []string{sb.ID}, ids)
+}
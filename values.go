@@ -0,0 +1,109 @@
+package sqlitestore
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/gob"
+	"errors"
+	"io"
+)
+
+// encryptedValue holds a session value encrypted by WithEncryptedValues.
+type encryptedValue []byte
+
+func init() {
+	gob.Register(encryptedValue{})
+}
+
+const valuesKeyInfo = "sqlitestore encrypted values"
+
+// hkdfSHA256 derives n bytes from secret as described in RFC 5869.
+func hkdfSHA256(secret, info []byte, n int) []byte {
+	extract := hmac.New(sha256.New, make([]byte, sha256.Size))
+	extract.Write(secret)
+	prk := extract.Sum(nil)
+
+	var out, prev []byte
+	for i := byte(1); len(out) < n; i++ {
+		expand := hmac.New(sha256.New, prk)
+		expand.Write(prev)
+		expand.Write(info)
+		expand.Write([]byte{i})
+		prev = expand.Sum(nil)
+		out = append(out, prev...)
+	}
+	return out[:n]
+}
+
+func newValuesCipher(hashKey []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(hkdfSHA256(hashKey, []byte(valuesKeyInfo), 32))
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+func (m *Store) isEncryptedKey(key interface{}) bool {
+	for _, k := range m.encryptedKeys {
+		if k == key {
+			return true
+		}
+	}
+	return false
+}
+
+// sealValues returns values with the entries registered by
+// WithEncryptedValues encrypted. values itself is left untouched.
+func (m *Store) sealValues(values map[interface{}]interface{}) (map[interface{}]interface{}, error) {
+	if m.valuesAEAD == nil {
+		return values, nil
+	}
+	sealed := make(map[interface{}]interface{}, len(values))
+	for k, v := range values {
+		if !m.isEncryptedKey(k) {
+			sealed[k] = v
+			continue
+		}
+		var buf bytes.Buffer
+		if err := gob.NewEncoder(&buf).Encode(&v); err != nil {
+			return nil, err
+		}
+		nonce := make([]byte, m.valuesAEAD.NonceSize())
+		if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+			return nil, err
+		}
+		sealed[k] = encryptedValue(m.valuesAEAD.Seal(nonce, nonce, buf.Bytes(), nil))
+	}
+	return sealed, nil
+}
+
+// openValues decrypts the encrypted entries of values in place.
+func (m *Store) openValues(values map[interface{}]interface{}) error {
+	for k, v := range values {
+		ev, ok := v.(encryptedValue)
+		if !ok {
+			continue
+		}
+		if m.valuesAEAD == nil {
+			return errors.New("sqlitestore: session has encrypted values but no value key is configured")
+		}
+		size := m.valuesAEAD.NonceSize()
+		if len(ev) < size {
+			return errors.New("sqlitestore: encrypted session value is truncated")
+		}
+		plain, err := m.valuesAEAD.Open(nil, ev[:size], ev[size:], nil)
+		if err != nil {
+			return err
+		}
+		var value interface{}
+		if err := gob.NewDecoder(bytes.NewReader(plain)).Decode(&value); err != nil {
+			return err
+		}
+		values[k] = value
+	}
+	return nil
+}